@@ -2,129 +2,442 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"packet-painter/internal/cables"
+	"packet-painter/internal/events"
+	"packet-painter/internal/export"
+	"packet-painter/internal/geo"
+	"packet-painter/internal/history"
+	"packet-painter/internal/layers"
+	"packet-painter/internal/scheduler"
+	"packet-painter/internal/sessions"
+	"packet-painter/internal/target"
+	"packet-painter/internal/telemetry"
 	"packet-painter/internal/trace"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// errHistoryUnavailable is returned when the history store failed to open
+var errHistoryUnavailable = errors.New("trace history is unavailable")
+
 // App struct
 type App struct {
 	ctx          context.Context
-	session      *trace.Session
-	mu           sync.Mutex
+	bus          *events.Bus
+	sessions     *sessions.Registry
+	recorder     *events.Recorder
 	cableService *cables.Service
+	layerService *layers.Service
+	history      *history.Store
+	geoLookup    *geo.Lookup
+	scheduler    *scheduler.Scheduler
+	telemetry    *telemetry.Exporter
+
+	backendMu sync.Mutex
+	backend   trace.Backend // Trace tool chosen by the user; auto picks the best available
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{
+	a := &App{
+		bus:          events.NewBus(),
 		cableService: cables.NewService(),
+		layerService: layers.NewService(filepath.Join(userDataDir(), "layers.json")),
+		geoLookup:    geo.NewLookup(),
+	}
+	a.recorder = events.NewRecorder(a.saveResult)
+	a.sessions = sessions.New(a.bus)
+	return a
+}
+
+// userDataDir returns the directory where packet-painter keeps its data
+// Falls back to the working directory if no user config dir is available
+func userDataDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
 	}
+	return filepath.Join(dir, "packet-painter")
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-}
 
-// StartTrace begins a new traceroute to the specified target
-func (a *App) StartTrace(target string) string {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	// Cancel any existing session
-	if a.session != nil && a.session.IsRunning() {
-		a.session.Cancel()
-	}
-
-	// Create new session
-	a.session = trace.NewSession(target)
-	sessionID := a.session.ID
-	source := a.session.GetSource()
-
-	// Emit trace started event
-	runtime.EventsEmit(a.ctx, "trace:started", trace.TraceStartedEvent{
-		SessionID: sessionID,
-		Target:    target,
-		Source:    source,
-		Timestamp: time.Now().UnixMilli(),
+	// Find out which trace tools work here before the first trace needs one
+	caps := trace.DetectCapabilities()
+	if caps.Selected == "" {
+		for _, note := range caps.Guidance {
+			println("Trace backend:", note)
+		}
+	}
+
+	// Open the trace history store
+	store, err := history.Open(filepath.Join(userDataDir(), "history"))
+	if err != nil {
+		println("Failed to open trace history:", err.Error())
+	} else {
+		a.history = store
+	}
+
+	// Forward trace events to the frontend under their event names, and
+	// record finished traces for history and telemetry
+	a.bus.Handle(events.SubscribeOptions{}, func(e events.Event) {
+		runtime.EventsEmit(a.ctx, e.Name, e)
 	})
+	a.bus.Handle(events.SubscribeOptions{}, a.recorder.Handle)
 
-	// Create context with 20-second timeout
-	traceCtx, _ := context.WithTimeout(a.ctx, 20*time.Second)
-
-	// Start the trace with callbacks
-	a.session.Start(traceCtx,
-		// On hop callback
-		func(hop *trace.Hop) {
-			// Log hop for debugging
-			println("Hop received:", hop.HopNumber, hop.IPAddress)
-			runtime.EventsEmit(a.ctx, "trace:hop", trace.TraceHopEvent{
-				SessionID: sessionID,
-				Hop:       hop,
-			})
-		},
-		// On complete callback
-		func(totalHops int) {
-			println("Trace completed:", totalHops, "hops")
-			runtime.EventsEmit(a.ctx, "trace:completed", trace.TraceCompletedEvent{
-				SessionID: sessionID,
-				TotalHops: totalHops,
-				Timestamp: time.Now().UnixMilli(),
-			})
-		},
-		// On error callback
-		func(err error) {
-			println("Trace error:", err.Error())
-			runtime.EventsEmit(a.ctx, "trace:error", trace.TraceErrorEvent{
-				SessionID: sessionID,
-				Error:     err.Error(),
-				Timestamp: time.Now().UnixMilli(),
-			})
-		},
-	)
+	// Load user map layers and reload them when their files change
+	if err := a.layerService.LoadConfig(); err != nil {
+		println("Failed to load layers:", err.Error())
+	}
+	go a.layerService.Watch(ctx, func(id string) {
+		runtime.EventsEmit(a.ctx, "layers:updated", id)
+	})
+
+	// Export finished traces when an OTLP collector is configured
+	if cfg := telemetry.ConfigFromEnv(); cfg.Enabled() {
+		a.telemetry = telemetry.NewExporter(cfg)
+	}
 
-	return sessionID
+	// Start recurring traces alongside the others; the recorder saves and
+	// exports them like any trace on the bus, and history serves earlier runs
+	// for comparison
+	var results scheduler.Store
+	if a.history != nil {
+		results = a.history
+	}
+	a.scheduler = scheduler.New(filepath.Join(userDataDir(), "schedules.json"), results, a.sessions, func(event events.Payload) {
+		a.bus.Publish(event)
+	})
+	if err := a.scheduler.Load(); err != nil {
+		println("Failed to load scheduled traces:", err.Error())
+	}
+	a.scheduler.Start(ctx)
 }
 
-// CancelTrace stops the current traceroute
-func (a *App) CancelTrace() {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+// shutdown is called when the app is closing
+func (a *App) shutdown(ctx context.Context) {
+	// Stop scheduling before the registry refuses new traces
+	if a.scheduler != nil {
+		a.scheduler.Stop()
+	}
 
-	if a.session != nil {
-		sessionID := a.session.ID
+	// Running traces publish trace:cancelled, so history records them
+	a.sessions.Close()
 
-		// Cancel if still running
-		if a.session.IsRunning() {
-			a.session.Cancel()
-		}
+	// Deliver what subscribers still have queued, including the cancelled trace
+	a.bus.Close()
 
-		// Always emit cancelled event so frontend can update
-		runtime.EventsEmit(a.ctx, "trace:cancelled", trace.TraceCancelledEvent{
-			SessionID: sessionID,
-			Timestamp: time.Now().UnixMilli(),
-		})
+	if a.telemetry != nil {
+		a.telemetry.Flush()
+	}
+
+	// Flush any traces still waiting to be written
+	if a.history != nil {
+		a.history.Close()
 	}
 }
 
-// GetTraceStatus returns whether a trace is currently running
-func (a *App) GetTraceStatus() bool {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+// StartTrace begins a new traceroute to the specified target
+// The target may be a hostname, IP address or URL; it is validated and
+// resolved first so bad input fails here rather than inside the trace tool.
+// Other traces keep running; beyond the concurrency limit the trace is
+// queued, or rejected when queueing is off. Returns the session ID
+func (a *App) StartTrace(input string) (string, error) {
+	resolved, err := target.Resolve(a.ctx, nil, input)
+	if err != nil {
+		return "", err
+	}
+	info, err := a.sessions.Start(a.newSession(resolved.Host))
+	if err != nil {
+		return "", err
+	}
+	return info.ID, nil
+}
 
-	if a.session == nil {
-		return false
+// TraceAllAddresses traces every address the target resolves to side by side
+// and compares the paths, one comparison per address family. Only IPv4
+// addresses are traced when there are any, unless bothFamilies is set.
+// Each trace streams its events like any other; this returns once all have finished
+func (a *App) TraceAllAddresses(input string, bothFamilies bool) ([]*trace.PathComparison, error) {
+	resolved, err := target.Resolve(a.ctx, nil, input)
+	if err != nil {
+		return nil, err
 	}
-	return a.session.IsRunning()
+	return a.sessions.TraceAll(a.ctx, resolved.Host, resolved.Select(bothFamilies), a.newSession)
+}
+
+// newSession creates a session that runs the user's chosen backend
+func (a *App) newSession(host string) *trace.Session {
+	a.backendMu.Lock()
+	opts := trace.DefaultOptions()
+	opts.Backend = a.backend
+	a.backendMu.Unlock()
+	return trace.NewSessionWithOptions(host, opts)
+}
+
+// GetCapabilities reports which trace backends are installed and usable,
+// whether raw or unprivileged ICMP probes are allowed, and what to do when
+// nothing works. The result is probed once at startup
+func (a *App) GetCapabilities() trace.Capabilities {
+	return trace.DetectCapabilities()
+}
+
+// SetTraceBackend chooses the trace tool for new traces; "auto" or "" picks
+// the best available. An installed but unusable choice fails at trace time
+// with guidance, so the user sees why
+func (a *App) SetTraceBackend(name string) error {
+	backend, err := trace.ParseBackend(name)
+	if err != nil {
+		return err
+	}
+	a.backendMu.Lock()
+	a.backend = backend
+	a.backendMu.Unlock()
+	return nil
+}
+
+// ResolveTarget validates a target and returns every address it resolves to,
+// so the user can pick one to trace
+func (a *App) ResolveTarget(input string) (target.Target, error) {
+	return target.Resolve(a.ctx, nil, input)
+}
+
+// ListSessions returns queued, running and recently finished traces, oldest first
+func (a *App) ListSessions() []sessions.Info {
+	return a.sessions.List()
+}
+
+// GetSession returns the state of one trace
+func (a *App) GetSession(id string) (sessions.Info, error) {
+	return a.sessions.Get(id)
+}
+
+// GetSessionSnapshot returns a trace's hops and state so far, so a reloaded
+// frontend can rebuild its view and then apply only events after Seq
+func (a *App) GetSessionSnapshot(id string) (sessions.Snapshot, error) {
+	return a.sessions.Snapshot(id)
+}
+
+// CancelTrace stops a queued or running traceroute
+func (a *App) CancelTrace(id string) error {
+	return a.sessions.Cancel(id)
+}
+
+// CancelAll stops every queued and running traceroute
+func (a *App) CancelAll() {
+	a.sessions.CancelAll()
+}
+
+// GetSessionLimits returns how many traces may run at once and whether extra ones queue
+func (a *App) GetSessionLimits() sessions.Limits {
+	return a.sessions.Limits()
+}
+
+// SetSessionLimits changes how many traces may run at once and whether extra ones queue
+func (a *App) SetSessionLimits(limits sessions.Limits) {
+	a.sessions.SetLimits(limits)
+}
+
+// ReplayTrace returns a session's events after the given sequence number,
+// so a frontend that reloads mid-trace can catch up
+func (a *App) ReplayTrace(sessionID string, afterSeq uint64) []events.Event {
+	return a.bus.Replay(sessionID, afterSeq)
+}
+
+// saveResult sends a finished trace to the history store and telemetry exporter
+func (a *App) saveResult(result *trace.Result) {
+	if a.history != nil {
+		if err := a.history.Save(result); err != nil {
+			println("Failed to save trace:", err.Error())
+		}
+	}
+	if a.telemetry != nil {
+		a.telemetry.Observe(result)
+	}
+}
+
+// GetTraceStatus returns whether any trace is currently running
+func (a *App) GetTraceStatus() bool {
+	return a.sessions.Running() > 0
 }
 
 // GetSubmarineCables fetches submarine cable data from TeleGeography API
 func (a *App) GetSubmarineCables() ([]cables.Cable, error) {
 	return a.cableService.FetchCables()
 }
+
+// ListLayers returns all user map layers without their features
+func (a *App) ListLayers() []layers.Info {
+	return a.layerService.List()
+}
+
+// GetLayer returns a map layer with all of its features
+func (a *App) GetLayer(id string) (*layers.Layer, error) {
+	return a.layerService.Get(id)
+}
+
+// AddLayer loads a GeoJSON file or URL as a new map layer
+func (a *App) AddLayer(config layers.Config) (*layers.Info, error) {
+	return a.layerService.Add(config)
+}
+
+// RemoveLayer deletes a map layer
+func (a *App) RemoveLayer(id string) error {
+	return a.layerService.Remove(id)
+}
+
+// SetLayerVisible toggles whether a map layer is drawn on the globe
+func (a *App) SetLayerVisible(id string, visible bool) error {
+	return a.layerService.SetVisible(id, visible)
+}
+
+// ListTraces returns saved traces matching the filter, newest first
+func (a *App) ListTraces(filter history.Filter) ([]history.Summary, error) {
+	if a.history == nil {
+		return nil, errHistoryUnavailable
+	}
+	return a.history.List(filter), nil
+}
+
+// LoadTrace returns a saved trace with all of its hops so it can be re-rendered
+func (a *App) LoadTrace(id string) (*trace.Result, error) {
+	if a.history == nil {
+		return nil, errHistoryUnavailable
+	}
+	return a.history.Get(id)
+}
+
+// DeleteTrace removes a saved trace
+func (a *App) DeleteTrace(id string) error {
+	if a.history == nil {
+		return errHistoryUnavailable
+	}
+	return a.history.Delete(id)
+}
+
+// PruneTraces deletes saved traces older than maxAgeDays and keeps at most maxCount
+// Pass 0 for either limit to disable it. Returns the number of traces deleted
+func (a *App) PruneTraces(maxAgeDays int, maxCount int) (int, error) {
+	if a.history == nil {
+		return 0, errHistoryUnavailable
+	}
+	return a.history.Prune(time.Duration(maxAgeDays)*24*time.Hour, maxCount)
+}
+
+// findResult returns the trace for a session ID from recent sessions or history
+func (a *App) findResult(sessionID string) (*trace.Result, error) {
+	if result, ok := a.recorder.Snapshot(sessionID); ok {
+		return result, nil
+	}
+
+	if a.history == nil {
+		return nil, errHistoryUnavailable
+	}
+	return a.history.Get(sessionID)
+}
+
+// ExportTrace saves a trace to a file chosen with a native save dialog
+// format is one of "json", "csv", "csv-probes", "text", "geojson" or "kml"
+// Returns the saved path, or "" if the user cancelled the dialog
+func (a *App) ExportTrace(sessionID string, format string) (string, error) {
+	exportFormat, err := export.ParseFormat(format)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := a.findResult(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	ext := exportFormat.Extension()
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Trace",
+		DefaultFilename: fmt.Sprintf("trace-%s-%s.%s", result.Target, time.UnixMilli(result.StartedAt).Format("20060102-150405"), ext),
+		Filters: []runtime.FileFilter{
+			{DisplayName: exportFormat.Description(), Pattern: "*." + ext},
+		},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := export.Write(file, result, exportFormat); err != nil {
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// ImportTrace parses pasted traceroute, tracert, pathping or mtr output and
+// replays it as a normal session, saving it to history
+// Returns the new session ID
+func (a *App) ImportTrace(text string) (string, error) {
+	result, _, err := trace.Import(text, a.geoLookup.GetLocation)
+	if err != nil {
+		return "", err
+	}
+
+	// The recorder saves it to history like any other finished trace
+	events.PublishResult(a.bus, result)
+	return result.SessionID, nil
+}
+
+// DiffTraces compares two traces by session ID and returns an aligned diff
+// Either ID may refer to the current session or a saved trace
+func (a *App) DiffTraces(sessionA string, sessionB string) (*trace.TraceDiff, error) {
+	resultA, err := a.findResult(sessionA)
+	if err != nil {
+		return nil, fmt.Errorf("trace %s: %w", sessionA, err)
+	}
+	resultB, err := a.findResult(sessionB)
+	if err != nil {
+		return nil, fmt.Errorf("trace %s: %w", sessionB, err)
+	}
+	return trace.DiffResults(resultA, resultB), nil
+}
+
+// ListScheduledTraces returns every recurring trace job
+func (a *App) ListScheduledTraces() []scheduler.Job {
+	return a.scheduler.Jobs()
+}
+
+// AddScheduledTrace creates a recurring trace job
+func (a *App) AddScheduledTrace(job scheduler.Job) (*scheduler.Job, error) {
+	return a.scheduler.AddJob(job)
+}
+
+// UpdateScheduledTrace changes a recurring trace job
+func (a *App) UpdateScheduledTrace(job scheduler.Job) (*scheduler.Job, error) {
+	return a.scheduler.UpdateJob(job)
+}
+
+// RemoveScheduledTrace deletes a recurring trace job
+func (a *App) RemoveScheduledTrace(id string) error {
+	return a.scheduler.RemoveJob(id)
+}
+
+// RunScheduledTraceNow runs a recurring trace job immediately
+// Returns the session ID of the saved run
+func (a *App) RunScheduledTraceNow(id string) (string, error) {
+	result, err := a.scheduler.RunNow(a.ctx, id)
+	if result == nil {
+		return "", err
+	}
+	return result.SessionID, err
+}
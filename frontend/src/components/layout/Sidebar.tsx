@@ -7,6 +7,7 @@ import { AlertCircle } from 'lucide-react';
 export function Sidebar() {
   const session = useTraceStore((state) => state.session);
   const error = session?.status === 'error' ? session.error : null;
+  const remediation = session?.status === 'error' ? session.remediation : null;
 
   return (
     <aside className="w-80 flex flex-col h-full border-r border-border/30" style={{ boxShadow: '1px 0 3px 0 rgb(0 0 0 / 0.2)', background: 'linear-gradient(180deg, hsl(270 25% 8% / 0.95) 0%, hsl(270 30% 5% / 0.95) 100%)' }}>
@@ -16,7 +17,12 @@ export function Sidebar() {
           <div className="mt-3 p-3 bg-destructive/10 border border-destructive/20 rounded-md">
             <div className="flex items-start gap-2">
               <AlertCircle className="h-4 w-4 text-destructive mt-0.5 flex-shrink-0" />
-              <p className="text-sm text-destructive">{error}</p>
+              <div>
+                <p className="text-sm text-destructive">{error}</p>
+                {remediation && (
+                  <p className="mt-1 text-xs text-muted-foreground">{remediation}</p>
+                )}
+              </div>
             </div>
           </div>
         )}
@@ -0,0 +1,44 @@
+import { trace } from '../../../wailsjs/go/models';
+import { GitFork, CheckCircle2, XCircle } from 'lucide-react';
+
+interface AddressComparisonProps {
+  comparisons: trace.PathComparison[];
+}
+
+// Shows where the paths to each address of a host split, and how fast each one is
+export function AddressComparison({ comparisons }: AddressComparisonProps) {
+  return (
+    <div className="space-y-3">
+      {comparisons.map((c) => (
+        <div key={c.family} className="bg-accent/30 rounded-md p-2 space-y-1.5 text-xs">
+          <div className="flex items-center gap-2 font-medium">
+            <GitFork className="h-3.5 w-3.5 text-primary" />
+            IPv{c.family}: {c.paths.length} addresses
+          </div>
+          <p className="text-muted-foreground">
+            {c.divergeHop > 0
+              ? `Shared for ${c.sharedHops.length} hops, diverging at hop ${c.divergeHop}`
+              : 'Every address takes the same path'}
+          </p>
+          <ul className="space-y-0.5">
+            {c.paths.map((p) => (
+              <li key={p.address} className="flex items-center justify-between gap-2">
+                <span className="flex items-center gap-1.5 font-mono">
+                  {p.reached ? (
+                    <CheckCircle2 className="h-3 w-3 text-green-500" />
+                  ) : (
+                    <XCircle className="h-3 w-3 text-amber-500" />
+                  )}
+                  {p.address}
+                </span>
+                <span className="text-muted-foreground">
+                  {p.error ? 'error' : `${p.hops} hops, ${p.endToEndMs.toFixed(1)} ms`}
+                </span>
+              </li>
+            ))}
+          </ul>
+        </div>
+      ))}
+    </div>
+  );
+}
@@ -0,0 +1,70 @@
+import { useState, useEffect } from 'react';
+import { GetCapabilities, SetTraceBackend } from '../../../wailsjs/go/main/App';
+import { trace as traceModels } from '../../../wailsjs/go/models';
+import { AlertTriangle } from 'lucide-react';
+
+/**
+ * Picks the trace tool for new traces
+ * Lists the backends found at startup and explains what to install when none work
+ */
+export function BackendSelect({ disabled }: { disabled?: boolean }) {
+  const [capabilities, setCapabilities] = useState<traceModels.Capabilities | null>(null);
+  const [backend, setBackend] = useState('auto');
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    GetCapabilities()
+      .then(setCapabilities)
+      .catch((err) => console.error('Failed to load trace capabilities:', err));
+  }, []);
+
+  if (!capabilities) return null;
+
+  const choose = async (name: string) => {
+    setBackend(name);
+    try {
+      await SetTraceBackend(name);
+      setError(null);
+    } catch (err) {
+      setError(String(err));
+    }
+  };
+
+  const installed = capabilities.backends.filter((b) => b.path);
+
+  return (
+    <div className="space-y-1.5">
+      <label className="flex items-center gap-2 text-sm">
+        <span className="text-muted-foreground">Trace with</span>
+        <select
+          value={backend}
+          onChange={(e) => choose(e.target.value)}
+          disabled={disabled}
+          className="rounded border border-border bg-background/50 px-2 py-1 text-xs"
+        >
+          <option value="auto">
+            Auto{capabilities.selected ? ` (${capabilities.selected})` : ''}
+          </option>
+          {installed.map((b) => (
+            <option key={b.name} value={b.name} disabled={!b.usable} title={b.reason}>
+              {b.name}
+              {b.usable ? '' : ' (unavailable)'}
+            </option>
+          ))}
+        </select>
+      </label>
+      {error && <p className="text-xs text-destructive">{error}</p>}
+      {!capabilities.selected && (
+        <div className="space-y-1 rounded border border-destructive/40 bg-destructive/10 p-2 text-xs text-destructive">
+          <p className="flex items-center gap-1.5 font-medium">
+            <AlertTriangle className="h-3 w-3" />
+            No trace tool available
+          </p>
+          {capabilities.guidance?.map((note) => (
+            <p key={note}>{note}</p>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+}
@@ -1,20 +1,32 @@
 import { motion } from 'framer-motion';
 import { Card } from '@/components/ui/card';
 import { Badge } from '@/components/ui/badge';
-import { Hop } from '@/types';
+import { Hop, Unreachable } from '@/types';
 import { getLatencyColor, formatRtt } from '@/lib/globe-utils';
 import { getDatacenterShortName } from '@/lib/datacenter-utils';
 import { cn } from '@/lib/utils';
 import { MapPin, Clock, Cloud } from 'lucide-react';
 
+// Short labels for the unreachable annotations routers send back
+const unreachableLabels: Record<Unreachable, string> = {
+  host_unreachable: '!H Host unreachable',
+  network_unreachable: '!N Network unreachable',
+  protocol_unreachable: '!P Protocol unreachable',
+  admin_prohibited: '!X Prohibited',
+  network_prohibited: '!A Network prohibited',
+  host_prohibited: '!Z Host prohibited',
+  unreachable: 'Unreachable',
+};
+
 interface HopItemProps {
   hop: Hop;
+  previousMtu?: number;
   index: number;
   isSelected: boolean;
   onSelect: () => void;
 }
 
-export function HopItem({ hop, index, isSelected, onSelect }: HopItemProps) {
+export function HopItem({ hop, previousMtu, index, isSelected, onSelect }: HopItemProps) {
   const latencyColor = getLatencyColor(hop.avgRtt, hop.isTimeout);
 
   return (
@@ -70,6 +82,22 @@ export function HopItem({ hop, index, isSelected, onSelect }: HopItemProps) {
                   Destination
                 </Badge>
               )}
+              {/* Flag where the path MTU drops, e.g. entering a VPN or GRE tunnel */}
+              {hop.mtu && previousMtu && hop.mtu < previousMtu && (
+                <Badge variant="outline" className="text-xs text-amber-500 border-amber-500/40">
+                  MTU {hop.mtu}
+                </Badge>
+              )}
+              {hop.unreachable && (
+                <Badge variant="outline" className="text-xs text-red-400 border-red-400/40">
+                  {unreachableLabels[hop.unreachable]}
+                </Badge>
+              )}
+              {hop.mpls && hop.mpls.length > 0 && (
+                <Badge variant="outline" className="text-xs text-sky-400 border-sky-400/40">
+                  MPLS {hop.mpls[0].label}
+                </Badge>
+              )}
             </div>
 
             {hop.hostname && hop.hostname !== hop.ipAddress && (
@@ -79,6 +107,14 @@ export function HopItem({ hop, index, isSelected, onSelect }: HopItemProps) {
             )}
 
             <div className="flex items-center gap-3 text-xs text-muted-foreground">
+              {hop.asn && (
+                <span
+                  className={cn('font-mono', hop.asnConflicts?.length && 'text-amber-500')}
+                  title={hop.asnConflicts?.length ? 'Sources disagree on this AS number' : undefined}
+                >
+                  {hop.asn}
+                </span>
+              )}
               {hop.location && (
                 <span className="flex items-center gap-1">
                   <MapPin className="h-3 w-3" />
@@ -119,6 +155,46 @@ export function HopItem({ hop, index, isSelected, onSelect }: HopItemProps) {
                 </span>
               ))}
             </div>
+            {(hop.mtu || hop.returnHops) && (
+              <div className="flex gap-3 mt-1 text-xs text-muted-foreground">
+                {hop.mtu && (
+                  <span>
+                    MTU: <span className="text-foreground">{hop.mtu}</span>
+                  </span>
+                )}
+                {hop.returnHops && (
+                  <span>
+                    Asymmetric: reply took <span className="text-foreground">{hop.returnHops}</span> hops back
+                  </span>
+                )}
+              </div>
+            )}
+            {hop.asnConflicts && hop.asnConflicts.length > 0 && (
+              <div className="mt-1 text-xs text-muted-foreground">
+                Also reported:{' '}
+                {hop.asnConflicts.map((claim, i) => (
+                  <span key={i} className="mr-2">
+                    <span className="text-foreground">{claim.asn}</span> by {claim.source}
+                    {claim.ip && ` for ${claim.ip}`}
+                  </span>
+                ))}
+              </div>
+            )}
+            {hop.mpls && hop.mpls.length > 0 && (
+              <div className="mt-1 text-xs text-muted-foreground">
+                MPLS labels:{' '}
+                {hop.mpls.map((entry, i) => (
+                  <span key={i} className="text-foreground mr-2">
+                    {entry.label}
+                    <span className="text-muted-foreground">
+                      {' '}
+                      (TC {entry.trafficClass}, TTL {entry.ttl}
+                      {entry.bottomOfStack && ', bottom'})
+                    </span>
+                  </span>
+                ))}
+              </div>
+            )}
           </motion.div>
         )}
         {isSelected && hop.isTimeout && (
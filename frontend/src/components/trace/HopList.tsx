@@ -3,9 +3,25 @@ import { HopItem } from './HopItem';
 import { motion, AnimatePresence } from 'framer-motion';
 import { Badge } from '@/components/ui/badge';
 import { Network, CheckCircle2, XCircle, Loader2, AlertTriangle } from 'lucide-react';
+import { PathOutcome, StopReason } from '@/types';
+
+// Explains why a trace stopped short of the destination
+const stopReasonText: Partial<Record<StopReason, string>> = {
+  trailing_timeouts:
+    'Several hops in a row stopped answering, so the trace ended early. The target may be blocking traceroute probes. This is common with services like Netflix and Cloudflare.',
+  max_hops: 'Every hop up to the hop limit was probed without the destination replying.',
+  deadline: 'The trace ran out of time while routers were still answering.',
+  unreachable: 'A router replied that the destination cannot be reached, so nothing further was probed.',
+};
+
+// Headline for a trace that stopped short of the destination
+const pathOutcomeText: Partial<Record<PathOutcome, string>> = {
+  unreachable: 'Destination unreachable',
+  prohibited: 'Administratively prohibited',
+};
 
 export function HopList() {
-  const { session, selectedHopIndex, selectHop, consecutiveTimeouts } = useTraceStore();
+  const { session, selectedHopIndex, selectHop } = useTraceStore();
 
   if (!session) {
     return (
@@ -17,7 +33,8 @@ export function HopList() {
     );
   }
 
-  const { hops, status, source } = session;
+  const { hops, status, source, pathOutcome, stopReason, pathMtu, mplsTunnels } = session;
+  const stoppedShort = status === 'completed' && pathOutcome && pathOutcome !== 'reached';
 
   return (
     <div className="p-4 space-y-4">
@@ -29,6 +46,11 @@ export function HopList() {
               <Loader2 className="h-4 w-4 animate-spin text-primary" />
               Tracing route...
             </span>
+          ) : stoppedShort ? (
+            <span className="flex items-center gap-2 text-amber-500">
+              <AlertTriangle className="h-4 w-4" />
+              {pathOutcomeText[pathOutcome] ?? 'Partial trace'}
+            </span>
           ) : status === 'completed' ? (
             <span className="flex items-center gap-2 text-green-500">
               <CheckCircle2 className="h-4 w-4" />
@@ -46,19 +68,16 @@ export function HopList() {
         <Badge variant="secondary">{hops.length} hops</Badge>
       </div>
 
-      {/* Timeout warning banner */}
-      {status === 'running' && consecutiveTimeouts >= 3 && (
+      {/* Why the trace stopped before the destination */}
+      {stoppedShort && stopReason && stopReasonText[stopReason] && (
         <div className="bg-amber-500/10 border border-amber-500/30 rounded-md p-3">
           <div className="flex items-start gap-2">
             <AlertTriangle className="h-4 w-4 text-amber-500 mt-0.5 flex-shrink-0" />
             <div className="text-sm">
               <p className="font-medium text-amber-500">
-                {consecutiveTimeouts} consecutive timeouts
-              </p>
-              <p className="text-muted-foreground text-xs mt-1">
-                The target may be blocking traceroute probes. This is common with
-                services like Netflix and Cloudflare.
+                Destination not reached after {hops.length} hops
               </p>
+              <p className="text-muted-foreground text-xs mt-1">{stopReasonText[stopReason]}</p>
             </div>
           </div>
         </div>
@@ -72,6 +91,30 @@ export function HopList() {
         </div>
       )}
 
+      {/* Smallest MTU on the path, from backends that discover it */}
+      {pathMtu && (
+        <div className="text-xs text-muted-foreground bg-accent/30 rounded-md p-2">
+          <span className="font-medium text-foreground/80">Path MTU:</span> {pathMtu} bytes
+        </div>
+      )}
+
+      {/* MPLS tunnels, from quoted label stacks or runs of near-identical RTTs */}
+      {mplsTunnels && mplsTunnels.length > 0 && (
+        <div className="text-xs text-muted-foreground bg-accent/30 rounded-md p-2 space-y-1">
+          {mplsTunnels.map((tunnel) => (
+            <div key={`${tunnel.kind}-${tunnel.firstHop}`}>
+              <span className="font-medium text-foreground/80">
+                {tunnel.kind === 'visible' ? 'MPLS tunnel' : 'Likely hidden MPLS tunnel'}:
+              </span>{' '}
+              {tunnel.firstHop === tunnel.lastHop
+                ? `hop ${tunnel.firstHop}`
+                : `hops ${tunnel.firstHop}-${tunnel.lastHop}`}
+              {tunnel.labels && tunnel.labels.length > 0 && ` (labels ${tunnel.labels.join(', ')})`}
+            </div>
+          ))}
+        </div>
+      )}
+
       {/* Hop list */}
       <div className="space-y-2">
         <AnimatePresence mode="popLayout">
@@ -79,6 +122,7 @@ export function HopList() {
             <HopItem
               key={`${hop.hopNumber}-${hop.ipAddress}`}
               hop={hop}
+              previousMtu={hops[index - 1]?.mtu}
               index={index}
               isSelected={selectedHopIndex === index}
               onSelect={() => selectHop(selectedHopIndex === index ? null : index)}
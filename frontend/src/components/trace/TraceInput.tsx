@@ -3,13 +3,28 @@ import { Button } from '@/components/ui/button';
 import { Input } from '@/components/ui/input';
 import { useTraceSession } from '@/hooks/useTraceSession';
 import { useTraceStore } from '@/stores/traceStore';
+import { AddressComparison } from './AddressComparison';
+import { BackendSelect } from './BackendSelect';
+import { trace as traceModels } from '../../../wailsjs/go/models';
 import { Play, Trash2, Loader2, Cable, Square } from 'lucide-react';
 
 export function TraceInput() {
   const [target, setTarget] = useState('tokyo.jp');
   const [isCancelling, setIsCancelling] = useState(false);
-  const { startTrace, cancelTrace, clearTrace, isRunning, hasSession } =
-    useTraceSession();
+  const [inputError, setInputError] = useState<string | null>(null);
+  // Addresses to pick from when the host resolves to more than one
+  const [choices, setChoices] = useState<string[]>([]);
+  const [comparisons, setComparisons] = useState<traceModels.PathComparison[] | null>(null);
+  const [isComparing, setIsComparing] = useState(false);
+  const {
+    startTrace,
+    resolveTarget,
+    traceAllAddresses,
+    cancelTrace,
+    clearTrace,
+    isRunning,
+    hasSession,
+  } = useTraceSession();
   const { showSubmarineCables, toggleSubmarineCables } = useTraceStore();
 
   // Reset cancelling state when trace stops
@@ -17,19 +32,49 @@ export function TraceInput() {
     if (!isRunning) setIsCancelling(false);
   }, [isRunning]);
 
+  const trace = async (host: string) => {
+    setChoices([]);
+    setComparisons(null);
+    setInputError(await startTrace(host));
+  };
+
+  const compareAll = async () => {
+    setChoices([]);
+    setIsComparing(true);
+    try {
+      setComparisons(await traceAllAddresses(target, false));
+    } catch (error) {
+      setInputError(String(error));
+    } finally {
+      setIsComparing(false);
+    }
+  };
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     if (isRunning) {
       setIsCancelling(true);
       await cancelTrace();
     } else {
-      startTrace(target);
+      try {
+        const resolved = await resolveTarget(target);
+        if (resolved.addresses.length > 1) {
+          setChoices(resolved.addresses);
+          return;
+        }
+        trace(resolved.host);
+      } catch (error) {
+        setInputError(String(error));
+      }
     }
   };
 
   const handleClear = () => {
     clearTrace();
     setTarget('tokyo.jp');
+    setChoices([]);
+    setComparisons(null);
+    setInputError(null);
   };
 
   return (
@@ -43,11 +88,54 @@ export function TraceInput() {
           type="text"
           placeholder="e.g., tokyo.jp or london.uk"
           value={target}
-          onChange={(e) => setTarget(e.target.value)}
+          onChange={(e) => {
+            setTarget(e.target.value);
+            setChoices([]);
+            setInputError(null);
+          }}
           disabled={isRunning}
           className="bg-background/50 focus:ring-2 focus:ring-primary/40 focus:border-primary/50 transition-all"
         />
+        {inputError && <p className="text-xs text-destructive">{inputError}</p>}
       </div>
+
+      {/* The host has several addresses; let the user pick one */}
+      {choices.length > 0 && (
+        <div className="space-y-1.5">
+          <p className="text-xs text-muted-foreground">
+            {target.trim()} has {choices.length} addresses. Trace:
+          </p>
+          <div className="flex flex-wrap gap-1.5">
+            <Button type="button" size="sm" variant="secondary" onClick={() => trace(target)}>
+              Any
+            </Button>
+            <Button type="button" size="sm" variant="secondary" onClick={compareAll}>
+              All, compared
+            </Button>
+            {choices.map((address) => (
+              <Button
+                key={address}
+                type="button"
+                size="sm"
+                variant="outline"
+                className="font-mono text-xs"
+                onClick={() => trace(address)}
+              >
+                {address}
+              </Button>
+            ))}
+          </div>
+        </div>
+      )}
+
+      {isComparing && (
+        <p className="flex items-center gap-2 text-xs text-muted-foreground">
+          <Loader2 className="h-3 w-3 animate-spin" />
+          Tracing every address...
+        </p>
+      )}
+      {comparisons && <AddressComparison comparisons={comparisons} />}
+
       <div className="flex gap-2">
         <Button
           type="submit"
@@ -96,6 +184,8 @@ export function TraceInput() {
         <span className="text-muted-foreground">Show submarine cables</span>
       </label>
 
+      <BackendSelect disabled={isRunning} />
+
       <p className="text-xs text-muted-foreground">
         Try "tokyo.jp" for SF→Tokyo or "london.uk" for NYC→London
       </p>
@@ -1,26 +1,60 @@
 import { useCallback } from 'react';
-import { StartTrace, CancelTrace } from '../../wailsjs/go/main/App';
+import {
+  StartTrace,
+  CancelTrace,
+  ResolveTarget,
+  TraceAllAddresses,
+  GetSessionSnapshot,
+} from '../../wailsjs/go/main/App';
+import { target as targetModels, trace as traceModels } from '../../wailsjs/go/models';
 import { useTraceStore } from '@/stores/traceStore';
-import { useWailsEvents } from './useWailsEvents';
+import { useWailsEvents, sessionFromSnapshot } from './useWailsEvents';
 
 export function useTraceSession() {
   // Subscribe to Wails events
   useWailsEvents();
 
-  const { session, selectedHopIndex, selectHop, reset } = useTraceStore();
+  const { session, selectedHopIndex, selectHop, reset, startSession, mergeSession } = useTraceStore();
 
-  const startTrace = useCallback(async (target: string) => {
-    if (!target.trim()) return;
+  // Returns the error message if the trace could not start
+  const startTrace = useCallback(async (target: string): Promise<string | null> => {
+    if (!target.trim()) return null;
+    let id: string;
     try {
-      await StartTrace(target.trim());
+      id = await StartTrace(target.trim());
     } catch (error) {
       console.error('Failed to start trace:', error);
+      return String(error);
     }
-  }, []);
+
+    // Show the new trace; events that arrived before its ID was known are in the snapshot
+    startSession(id, target.trim(), null);
+    try {
+      mergeSession(sessionFromSnapshot(await GetSessionSnapshot(id)));
+    } catch (error) {
+      console.error('Failed to load trace session:', error);
+    }
+    return null;
+  }, [startSession, mergeSession]);
+
+  // Validates a target and looks up every address it resolves to
+  const resolveTarget = useCallback(
+    async (target: string): Promise<targetModels.Target> => ResolveTarget(target.trim()),
+    []
+  );
+
+  // Traces every address of a target side by side; resolves once all have finished
+  const traceAllAddresses = useCallback(
+    async (target: string, bothFamilies: boolean): Promise<traceModels.PathComparison[]> =>
+      TraceAllAddresses(target.trim(), bothFamilies),
+    []
+  );
 
   const cancelTrace = useCallback(async () => {
+    const id = useTraceStore.getState().session?.id;
+    if (!id) return;
     try {
-      await CancelTrace();
+      await CancelTrace(id);
     } catch (error) {
       console.error('Failed to cancel trace:', error);
     }
@@ -35,6 +69,8 @@ export function useTraceSession() {
     selectedHopIndex,
     selectHop,
     startTrace,
+    resolveTarget,
+    traceAllAddresses,
     cancelTrace,
     clearTrace,
     isRunning: session?.status === 'running',
@@ -1,5 +1,7 @@
 import { useEffect } from 'react';
 import { EventsOn, EventsOff } from '../../wailsjs/runtime/runtime';
+import { GetSessionSnapshot, ListSessions } from '../../wailsjs/go/main/App';
+import { sessions } from '../../wailsjs/go/models';
 import { useTraceStore } from '@/stores/traceStore';
 import {
   TraceStartedEvent,
@@ -7,36 +9,129 @@ import {
   TraceCompletedEvent,
   TraceCancelledEvent,
   TraceErrorEvent,
+  TraceSession,
+  TraceStatus,
+  PathOutcome,
+  StopReason,
+  MPLSTunnel,
 } from '@/types';
 
+// Snapshot states map onto the store's statuses; "queued" shows as running
+const snapshotStatus: Record<string, TraceStatus> = {
+  queued: 'running',
+  running: 'running',
+  completed: 'completed',
+  cancelled: 'cancelled',
+  error: 'error',
+};
+
+// sessionFromSnapshot rebuilds the store's view of a session from a registry snapshot
+export function sessionFromSnapshot(snapshot: sessions.Snapshot): TraceSession {
+  return {
+    id: snapshot.id,
+    target: snapshot.target,
+    status: snapshotStatus[snapshot.state] ?? 'running',
+    startTime: snapshot.startedAt || snapshot.queuedAt,
+    endTime: snapshot.endedAt || undefined,
+    hops: snapshot.hops,
+    source: snapshot.source,
+    pathOutcome: snapshot.pathOutcome as PathOutcome | undefined,
+    stopReason: snapshot.stopReason as StopReason | undefined,
+    pathMtu: snapshot.pathMtu,
+    mplsTunnels: snapshot.mplsTunnels as MPLSTunnel[] | undefined,
+    error: snapshot.error,
+  };
+}
+
 export function useWailsEvents() {
-  const { startSession, addHop, completeSession, cancelSession, setError } =
+  const { startSession, restoreSession, addHop, completeSession, cancelSession, setError } =
     useTraceStore();
 
   useEffect(() => {
+    // Several traces may run at once; only follow the one shown
+    const isCurrent = (sessionId: string) =>
+      useTraceStore.getState().session?.id === sessionId;
+
+    // Events are applied once, in sequence order. Until the snapshot of a
+    // trace that was running before a reload arrives, they are held back
+    let lastSeq = 0;
+    let recovering = true;
+    const pending: Array<{ seq: number; apply: () => void }> = [];
+    const handle = (seq: number, apply: () => void) => {
+      if (recovering) {
+        pending.push({ seq, apply });
+        return;
+      }
+      if (seq <= lastSeq) return;
+      lastSeq = seq;
+      apply();
+    };
+
     // Subscribe to trace events
-    const unsubStarted = EventsOn('trace:started', (data: TraceStartedEvent) => {
-      console.log('trace:started', data);
-      startSession(data.sessionId, data.target, data.source);
-    });
+    const unsubStarted = EventsOn('trace:started', (data: TraceStartedEvent) =>
+      handle(data.seq, () => {
+        // Queued traces and every address of a trace-all start on their own;
+        // only take over the view when nothing is shown. Traces this view
+        // starts are followed by useTraceSession once their ID is known
+        const current = useTraceStore.getState().session;
+        if (current && current.id !== data.sessionId) return;
+        console.log('trace:started', data);
+        startSession(data.sessionId, data.target, data.source);
+      })
+    );
+
+    const unsubHop = EventsOn('trace:hop', (data: TraceHopEvent) =>
+      handle(data.seq, () => {
+        if (!isCurrent(data.sessionId)) return;
+        console.log('trace:hop', data.hop.hopNumber, data.hop.ipAddress);
+        addHop(data.hop);
+      })
+    );
 
-    const unsubHop = EventsOn('trace:hop', (data: TraceHopEvent) => {
-      console.log('trace:hop', data.hop.hopNumber, data.hop.ipAddress);
-      addHop(data.hop);
-    });
+    const unsubCompleted = EventsOn('trace:completed', (data: TraceCompletedEvent) =>
+      handle(data.seq, () => {
+        if (!isCurrent(data.sessionId)) return;
+        console.log('trace:completed', data);
+        completeSession(data.totalHops, data.outcome, data.reason, data.pathMtu, data.mplsTunnels);
+      })
+    );
 
-    const unsubCompleted = EventsOn('trace:completed', (data: TraceCompletedEvent) => {
-      console.log('trace:completed', data);
-      completeSession(data.totalHops);
-    });
+    const unsubCancelled = EventsOn('trace:cancelled', (data: TraceCancelledEvent) =>
+      handle(data.seq, () => {
+        if (!isCurrent(data.sessionId)) return;
+        cancelSession();
+      })
+    );
 
-    const unsubCancelled = EventsOn('trace:cancelled', (data: TraceCancelledEvent) => {
-      cancelSession();
-    });
+    const unsubError = EventsOn('trace:error', (data: TraceErrorEvent) =>
+      handle(data.seq, () => {
+        if (!isCurrent(data.sessionId)) return;
+        setError(data.error, data.code, data.remediation);
+      })
+    );
 
-    const unsubError = EventsOn('trace:error', (data: TraceErrorEvent) => {
-      setError(data.error);
-    });
+    // Rebuild the store from a trace that was already running, e.g. after a reload
+    const recover = async () => {
+      try {
+        if (!useTraceStore.getState().session) {
+          const running = (await ListSessions()).filter((s) => s.state === 'running');
+          const latest = running[running.length - 1];
+          if (latest) {
+            const snapshot = await GetSessionSnapshot(latest.id);
+            restoreSession(sessionFromSnapshot(snapshot));
+            lastSeq = snapshot.seq;
+          }
+        }
+      } catch (error) {
+        console.error('Failed to recover trace session:', error);
+      }
+
+      recovering = false;
+      for (const { seq, apply } of pending.splice(0)) {
+        handle(seq, apply);
+      }
+    };
+    recover();
 
     // Cleanup on unmount
     return () => {
@@ -46,5 +141,5 @@ export function useWailsEvents() {
       EventsOff('trace:cancelled');
       EventsOff('trace:error');
     };
-  }, [startSession, addHop, completeSession, cancelSession, setError]);
+  }, [startSession, restoreSession, addHop, completeSession, cancelSession, setError]);
 }
@@ -1,19 +1,26 @@
 import { create } from 'zustand';
-import { TraceSession, Hop, GeoLocation, TraceStatus } from '@/types';
+import { TraceSession, Hop, GeoLocation, TraceStatus, PathOutcome, StopReason, MPLSTunnel } from '@/types';
 
 interface TraceState {
   session: TraceSession | null;
   selectedHopIndex: number | null;
   showSubmarineCables: boolean;
   showLatencyHeatmap: boolean;
-  consecutiveTimeouts: number;
 
   // Actions
-  startSession: (id: string, target: string, source: GeoLocation) => void;
+  startSession: (id: string, target: string, source: GeoLocation | null) => void;
+  restoreSession: (session: TraceSession) => void;
+  mergeSession: (session: TraceSession) => void;
   addHop: (hop: Hop) => void;
-  completeSession: (totalHops: number) => void;
+  completeSession: (
+    totalHops: number,
+    pathOutcome?: PathOutcome,
+    stopReason?: StopReason,
+    pathMtu?: number,
+    mplsTunnels?: MPLSTunnel[]
+  ) => void;
   cancelSession: () => void;
-  setError: (error: string) => void;
+  setError: (error: string, errorCode?: string, remediation?: string) => void;
   selectHop: (index: number | null) => void;
   reset: () => void;
   toggleSubmarineCables: () => void;
@@ -34,13 +41,13 @@ export const useTraceStore = create<TraceState>((set) => ({
   selectedHopIndex: null,
   showSubmarineCables: true,
   showLatencyHeatmap: false,
-  consecutiveTimeouts: 0,
 
   startSession: (id, target, source) =>
     set((state) => {
-      // Prevent duplicate session starts (React StrictMode)
+      // Prevent duplicate session starts (React StrictMode, or a trace this view followed first)
       if (state.session?.id === id) {
-        return state;
+        if (state.session.source || !source) return state;
+        return { session: { ...state.session, source } };
       }
       return {
         session: {
@@ -52,7 +59,32 @@ export const useTraceStore = create<TraceState>((set) => ({
           source,
         },
         selectedHopIndex: null,
-        consecutiveTimeouts: 0,
+      };
+    }),
+
+  restoreSession: (session) =>
+    set({
+      session,
+      selectedHopIndex: session.hops.length > 0 ? session.hops.length - 1 : null,
+    }),
+
+  // Fills in what a followed session missed before it was shown, keeping
+  // hops and the final status that events have delivered since
+  mergeSession: (restored) =>
+    set((state) => {
+      const live = state.session;
+      if (!live || live.id !== restored.id) return state;
+      const hops = [...restored.hops];
+      for (const hop of live.hops) {
+        if (!hops.some(h => h.hopNumber === hop.hopNumber)) {
+          hops.push(hop);
+        }
+      }
+      hops.sort((a, b) => a.hopNumber - b.hopNumber);
+      const base = live.status === 'running' ? restored : { ...restored, ...live };
+      return {
+        session: { ...base, source: live.source ?? restored.source, hops },
+        selectedHopIndex: hops.length > 0 ? hops.length - 1 : null,
       };
     }),
 
@@ -70,11 +102,10 @@ export const useTraceStore = create<TraceState>((set) => ({
         },
         // Auto-select the latest hop
         selectedHopIndex: state.session.hops.length,
-        consecutiveTimeouts: hop.isTimeout ? state.consecutiveTimeouts + 1 : 0,
       };
     }),
 
-  completeSession: (totalHops) =>
+  completeSession: (totalHops, pathOutcome, stopReason, pathMtu, mplsTunnels) =>
     set((state) => {
       if (!state.session) return state;
       return {
@@ -83,6 +114,10 @@ export const useTraceStore = create<TraceState>((set) => ({
           status: 'completed',
           endTime: Date.now(),
           totalHops,
+          pathOutcome,
+          stopReason,
+          pathMtu,
+          mplsTunnels,
         },
       };
     }),
@@ -99,7 +134,7 @@ export const useTraceStore = create<TraceState>((set) => ({
       };
     }),
 
-  setError: (error) =>
+  setError: (error, errorCode, remediation) =>
     set((state) => {
       if (!state.session) return state;
       return {
@@ -107,6 +142,8 @@ export const useTraceStore = create<TraceState>((set) => ({
           ...state.session,
           status: 'error',
           error,
+          errorCode,
+          remediation,
           endTime: Date.now(),
         },
       };
@@ -114,7 +151,7 @@ export const useTraceStore = create<TraceState>((set) => ({
 
   selectHop: (index) => set({ selectedHopIndex: index }),
 
-  reset: () => set({ session: null, selectedHopIndex: null, consecutiveTimeouts: 0 }),
+  reset: () => set({ session: null, selectedHopIndex: null }),
 
   toggleSubmarineCables: () =>
     set((state) => ({
@@ -1,32 +1,60 @@
 import { GeoLocation } from './geo';
-import { Hop } from './trace';
+import { Hop, MPLSTunnel, PathOutcome, StopReason } from './trace';
 
-export interface TraceStartedEvent {
+// Every event carries the bus sequence number it was published with
+interface SequencedEvent {
+  seq: number;
+}
+
+export interface TraceStartedEvent extends SequencedEvent {
   sessionId: string;
   target: string;
   source: GeoLocation;
   timestamp: number;
 }
 
-export interface TraceHopEvent {
+export interface TraceQueuedEvent extends SequencedEvent {
+  sessionId: string;
+  target: string;
+  position: number;
+  timestamp: number;
+}
+
+export interface TraceHopEvent extends SequencedEvent {
   sessionId: string;
   hop: Hop;
 }
 
-export interface TraceCompletedEvent {
+export interface TraceCompletedEvent extends SequencedEvent {
   sessionId: string;
   totalHops: number;
+  outcome: PathOutcome;
+  reason: StopReason;
+  pathMtu?: number;
+  mplsTunnels?: MPLSTunnel[];
   timestamp: number;
 }
 
-export interface TraceCancelledEvent {
+export interface TraceCancelledEvent extends SequencedEvent {
   sessionId: string;
   timestamp: number;
 }
 
-export interface TraceErrorEvent {
+// Stable codes of classified trace failures
+export type TraceErrorCode =
+  | 'binary_not_found'
+  | 'permission_denied'
+  | 'unknown_host'
+  | 'invalid_target'
+  | 'network_unreachable'
+  | 'deadline_exceeded'
+  | 'tool_failed';
+
+export interface TraceErrorEvent extends SequencedEvent {
   sessionId: string;
   error: string;
+  code: TraceErrorCode;
+  remediation?: string;
   timestamp: number;
 }
 
@@ -16,10 +16,56 @@ export interface Hop {
   isTimeout: boolean;
   isDestination: boolean;
   timestamp: number;
+  mtu?: number; // Path MTU in effect at this hop (tracepath)
+  returnHops?: number; // Hops the reply took back, when it differs from the way out
+  unreachable?: Unreachable; // Set when the router reported the destination unreachable
+  mpls?: MPLSLabel[]; // Label stack the router quoted, top first
+  asn?: string; // AS announcing the hop's address, e.g. "AS15169"
+  asnConflicts?: ASNClaim[]; // AS numbers other sources or routers gave that differ from asn
 }
 
+// An AS number one source gave for a hop
+export interface ASNClaim {
+  asn: string;
+  source: 'traceroute' | 'geo';
+  ip?: string; // Another router that answered a probe for the same hop
+}
+
+// One entry of an MPLS label stack (RFC 4950)
+export interface MPLSLabel {
+  label: number;
+  trafficClass: number;
+  bottomOfStack: boolean;
+  ttl: number;
+}
+
+// A run of hops inside one MPLS tunnel. Visible tunnels quote their labels;
+// hidden ones are inferred from hops with near-identical RTTs
+export interface MPLSTunnel {
+  kind: 'visible' | 'hidden';
+  firstHop: number;
+  lastHop: number;
+  labels?: number[];
+}
+
+// Why a router reported the destination unreachable (traceroute's !H, !N, !X, ...)
+export type Unreachable =
+  | 'host_unreachable'
+  | 'network_unreachable'
+  | 'protocol_unreachable'
+  | 'admin_prohibited'
+  | 'network_prohibited'
+  | 'host_prohibited'
+  | 'unreachable';
+
 export type TraceStatus = 'idle' | 'running' | 'completed' | 'error' | 'cancelled';
 
+// How far a completed trace got
+export type PathOutcome = 'reached' | 'partial' | 'unreachable' | 'prohibited';
+
+// Why a completed trace stopped
+export type StopReason = 'destination' | 'max_hops' | 'trailing_timeouts' | 'deadline' | 'unreachable';
+
 export interface TraceSession {
   id: string;
   target: string;
@@ -29,5 +75,11 @@ export interface TraceSession {
   hops: Hop[];
   source: GeoLocation | null;
   totalHops?: number;
+  pathOutcome?: PathOutcome;
+  stopReason?: StopReason;
+  pathMtu?: number;
+  mplsTunnels?: MPLSTunnel[];
   error?: string;
+  errorCode?: string;
+  remediation?: string;
 }
@@ -1,11 +1,28 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {cables} from '../models';
+import {sessions} from '../models';
+import {target} from '../models';
+import {trace} from '../models';
 
-export function CancelTrace():Promise<void>;
+export function CancelAll():Promise<void>;
+
+export function CancelTrace(arg1:string):Promise<void>;
+
+export function GetCapabilities():Promise<trace.Capabilities>;
+
+export function GetSessionSnapshot(arg1:string):Promise<sessions.Snapshot>;
 
 export function GetSubmarineCables():Promise<Array<cables.Cable>>;
 
 export function GetTraceStatus():Promise<boolean>;
 
+export function ListSessions():Promise<Array<sessions.Info>>;
+
+export function ResolveTarget(arg1:string):Promise<target.Target>;
+
+export function SetTraceBackend(arg1:string):Promise<void>;
+
 export function StartTrace(arg1:string):Promise<string>;
+
+export function TraceAllAddresses(arg1:string,arg2:boolean):Promise<Array<trace.PathComparison>>;
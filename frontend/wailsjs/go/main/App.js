@@ -2,8 +2,20 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function CancelTrace() {
-  return window['go']['main']['App']['CancelTrace']();
+export function CancelAll() {
+  return window['go']['main']['App']['CancelAll']();
+}
+
+export function CancelTrace(arg1) {
+  return window['go']['main']['App']['CancelTrace'](arg1);
+}
+
+export function GetCapabilities() {
+  return window['go']['main']['App']['GetCapabilities']();
+}
+
+export function GetSessionSnapshot(arg1) {
+  return window['go']['main']['App']['GetSessionSnapshot'](arg1);
 }
 
 export function GetSubmarineCables() {
@@ -14,6 +26,22 @@ export function GetTraceStatus() {
   return window['go']['main']['App']['GetTraceStatus']();
 }
 
+export function ListSessions() {
+  return window['go']['main']['App']['ListSessions']();
+}
+
+export function ResolveTarget(arg1) {
+  return window['go']['main']['App']['ResolveTarget'](arg1);
+}
+
+export function SetTraceBackend(arg1) {
+  return window['go']['main']['App']['SetTraceBackend'](arg1);
+}
+
 export function StartTrace(arg1) {
   return window['go']['main']['App']['StartTrace'](arg1);
 }
+
+export function TraceAllAddresses(arg1, arg2) {
+  return window['go']['main']['App']['TraceAllAddresses'](arg1, arg2);
+}
@@ -21,3 +21,276 @@ export namespace cables {
 
 }
 
+export namespace sessions {
+	
+	export class Info {
+	    id: string;
+	    target: string;
+	    options: any;
+	    state: string;
+	    queuePosition?: number;
+	    queuedAt: number;
+	    startedAt?: number;
+	    endedAt?: number;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Info(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.target = source["target"];
+	        this.options = source["options"];
+	        this.state = source["state"];
+	        this.queuePosition = source["queuePosition"];
+	        this.queuedAt = source["queuedAt"];
+	        this.startedAt = source["startedAt"];
+	        this.endedAt = source["endedAt"];
+	        this.error = source["error"];
+	    }
+	}
+	export class Snapshot {
+	    id: string;
+	    target: string;
+	    options: any;
+	    state: string;
+	    queuePosition?: number;
+	    queuedAt: number;
+	    startedAt?: number;
+	    endedAt?: number;
+	    error?: string;
+	    source: any;
+	    hops: any[];
+	    outcome?: string;
+	    seq: number;
+	    pathOutcome?: string;
+	    stopReason?: string;
+	    pathMtu?: number;
+	    mplsTunnels?: trace.MPLSTunnel[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Snapshot(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.target = source["target"];
+	        this.options = source["options"];
+	        this.state = source["state"];
+	        this.queuePosition = source["queuePosition"];
+	        this.queuedAt = source["queuedAt"];
+	        this.startedAt = source["startedAt"];
+	        this.endedAt = source["endedAt"];
+	        this.error = source["error"];
+	        this.source = source["source"];
+	        this.hops = source["hops"];
+	        this.outcome = source["outcome"];
+	        this.seq = source["seq"];
+	        this.pathOutcome = source["pathOutcome"];
+	        this.stopReason = source["stopReason"];
+	        this.pathMtu = source["pathMtu"];
+	        this.mplsTunnels = this.convertValues(source["mplsTunnels"], trace.MPLSTunnel);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace target {
+	
+	export class Target {
+	    input: string;
+	    host: string;
+	    unicode?: string;
+	    port?: number;
+	    isLiteral: boolean;
+	    addresses: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Target(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.input = source["input"];
+	        this.host = source["host"];
+	        this.unicode = source["unicode"];
+	        this.port = source["port"];
+	        this.isLiteral = source["isLiteral"];
+	        this.addresses = source["addresses"];
+	    }
+	}
+
+}
+
+export namespace trace {
+	
+	export class AddressPath {
+	    address: string;
+	    sessionId: string;
+	    outcome: string;
+	    reached: boolean;
+	    hops: number;
+	    endToEndMs: number;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AddressPath(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.address = source["address"];
+	        this.sessionId = source["sessionId"];
+	        this.outcome = source["outcome"];
+	        this.reached = source["reached"];
+	        this.hops = source["hops"];
+	        this.endToEndMs = source["endToEndMs"];
+	        this.error = source["error"];
+	    }
+	}
+	export class BackendInfo {
+	    name: string;
+	    path?: string;
+	    supported: boolean;
+	    usable: boolean;
+	    reason?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BackendInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.path = source["path"];
+	        this.supported = source["supported"];
+	        this.usable = source["usable"];
+	        this.reason = source["reason"];
+	    }
+	}
+	export class Capabilities {
+	    os: string;
+	    backends: BackendInfo[];
+	    selected?: string;
+	    root: boolean;
+	    capNetRaw: boolean;
+	    rawIcmp: boolean;
+	    unprivilegedIcmp: boolean;
+	    pingGroupRange: number[];
+	    guidance?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Capabilities(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.os = source["os"];
+	        this.backends = this.convertValues(source["backends"], BackendInfo);
+	        this.selected = source["selected"];
+	        this.root = source["root"];
+	        this.capNetRaw = source["capNetRaw"];
+	        this.rawIcmp = source["rawIcmp"];
+	        this.unprivilegedIcmp = source["unprivilegedIcmp"];
+	        this.pingGroupRange = source["pingGroupRange"];
+	        this.guidance = source["guidance"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MPLSTunnel {
+	    kind: string;
+	    firstHop: number;
+	    lastHop: number;
+	    labels?: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new MPLSTunnel(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.firstHop = source["firstHop"];
+	        this.lastHop = source["lastHop"];
+	        this.labels = source["labels"];
+	    }
+	}
+	export class PathComparison {
+	    target: string;
+	    family: number;
+	    paths: AddressPath[];
+	    sharedHops: any[];
+	    divergeHop: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PathComparison(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.target = source["target"];
+	        this.family = source["family"];
+	        this.paths = this.convertValues(source["paths"], AddressPath);
+	        this.sharedHops = source["sharedHops"];
+	        this.divergeHop = source["divergeHop"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
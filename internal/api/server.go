@@ -0,0 +1,455 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"packet-painter/internal/events"
+	"packet-painter/internal/history"
+	"packet-painter/internal/sessions"
+	"packet-painter/internal/target"
+	"packet-painter/internal/trace"
+)
+
+// Default limits applied when the config leaves them unset
+const (
+	DefaultMaxPerClient = 2
+	DefaultTraceTimeout = 2 * time.Minute
+)
+
+// History is the subset of the history store the API needs
+type History interface {
+	Save(result *trace.Result) error
+	List(filter history.Filter) []history.Summary
+	Get(id string) (*trace.Result, error)
+}
+
+// SessionFactory creates the session for a trace request
+type SessionFactory func(target string, opts trace.Options) *trace.Session
+
+// Config controls the API server
+type Config struct {
+	Token          string                     // Required bearer token; empty disables auth
+	AllowedOrigins []string                   // CORS origins, "*" allows any
+	MaxPerClient   int                        // Queued and running traces allowed per client address
+	TraceTimeout   time.Duration              // Upper bound on a single trace
+	Sessions       *sessions.Registry         // Optional; shared with other callers. Nil creates one the server owns
+	Limits         sessions.Limits            // Applied to the registry the server creates
+	History        History                    // Optional; finished traces are saved here
+	NewSession     SessionFactory             // Defaults to trace.NewSessionWithOptions
+	Resolver       target.Resolver            // Used by /api/resolve; nil for the system resolver
+	OnResult       func(result *trace.Result) // Optional; called with every finished trace
+}
+
+// StartRequest is the body of POST /api/traces
+type StartRequest struct {
+	Target  string        `json:"target"`
+	Options trace.Options `json:"options"`
+}
+
+// SessionResponse describes a running or finished session
+type SessionResponse struct {
+	*trace.Result
+	Running bool `json:"running"`
+}
+
+// errorResponse is the body of every error reply
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server serves the REST API and event stream
+// Traces run on a sessions.Registry; the server only adds per-client quotas
+// and saves what finishes
+type Server struct {
+	cfg      Config
+	mux      *http.ServeMux
+	bus      *events.Bus
+	registry *sessions.Registry
+	owned    bool // The server created the registry and its bus, and closes them
+	mu       sync.Mutex
+	clients  map[string]string // Client address of each API session until its result is saved
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// New creates a server with the given config
+func New(cfg Config) *Server {
+	if cfg.MaxPerClient <= 0 {
+		cfg.MaxPerClient = DefaultMaxPerClient
+	}
+	if cfg.TraceTimeout <= 0 {
+		cfg.TraceTimeout = DefaultTraceTimeout
+	}
+	if cfg.NewSession == nil {
+		cfg.NewSession = trace.NewSessionWithOptions
+	}
+
+	s := &Server{
+		cfg:      cfg,
+		mux:      http.NewServeMux(),
+		registry: cfg.Sessions,
+		clients:  make(map[string]string),
+	}
+	if s.registry == nil {
+		s.registry = sessions.New(events.NewBus())
+		s.registry.SetLimits(cfg.Limits)
+		s.owned = true
+	}
+	s.bus = s.registry.Bus()
+
+	s.mux.HandleFunc("POST /api/traces", s.handleStart)
+	s.mux.HandleFunc("GET /api/traces/{id}", s.handleGet)
+	s.mux.HandleFunc("DELETE /api/traces/{id}", s.handleCancel)
+	s.mux.HandleFunc("POST /api/traces/{id}/cancel", s.handleCancel)
+	s.mux.HandleFunc("GET /api/history", s.handleHistory)
+	s.mux.HandleFunc("GET /api/resolve", s.handleResolve)
+	s.mux.HandleFunc("GET /api/capabilities", s.handleCapabilities)
+	s.mux.HandleFunc("GET /api/events", s.handleEvents)
+	return s
+}
+
+// ServeHTTP applies CORS and auth before routing
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.applyCORS(w, r)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="packet-painter"`)
+		writeError(w, http.StatusUnauthorized, "missing or invalid token")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Handle registers an extra handler behind the same auth and CORS checks
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Publish sends an event that is not tied to an API session to stream clients
+// Used for scheduler events such as scheduler.RouteChangedEvent
+func (s *Server) Publish(payload events.Payload) {
+	s.bus.Publish(payload)
+}
+
+// Sessions returns the registry API traces run on
+func (s *Server) Sessions() *sessions.Registry {
+	return s.registry
+}
+
+// Close cancels the traces started through the API and waits for their
+// results to be saved. A registry the server created is closed with it,
+// which ends all event streams
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	ids := make([]string, 0, len(s.clients))
+	for id := range s.clients {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	if s.owned {
+		s.registry.Close()
+	} else {
+		for _, id := range ids {
+			s.registry.Cancel(id)
+		}
+	}
+	s.wg.Wait()
+	if s.owned {
+		s.bus.Close()
+	}
+}
+
+// applyCORS sets the CORS headers when the request origin is allowed
+func (s *Server) applyCORS(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	for _, allowed := range s.cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Add("Vary", "Origin")
+			return
+		}
+	}
+}
+
+// authorized checks the bearer token, which may also be passed as ?token= for EventSource clients
+func (s *Server) authorized(r *http.Request) bool {
+	if s.cfg.Token == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) == 1
+}
+
+// handleStart starts a trace and returns its session
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	var req StartRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	// The trace tool resolves the host itself; it only ever sees a validated one
+	parsed, err := target.Parse(req.Target)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Options.Backend, err = trace.ParseBackend(string(req.Options.Backend)); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	session := s.cfg.NewSession(parsed.Host, req.Options)
+	policy := session.DeadlinePolicy()
+	if policy.Max <= 0 || policy.Max > s.cfg.TraceTimeout {
+		policy.Max = s.cfg.TraceTimeout
+		session.SetDeadlinePolicy(policy)
+	}
+
+	client := clientAddress(r)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	if s.activeLocked(client) >= s.cfg.MaxPerClient {
+		s.mu.Unlock()
+		writeError(w, http.StatusTooManyRequests, "too many running traces, limit is "+strconv.Itoa(s.cfg.MaxPerClient))
+		return
+	}
+	if _, err := s.registry.Start(session); err != nil {
+		s.mu.Unlock()
+		switch {
+		case errors.Is(err, sessions.ErrLimitReached):
+			writeError(w, http.StatusTooManyRequests, err.Error())
+		case errors.Is(err, sessions.ErrClosed):
+			writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	s.clients[session.ID] = client
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go s.save(session.ID)
+
+	response, err := s.describe(r.Context(), session.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+// activeLocked counts a client's queued and running traces
+// Must be called with s.mu held
+func (s *Server) activeLocked(client string) int {
+	var n int
+	for id, owner := range s.clients {
+		if owner != client {
+			continue
+		}
+		if info, err := s.registry.Get(id); err == nil && (info.State == sessions.StateQueued || info.State == sessions.StateRunning) {
+			n++
+		}
+	}
+	return n
+}
+
+// save waits for an API trace to finish and hands its result to the history store and observer
+func (s *Server) save(id string) {
+	defer s.wg.Done()
+
+	// Run records any error in the result, which is what clients see
+	result, err := s.registry.Wait(context.Background(), id)
+	if err == nil {
+		if s.cfg.History != nil {
+			if err := s.cfg.History.Save(result); err != nil {
+				println("Failed to save trace:", err.Error())
+			}
+		}
+		if s.cfg.OnResult != nil {
+			s.cfg.OnResult(result)
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.clients, id)
+	s.mu.Unlock()
+}
+
+// describe builds the response for a session the registry knows about
+func (s *Server) describe(ctx context.Context, id string) (SessionResponse, error) {
+	snapshot, err := s.registry.Snapshot(id)
+	if err != nil {
+		return SessionResponse{}, err
+	}
+	if snapshot.State == sessions.StateQueued || snapshot.State == sessions.StateRunning {
+		return SessionResponse{
+			Result: &trace.Result{
+				SessionID: snapshot.ID,
+				Target:    snapshot.Target,
+				Options:   snapshot.Options,
+				Source:    snapshot.Source,
+				Hops:      snapshot.Hops,
+				StartedAt: snapshot.StartedAt,
+			},
+			Running: true,
+		}, nil
+	}
+
+	// Finished sessions stay in the registry for a while; Wait returns at once
+	result, err := s.registry.Wait(ctx, id)
+	if err != nil {
+		return SessionResponse{}, err
+	}
+	return SessionResponse{Result: result}, nil
+}
+
+// handleGet returns a session from the registry or a finished trace from history
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	response, err := s.describe(r.Context(), id)
+	if err == nil {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	if !errors.Is(err, sessions.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if s.cfg.History != nil {
+		result, err := s.cfg.History.Get(id)
+		if err == nil {
+			writeJSON(w, http.StatusOK, SessionResponse{Result: result})
+			return
+		}
+		if !errors.Is(err, history.ErrNotFound) {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "session not found")
+}
+
+// handleCancel cancels a queued or running session
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	// Cancel returns once trace:cancelled has been published
+	if err := s.registry.Cancel(id); err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	response, err := s.describe(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleResolve validates a target and returns every address it resolves to
+func (s *Server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	resolved, err := target.Resolve(r.Context(), s.cfg.Resolver, r.URL.Query().Get("target"))
+	if err != nil {
+		status := http.StatusBadRequest
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) || errors.Is(err, target.ErrNoAddresses) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, resolved)
+}
+
+// handleCapabilities reports which trace backends and probe types this host supports
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, trace.DetectCapabilities())
+}
+
+// handleHistory lists saved traces, filtered by query parameters
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.History == nil {
+		writeError(w, http.StatusNotImplemented, "trace history is unavailable")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := history.Filter{
+		Target:  query.Get("target"),
+		Outcome: trace.Outcome(query.Get("outcome")),
+	}
+	for name, dest := range map[string]*int64{"from": &filter.From, "to": &filter.To} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*dest = n
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+
+	summaries := s.cfg.History.List(filter)
+	if summaries == nil {
+		summaries = []history.Summary{}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// clientAddress identifies a client by its remote IP for the concurrency limit
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,341 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"packet-painter/internal/events"
+	"packet-painter/internal/geo"
+	"packet-painter/internal/history"
+	"packet-painter/internal/sessions"
+	"packet-painter/internal/target"
+	"packet-painter/internal/trace"
+)
+
+const testToken = "secret"
+
+// fakeRunner replays hops, optionally waiting for release or cancellation before completing
+type fakeRunner struct {
+	hops    []*trace.Hop
+	release chan struct{}
+}
+
+func (r *fakeRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop trace.HopCallback, onComplete trace.CompletedCallback, onError trace.ErrorCallback) error {
+	for _, hop := range r.hops {
+		onHop(hop)
+	}
+	if r.release != nil {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	onComplete(len(r.hops))
+	return nil
+}
+
+// memoryHistory keeps results in memory
+type memoryHistory struct {
+	mu      sync.Mutex
+	results []*trace.Result
+}
+
+func (m *memoryHistory) Save(result *trace.Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results = append(m.results, result)
+	return nil
+}
+
+func (m *memoryHistory) List(filter history.Filter) []history.Summary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var summaries []history.Summary
+	for _, result := range m.results {
+		summaries = append(summaries, history.Summary{ID: result.SessionID, Target: result.Target, Outcome: result.Outcome})
+	}
+	return summaries
+}
+
+func (m *memoryHistory) Get(id string) (*trace.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, result := range m.results {
+		if result.SessionID == id {
+			return result, nil
+		}
+	}
+	return nil, history.ErrNotFound
+}
+
+func newTestServer(t *testing.T, runner trace.Runner, cfg Config) (*httptest.Server, *memoryHistory) {
+	t.Helper()
+	store := &memoryHistory{}
+	cfg.Token = testToken
+	cfg.History = store
+	cfg.NewSession = func(target string, opts trace.Options) *trace.Session {
+		return trace.NewSessionWithRunner(target, opts, runner)
+	}
+	api := New(cfg)
+	ts := httptest.NewServer(api)
+	t.Cleanup(func() {
+		ts.Close()
+		api.Close()
+	})
+	return ts, store
+}
+
+func request(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decode(t *testing.T, resp *http.Response, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+}
+
+func TestTraceLifecycle(t *testing.T) {
+	release := make(chan struct{})
+	runner := &fakeRunner{
+		hops: []*trace.Hop{
+			{HopNumber: 1, IPAddress: "192.168.1.1", AvgRTT: 1},
+			{HopNumber: 2, IPAddress: "8.8.8.8", AvgRTT: 10, IsDestination: true},
+		},
+		release: release,
+	}
+	ts, store := newTestServer(t, runner, Config{})
+
+	resp := request(t, http.MethodPost, ts.URL+"/api/traces", `{"target": "8.8.8.8", "options": {"maxHops": 10}}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("start status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	var started SessionResponse
+	decode(t, resp, &started)
+	if started.SessionID == "" || !started.Running || started.Options.MaxHops != 10 {
+		t.Fatalf("start response = %+v", started)
+	}
+
+	// Subscribing after the start replays what already happened
+	stream, err := http.Get(ts.URL + "/api/events?session=" + started.SessionID + "&token=" + testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("stream Content-Type = %s", ct)
+	}
+	close(release)
+
+	var names []string
+	var completed trace.TraceCompletedEvent
+	scanner := bufio.NewScanner(stream.Body)
+	name := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+			names = append(names, name)
+		case strings.HasPrefix(line, "data: ") && name == "trace:completed":
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &completed)
+		}
+	}
+	expected := []string{"trace:started", "trace:hop", "trace:hop", "trace:completed"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("events = %v, want %v", names, expected)
+	}
+	if completed.SessionID != started.SessionID || completed.TotalHops != 2 {
+		t.Errorf("completed event = %+v", completed)
+	}
+
+	// Finished traces are served from history
+	var session SessionResponse
+	decode(t, request(t, http.MethodGet, ts.URL+"/api/traces/"+started.SessionID, ""), &session)
+	if session.Running || session.Outcome != trace.OutcomeCompleted || len(session.Hops) != 2 {
+		t.Errorf("session = %+v, want completed with 2 hops", session)
+	}
+
+	var summaries []history.Summary
+	decode(t, request(t, http.MethodGet, ts.URL+"/api/history", ""), &summaries)
+	if len(summaries) != 1 || summaries[0].ID != started.SessionID {
+		t.Errorf("history = %+v", summaries)
+	}
+	if len(store.results) != 1 {
+		t.Errorf("saved %d results, want 1", len(store.results))
+	}
+
+	if resp := request(t, http.MethodGet, ts.URL+"/api/traces/missing", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing session status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestConcurrencyLimitAndCancel(t *testing.T) {
+	runner := &fakeRunner{release: make(chan struct{})} // Never released
+	ts, _ := newTestServer(t, runner, Config{MaxPerClient: 2})
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		resp := request(t, http.MethodPost, ts.URL+"/api/traces", `{"target": "example.com"}`)
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("start %d status = %d", i, resp.StatusCode)
+		}
+		var session SessionResponse
+		decode(t, resp, &session)
+		ids = append(ids, session.SessionID)
+	}
+
+	if resp := request(t, http.MethodPost, ts.URL+"/api/traces", `{"target": "example.com"}`); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("third start status = %d, want 429", resp.StatusCode)
+	}
+
+	resp := request(t, http.MethodDelete, ts.URL+"/api/traces/"+ids[0], "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("cancel status = %d", resp.StatusCode)
+	}
+	var cancelled SessionResponse
+	decode(t, resp, &cancelled)
+	if cancelled.Running || cancelled.Outcome != trace.OutcomeCancelled {
+		t.Errorf("cancelled session = %+v", cancelled)
+	}
+
+	// The slot is free again once the cancelled trace has finished
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp := request(t, http.MethodPost, ts.URL+"/api/traces", `{"target": "example.com"}`)
+		if resp.StatusCode == http.StatusAccepted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("start after cancel status = %d", resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSharedRegistry(t *testing.T) {
+	bus := events.NewBus()
+	registry := sessions.New(bus)
+	registry.SetLimits(sessions.Limits{MaxConcurrent: 1})
+	t.Cleanup(func() {
+		registry.Close()
+		bus.Close()
+	})
+	runner := &fakeRunner{release: make(chan struct{})} // Never released
+	ts, _ := newTestServer(t, runner, Config{Sessions: registry, MaxPerClient: 5})
+
+	resp := request(t, http.MethodPost, ts.URL+"/api/traces", `{"target": "example.com"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("start status = %d", resp.StatusCode)
+	}
+	var started SessionResponse
+	decode(t, resp, &started)
+	if info, err := registry.Get(started.SessionID); err != nil || info.State != sessions.StateRunning {
+		t.Fatalf("registry.Get() = %+v, %v; want running", info, err)
+	}
+
+	// The registry's own limit applies below the per-client quota
+	if resp := request(t, http.MethodPost, ts.URL+"/api/traces", `{"target": "example.com"}`); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("start beyond registry limit status = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestAuthAndCORS(t *testing.T) {
+	ts, _ := newTestServer(t, &fakeRunner{}, Config{AllowedOrigins: []string{"https://noc.example.com"}})
+
+	resp, err := http.Post(ts.URL+"/api/traces", "application/json", bytes.NewBufferString(`{"target": "example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", resp.StatusCode)
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://noc.example.com", true},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodOptions, ts.URL+"/api/traces", nil)
+		req.Header.Set("Origin", tt.origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		got := resp.Header.Get("Access-Control-Allow-Origin") == tt.origin
+		if resp.StatusCode != http.StatusNoContent || got != tt.allowed {
+			t.Errorf("preflight from %s: status %d, allowed %v, want %v", tt.origin, resp.StatusCode, got, tt.allowed)
+		}
+	}
+}
+
+// staticResolver resolves known hosts to fixed addresses
+type staticResolver map[string]string
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if ip, ok := r[host]; ok {
+		return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestTargetValidation(t *testing.T) {
+	ts, _ := newTestServer(t, &fakeRunner{}, Config{Resolver: staticResolver{"dns.google": "8.8.8.8"}})
+
+	for _, body := range []string{`{"target": ""}`, `{"target": "-w 99 8.8.8.8"}`, `{"target": "8.8.8.8; reboot"}`, `{"target": "8.8.8.8", "options": {"backend": "ping"}}`} {
+		if resp := request(t, http.MethodPost, ts.URL+"/api/traces", body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"https://dns.google/resolve", http.StatusOK},
+		{"nosuch.example", http.StatusNotFound},
+		{"--help", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp := request(t, http.MethodGet, ts.URL+"/api/resolve?target="+tt.target, "")
+		if resp.StatusCode != tt.status {
+			t.Errorf("resolve %s status = %d, want %d", tt.target, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status == http.StatusOK {
+			var resolved target.Target
+			decode(t, resp, &resolved)
+			if resolved.Host != "dns.google" || resolved.Port != 443 || len(resolved.Addresses) != 1 || resolved.Addresses[0] != "8.8.8.8" {
+				t.Errorf("resolved = %+v", resolved)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"packet-painter/internal/events"
+)
+
+// subscriberBuffer is how many events a slow stream client may fall behind before it is disconnected
+const subscriberBuffer = 256
+
+// heartbeatInterval keeps idle streams alive through proxies
+const heartbeatInterval = 15 * time.Second
+
+// handleEvents streams trace events as Server-Sent Events
+// ?session=<id> limits the stream to one session and replays what already happened.
+// Each event carries its sequence number as the SSE id, so a reconnecting client
+// sending Last-Event-ID (or ?after=<seq>) only receives what it missed
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	query := r.URL.Query()
+	after := query.Get("after")
+	if after == "" {
+		after = r.Header.Get("Last-Event-ID")
+	}
+	var afterSeq uint64
+	if after != "" {
+		n, err := strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid after")
+			return
+		}
+		afterSeq = n
+	}
+
+	sessionID := query.Get("session")
+	if sessionID != "" {
+		_, err := s.registry.Get(sessionID)
+		if err != nil && len(s.bus.Replay(sessionID, 0)) == 0 {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+	}
+
+	sub := s.bus.Subscribe(events.SubscribeOptions{
+		SessionID: sessionID,
+		Replay:    sessionID != "" || afterSeq > 0,
+		AfterSeq:  afterSeq,
+		Buffer:    subscriberBuffer,
+	})
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			// A client that fell behind reconnects and resumes from its last id
+			if sub.Dropped() > 0 {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Name, data)
+			flusher.Flush()
+
+			// A session stream ends with the session
+			if sessionID != "" && events.IsFinal(e.Name) {
+				return
+			}
+		}
+	}
+}
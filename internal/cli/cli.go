@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Exit codes returned by Run
+const (
+	ExitReached     = 0   // Destination answered
+	ExitError       = 1   // Trace could not run
+	ExitPartial     = 2   // Trace finished without reaching the destination
+	ExitUsage       = 64  // Bad command line
+	ExitInterrupted = 130 // Cancelled with Ctrl+C
+)
+
+// command is a headless subcommand
+type command struct {
+	summary string
+	run     func(ctx context.Context, args []string, stdout, stderr io.Writer) int
+}
+
+// commands lists the subcommands available without the GUI
+var commands = map[string]command{
+	"trace": {"Run a traceroute and print the result", runTrace},
+	"serve": {"Serve the trace API over HTTP with a Server-Sent Events stream", runServe},
+	"tui":   {"Trace interactively with a live hop table and world map", runTUI},
+}
+
+// IsCommand reports whether name is a headless subcommand
+// main uses this to decide whether to start the GUI
+func IsCommand(name string) bool {
+	if name == "help" || name == "-h" || name == "--help" {
+		return true
+	}
+	_, ok := commands[name]
+	return ok
+}
+
+// Run executes a subcommand and returns the process exit code
+// args starts with the subcommand name
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return ExitUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			usage(stdout)
+			return ExitReached
+		}
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return ExitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return cmd.run(ctx, args[1:], stdout, stderr)
+}
+
+// usage prints the list of subcommands
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: packet-painter <command> [options]")
+	fmt.Fprintln(w, "\nRun without a command to open the desktop app.\n\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+}
+
+// splitArgs separates flags from positional arguments so flags may follow the target
+// flag.FlagSet stops at the first positional argument otherwise
+func splitArgs(args []string, valueFlags map[string]bool) (flags, positional []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		name := strings.TrimLeft(arg, "-")
+		if !strings.Contains(name, "=") && valueFlags[name] && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return flags, positional
+}
@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"packet-painter/internal/geo"
+	"packet-painter/internal/trace"
+)
+
+// fakeRunner replays a fixed set of hops
+type fakeRunner struct {
+	hops []*trace.Hop
+	err  error
+}
+
+func (r *fakeRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop trace.HopCallback, onComplete trace.CompletedCallback, onError trace.ErrorCallback) error {
+	for _, hop := range r.hops {
+		onHop(hop)
+	}
+	if r.err != nil {
+		onError(r.err)
+		return r.err
+	}
+	onComplete(len(r.hops))
+	return nil
+}
+
+func useRunner(t *testing.T, runner trace.Runner) {
+	t.Helper()
+	previous := newSession
+	newSession = func(target string, opts trace.Options) *trace.Session {
+		return trace.NewSessionWithRunner(target, opts, runner)
+	}
+	t.Cleanup(func() { newSession = previous })
+}
+
+// hostResolver resolves known hosts to fixed addresses
+type hostResolver map[string][]string
+
+func (r hostResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+// useResolver answers target lookups from r for the rest of the test
+func useResolver(t *testing.T, r hostResolver) {
+	t.Helper()
+	previous := resolver
+	resolver = r
+	t.Cleanup(func() { resolver = previous })
+}
+
+func TestRunTrace(t *testing.T) {
+	reached := []*trace.Hop{
+		{HopNumber: 1, IPAddress: "192.168.1.1", RTT: []float64{0.5}, AvgRTT: 0.5},
+		{HopNumber: 2, IPAddress: "*", IsTimeout: true},
+		{HopNumber: 3, IPAddress: "8.8.8.8", Hostname: "dns.google", RTT: []float64{15}, AvgRTT: 15, IsDestination: true,
+			Location: &geo.Location{City: "Mountain View", CountryCode: "US"}},
+	}
+	partial := reached[:2]
+	prohibited := []*trace.Hop{
+		reached[0],
+		{HopNumber: 2, IPAddress: "10.0.0.1", RTT: []float64{5}, AvgRTT: 5, Unreachable: trace.UnreachableProhibited},
+	}
+	labelled := []*trace.Hop{
+		{HopNumber: 1, IPAddress: "62.115.42.1", RTT: []float64{21.4}, AvgRTT: 21.4,
+			MPLS: []trace.MPLSLabel{{Label: 24001}, {Label: 300, BottomOfStack: true}}},
+		reached[2],
+	}
+	tagged := []*trace.Hop{
+		{HopNumber: 1, IPAddress: "8.8.8.8", Hostname: "dns.google", RTT: []float64{15}, AvgRTT: 15, IsDestination: true, ASN: "AS15169"},
+	}
+
+	tests := []struct {
+		name         string
+		args         []string
+		runner       *fakeRunner
+		expectedCode int
+		contains     string
+	}{
+		{"text reached", []string{"8.8.8.8"}, &fakeRunner{hops: reached}, ExitReached, " 3  8.8.8.8 (dns.google)  15.0 ms  Mountain View, US"},
+		{"flags after target", []string{"8.8.8.8", "--max-hops", "5", "--text"}, &fakeRunner{hops: reached}, ExitReached, "5 hops max"},
+		{"partial", []string{"8.8.8.8"}, &fakeRunner{hops: partial}, ExitPartial, "destination not reached"},
+		{"prohibited", []string{"8.8.8.8"}, &fakeRunner{hops: prohibited}, ExitPartial, "10.0.0.1 reported communication administratively prohibited"},
+		{"asn", []string{"8.8.8.8", "--as-lookup"}, &fakeRunner{hops: tagged}, ExitReached, " 1  8.8.8.8 (dns.google) [AS15169]  15.0 ms"},
+		{"mpls", []string{"8.8.8.8"}, &fakeRunner{hops: labelled}, ExitReached, " 1  62.115.42.1  21.4 ms  MPLS 24001/300"},
+		{"error", []string{"--json", "8.8.8.8"}, &fakeRunner{err: errors.New("boom")}, ExitError, `"outcome": "error"`},
+		{"error code", []string{"--json", "8.8.8.8"}, &fakeRunner{err: errors.New("boom")}, ExitError, `"errorCode": "tool_failed"`},
+		{"csv", []string{"--csv", "8.8.8.8"}, &fakeRunner{hops: reached}, ExitReached, "8.8.8.8"},
+		{"missing target", nil, &fakeRunner{}, ExitUsage, ""},
+		{"two formats", []string{"--json", "--csv", "8.8.8.8"}, &fakeRunner{}, ExitUsage, ""},
+		{"bad max hops", []string{"--max-hops=0", "8.8.8.8"}, &fakeRunner{}, ExitUsage, ""},
+		{"backend", []string{"8.8.8.8", "--backend", "tracepath"}, &fakeRunner{hops: reached}, ExitReached, "8.8.8.8"},
+		{"unknown backend", []string{"--backend=ping", "8.8.8.8"}, &fakeRunner{}, ExitUsage, ""},
+		{"url target", []string{"https://dns.google/"}, &fakeRunner{hops: reached}, ExitReached, "traceroute to dns.google, 30 hops max"},
+		{"unsafe target", []string{"8.8.8.8;reboot"}, &fakeRunner{}, ExitUsage, ""},
+		{"unknown host", []string{"nosuch.example"}, &fakeRunner{}, ExitError, ""},
+	}
+
+	useResolver(t, hostResolver{"dns.google": {"8.8.8.8"}})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRunner(t, tt.runner)
+			var stdout, stderr bytes.Buffer
+			code := Run(append([]string{"trace"}, tt.args...), &stdout, &stderr)
+			if code != tt.expectedCode {
+				t.Fatalf("exit code = %d, want %d\nstdout: %s\nstderr: %s", code, tt.expectedCode, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.contains) {
+				t.Errorf("stdout does not contain %q:\n%s", tt.contains, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunTraceJSON(t *testing.T) {
+	useRunner(t, &fakeRunner{hops: []*trace.Hop{
+		{HopNumber: 1, IPAddress: "8.8.8.8", RTT: []float64{10}, AvgRTT: 10, IsDestination: true},
+	}})
+
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"trace", "--json", "8.8.8.8"}, &stdout, &stderr); code != ExitReached {
+		t.Fatalf("exit code = %d, want %d: %s", code, ExitReached, stderr.String())
+	}
+
+	var doc struct {
+		Hops []trace.Hop `json:"hops"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("stdout is not a JSON document: %v", err)
+	}
+	if len(doc.Hops) != 1 {
+		t.Errorf("got %d hops, want 1", len(doc.Hops))
+	}
+}
+
+func TestIsCommand(t *testing.T) {
+	if !IsCommand("trace") || !IsCommand("help") {
+		t.Error("IsCommand() = false for a known command")
+	}
+	if IsCommand("-psn_0_12345") || IsCommand("frobnicate") {
+		t.Error("IsCommand() = true for an unknown argument")
+	}
+}
+
+// addressRunner replays a fixed path for each target address
+type addressRunner map[string][]string
+
+func (r addressRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop trace.HopCallback, onComplete trace.CompletedCallback, onError trace.ErrorCallback) error {
+	for i, ip := range r[target] {
+		onHop(&trace.Hop{HopNumber: i + 1, IPAddress: ip, AvgRTT: float64(i + 1), IsTimeout: ip == "*", IsDestination: ip == target})
+	}
+	onComplete(len(r[target]))
+	return nil
+}
+
+func TestTraceAllAddresses(t *testing.T) {
+	useResolver(t, hostResolver{"example.com": {"192.0.2.1", "192.0.2.2", "2001:db8::1"}})
+	useRunner(t, addressRunner{
+		"192.0.2.1":   {"10.0.0.1", "198.51.100.1", "192.0.2.1"},
+		"192.0.2.2":   {"10.0.0.1", "203.0.113.1", "*"},
+		"2001:db8::1": {"fe80::1", "2001:db8::1"},
+	})
+
+	tests := []struct {
+		name         string
+		args         []string
+		expectedCode int
+		contains     []string
+	}{
+		{"ipv4 only", []string{"--all-addresses", "example.com"}, ExitPartial, []string{
+			"tracing 2 addresses of example.com",
+			"shared path: 10.0.0.1",
+			"paths diverge at hop 2",
+			"192.0.2.1                                reached in 3 hops, 3.0 ms",
+			"192.0.2.2                                not reached after 3 hops, last reply 2.0 ms",
+		}},
+		{"both families", []string{"--all-addresses", "--both-families", "--json", "example.com"}, ExitPartial, []string{`"family": 4`, `"family": 6`, `"divergeHop": 2`}},
+		{"csv refused", []string{"--all-addresses", "--csv", "example.com"}, ExitUsage, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Run(append([]string{"trace"}, tt.args...), &stdout, &stderr)
+			if code != tt.expectedCode {
+				t.Fatalf("exit code = %d, want %d\nstdout: %s\nstderr: %s", code, tt.expectedCode, stdout.String(), stderr.String())
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("stdout does not contain %q:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+}
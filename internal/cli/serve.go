@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"packet-painter/internal/api"
+	"packet-painter/internal/history"
+	"packet-painter/internal/metrics"
+	"packet-painter/internal/scheduler"
+	"packet-painter/internal/sessions"
+	"packet-painter/internal/telemetry"
+	"packet-painter/internal/trace"
+)
+
+// tokenEnv names the environment variable read when --token is not given
+const tokenEnv = "PACKET_PAINTER_TOKEN"
+
+// runServe implements `packet-painter serve`
+func runServe(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: packet-painter serve [options]")
+		fmt.Fprintf(stderr, "\nServes the trace API. Without --token or $%s a random token is generated.\n\nOptions:\n", tokenEnv)
+		fs.PrintDefaults()
+	}
+
+	addr := fs.String("addr", "127.0.0.1:8787", "address to listen on")
+	token := fs.String("token", os.Getenv(tokenEnv), "bearer token clients must send")
+	cors := fs.String("cors", "", "comma-separated origins allowed to call the API, or *")
+	maxPerClient := fs.Int("max-per-client", api.DefaultMaxPerClient, "queued and running traces allowed per client address")
+	maxConcurrent := fs.Int("max-concurrent", sessions.DefaultMaxConcurrent, "traces run at once across all clients")
+	queue := fs.Bool("queue", false, "queue traces beyond --max-concurrent instead of rejecting them")
+	timeout := fs.Duration("timeout", api.DefaultTraceTimeout, "give up on a single trace after this long")
+	historyDir := fs.String("history", filepath.Join(dataDir(), "history"), "directory of the trace history, empty to disable")
+	schedules := fs.String("schedules", filepath.Join(dataDir(), "schedules.json"), "scheduled traces to run, empty to disable")
+	withMetrics := fs.Bool("metrics", false, "expose Prometheus metrics for API and scheduled traces at /metrics")
+	otlpEndpoint := fs.String("otlp-endpoint", telemetry.ConfigFromEnv().Endpoint, "OTLP/HTTP collector to export finished traces to, e.g. http://localhost:4318")
+	maxTargets := fs.Int("metrics-max-targets", metrics.DefaultMaxTargets, "targets exported before the least recently traced is dropped")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitReached
+		}
+		return ExitUsage
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return ExitUsage
+	}
+	if *maxPerClient < 1 || *maxConcurrent < 1 || *timeout <= 0 || *maxTargets < 1 {
+		fmt.Fprintln(stderr, "--max-per-client, --max-concurrent, --timeout and --metrics-max-targets must be positive")
+		return ExitUsage
+	}
+
+	if *token == "" {
+		generated, err := randomToken()
+		if err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return ExitError
+		}
+		*token = generated
+		fmt.Fprintln(stdout, "Generated API token:", generated)
+	}
+
+	cfg := api.Config{
+		Token:        *token,
+		MaxPerClient: *maxPerClient,
+		TraceTimeout: *timeout,
+		Limits:       sessions.Limits{MaxConcurrent: *maxConcurrent, Queue: *queue},
+		NewSession:   newSession,
+	}
+	for _, origin := range strings.Split(*cors, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+		}
+	}
+
+	// Scheduled runs compare against earlier results, so they share the history store
+	var results scheduler.Store
+	if *historyDir != "" {
+		store, err := history.Open(*historyDir)
+		if err != nil {
+			fmt.Fprintln(stderr, "error: failed to open trace history:", err)
+			return ExitError
+		}
+		defer store.Close()
+		cfg.History = store
+		results = store
+	}
+
+	// Finished API and scheduled traces feed the metrics and telemetry exporters
+	var observers []func(result *trace.Result)
+	var registry *metrics.Registry
+	if *withMetrics {
+		registry = metrics.NewRegistry(*maxTargets)
+		observers = append(observers, registry.Observe)
+	}
+	otelCfg := telemetry.ConfigFromEnv()
+	if *otlpEndpoint != "" {
+		otelCfg.Endpoint = *otlpEndpoint
+	}
+	if otelCfg.Enabled() {
+		exporter := telemetry.NewExporter(otelCfg)
+		defer exporter.Flush()
+		observers = append(observers, exporter.Observe)
+	}
+	observe := func(result *trace.Result) {
+		for _, observer := range observers {
+			observer(result)
+		}
+	}
+	cfg.OnResult = observe
+
+	server := api.New(cfg)
+	if registry != nil {
+		server.Handle("GET /metrics", registry)
+	}
+
+	if *schedules != "" {
+		jobs := scheduler.New(*schedules, results, server.Sessions(), server.Publish)
+		jobs.SetSessionFactory(scheduler.SessionFactory(newSession))
+		jobs.SetObserver(observe)
+		if err := jobs.Load(); err != nil {
+			server.Close()
+			fmt.Fprintln(stderr, "error: failed to load scheduled traces:", err)
+			return ExitError
+		}
+		jobs.Start(ctx)
+		defer jobs.Stop()
+		fmt.Fprintf(stdout, "Running %d scheduled traces\n", len(jobs.Jobs()))
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		server.Close()
+		fmt.Fprintln(stderr, "error:", err)
+		return ExitError
+	}
+
+	httpServer := &http.Server{Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	fmt.Fprintf(stdout, "Listening on http://%s\n", listener.Addr())
+
+	errs := make(chan error, 1)
+	go func() { errs <- httpServer.Serve(listener) }()
+
+	select {
+	case err := <-errs:
+		server.Close()
+		fmt.Fprintln(stderr, "error:", err)
+		return ExitError
+	case <-ctx.Done():
+	}
+
+	// Event streams only end when the API closes, so close it before waiting on connections
+	server.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return ExitError
+	}
+	return ExitReached
+}
+
+// randomToken returns a random 128-bit hex token
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// dataDir returns the directory the desktop app keeps its data in, so both share history
+func dataDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(dir, "packet-painter")
+}
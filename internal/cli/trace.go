@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"packet-painter/internal/events"
+	"packet-painter/internal/export"
+	"packet-painter/internal/sessions"
+	"packet-painter/internal/target"
+	"packet-painter/internal/telemetry"
+	"packet-painter/internal/trace"
+)
+
+// newSession creates the session for a trace command, replaced in tests
+var newSession = trace.NewSessionWithOptions
+
+// resolver looks up trace targets; nil for the system resolver, replaced in tests
+var resolver target.Resolver
+
+// traceValueFlags lists the trace flags that take a value
+var traceValueFlags = map[string]bool{
+	"max-hops":      true,
+	"probes":        true,
+	"wait":          true,
+	"backend":       true,
+	"timeout":       true,
+	"otlp-endpoint": true,
+}
+
+// addOptionFlags registers the trace option flags shared by trace and tui
+// The returned function validates the parsed values
+func addOptionFlags(fs *flag.FlagSet) func() (trace.Options, error) {
+	defaults := trace.DefaultOptions()
+	maxHops := fs.Int("max-hops", defaults.MaxHops, "maximum number of hops to probe")
+	probes := fs.Int("probes", defaults.ProbesPerHop, "probes sent per hop")
+	wait := fs.Int("wait", defaults.WaitSeconds, "seconds to wait for each probe reply")
+	backendName := fs.String("backend", string(trace.BackendAuto), "trace tool to run: auto, traceroute, tracepath, mtr or tracert")
+	asLookup := fs.Bool("as-lookup", false, "have traceroute look up each hop's AS number")
+	mpls := fs.Bool("mpls", false, "have traceroute record MPLS label stacks (Linux traceroute only)")
+
+	return func() (trace.Options, error) {
+		if *maxHops < 1 || *maxHops > 255 {
+			return trace.Options{}, errors.New("--max-hops must be between 1 and 255")
+		}
+		if *probes < 1 || *wait < 1 {
+			return trace.Options{}, errors.New("--probes and --wait must be positive")
+		}
+		backend, err := trace.ParseBackend(*backendName)
+		if err != nil {
+			return trace.Options{}, err
+		}
+		return trace.Options{MaxHops: *maxHops, ProbesPerHop: *probes, WaitSeconds: *wait, Backend: backend, ASLookup: *asLookup, MPLS: *mpls}, nil
+	}
+}
+
+// runTrace implements `packet-painter trace <target>`
+func runTrace(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("trace", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: packet-painter trace <target> [--json|--csv|--text] [options]")
+		fmt.Fprintln(stderr, "\nExit status is 0 if the destination was reached, 2 if not, 1 on error.\n\nOptions:")
+		fs.PrintDefaults()
+	}
+
+	jsonOut := fs.Bool("json", false, "print the finished trace as a JSON document")
+	csvOut := fs.Bool("csv", false, "print the finished trace as CSV")
+	textOut := fs.Bool("text", false, "stream hop lines as they arrive (default)")
+	options := addOptionFlags(fs)
+	timeout := fs.Duration("timeout", 2*time.Minute, "give up on the whole trace after this long")
+	allAddresses := fs.Bool("all-addresses", false, "trace every address the target resolves to and compare the paths")
+	bothFamilies := fs.Bool("both-families", false, "with --all-addresses, trace IPv6 addresses as well as IPv4")
+	otlpEndpoint := fs.String("otlp-endpoint", telemetry.ConfigFromEnv().Endpoint, "OTLP/HTTP collector to export the finished trace to")
+
+	flags, positional := splitArgs(args, traceValueFlags)
+	if err := fs.Parse(flags); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitReached
+		}
+		return ExitUsage
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return ExitUsage
+	}
+
+	selected := 0
+	for _, set := range []bool{*jsonOut, *csvOut, *textOut} {
+		if set {
+			selected++
+		}
+	}
+	if selected > 1 {
+		fmt.Fprintln(stderr, "only one of --json, --csv or --text may be given")
+		return ExitUsage
+	}
+	if *allAddresses && *csvOut {
+		fmt.Fprintln(stderr, "--csv cannot be used with --all-addresses")
+		return ExitUsage
+	}
+	opts, err := options()
+	if err == nil && *timeout <= 0 {
+		err = errors.New("--timeout must be positive")
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	resolved, err := target.Resolve(ctx, resolver, positional[0])
+	if err != nil {
+		if errors.Is(err, target.ErrEmpty) || errors.Is(err, target.ErrUnsafe) || errors.Is(err, target.ErrInvalid) {
+			fmt.Fprintln(stderr, err)
+			return ExitUsage
+		}
+		fmt.Fprintln(stderr, "error:", err)
+		return ExitError
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	if *allAddresses {
+		return traceAll(ctx, resolved, resolved.Select(*bothFamilies), opts, *jsonOut, stdout, stderr)
+	}
+
+	session := newSession(resolved.Host, opts)
+
+	bus := events.NewBus()
+	if !*jsonOut && !*csvOut {
+		fmt.Fprintf(stdout, "traceroute to %s, %d hops max\n", resolved.Host, opts.MaxHops)
+		bus.Handle(events.SubscribeOptions{SessionID: session.ID}, printHops(stdout))
+	}
+
+	result, err := events.Run(ctx, bus, session)
+	// Let the printer finish before anything else is written
+	bus.Close()
+
+	otelCfg := telemetry.ConfigFromEnv()
+	if *otlpEndpoint != "" {
+		otelCfg.Endpoint = *otlpEndpoint
+	}
+	if otelCfg.Enabled() {
+		// The trace context may have expired, so export with a fresh one
+		if exportErr := telemetry.NewExporter(otelCfg).Export(context.Background(), result); exportErr != nil {
+			fmt.Fprintln(stderr, "warning:", exportErr)
+		}
+	}
+
+	switch {
+	case *jsonOut:
+		if werr := export.WriteJSON(stdout, result); werr != nil {
+			fmt.Fprintln(stderr, "error:", werr)
+			return ExitError
+		}
+	case *csvOut:
+		if werr := export.WriteCSV(stdout, result, false); werr != nil {
+			fmt.Fprintln(stderr, "error:", werr)
+			return ExitError
+		}
+	default:
+		// Errors and cancellation are reported on stderr by exitCode
+		if result.Outcome == trace.OutcomeCompleted {
+			fmt.Fprintln(stdout, summaryLine(result))
+		}
+	}
+
+	return exitCode(result, err, stderr)
+}
+
+// traceAll traces every one of addresses side by side and prints how the paths compare
+func traceAll(ctx context.Context, resolved target.Target, addresses []string, opts trace.Options, jsonOut bool, stdout, stderr io.Writer) int {
+	bus := events.NewBus()
+	defer bus.Close()
+	registry := sessions.New(bus)
+	defer registry.Close()
+	registry.SetLimits(sessions.Limits{MaxConcurrent: len(addresses)})
+
+	if !jsonOut {
+		fmt.Fprintf(stdout, "tracing %d addresses of %s, %d hops max\n", len(addresses), resolved.Host, opts.MaxHops)
+	}
+	comparisons, err := registry.TraceAll(ctx, resolved.Host, addresses, func(address string) *trace.Session {
+		return newSession(address, opts)
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return ExitError
+	}
+
+	if jsonOut {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(comparisons); err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return ExitError
+		}
+	} else {
+		for _, c := range comparisons {
+			printComparison(stdout, c)
+		}
+	}
+
+	// Reached only if every address was; an error only if every trace failed
+	reached, failed, total := 0, 0, 0
+	for _, c := range comparisons {
+		for _, p := range c.Paths {
+			total++
+			if p.Reached {
+				reached++
+			}
+			if p.Outcome != trace.OutcomeCompleted {
+				failed++
+			}
+		}
+	}
+	switch {
+	case ctx.Err() == context.Canceled:
+		fmt.Fprintln(stderr, "trace cancelled")
+		return ExitInterrupted
+	case failed == total:
+		return ExitError
+	case reached == total:
+		return ExitReached
+	default:
+		return ExitPartial
+	}
+}
+
+// printComparison writes a path comparison as text
+func printComparison(w io.Writer, c *trace.PathComparison) {
+	fmt.Fprintf(w, "\nIPv%d: %d addresses\n", c.Family, len(c.Paths))
+	if len(c.SharedHops) > 0 {
+		ips := make([]string, len(c.SharedHops))
+		for i, hop := range c.SharedHops {
+			ips[i] = hop.IPAddress
+		}
+		fmt.Fprintf(w, "  shared path: %s\n", strings.Join(ips, " -> "))
+	}
+	if c.DivergeHop > 0 {
+		fmt.Fprintf(w, "  paths diverge at hop %d\n", c.DivergeHop)
+	} else if len(c.Paths) > 1 {
+		fmt.Fprintln(w, "  all paths are the same")
+	}
+	for _, p := range c.Paths {
+		switch {
+		case p.Outcome == trace.OutcomeError:
+			fmt.Fprintf(w, "  %-39s  error: %s\n", p.Address, p.Error)
+		case p.Reached:
+			fmt.Fprintf(w, "  %-39s  reached in %d hops, %.1f ms\n", p.Address, p.Hops, p.EndToEndMs)
+		default:
+			fmt.Fprintf(w, "  %-39s  not reached after %d hops, last reply %.1f ms\n", p.Address, p.Hops, p.EndToEndMs)
+		}
+	}
+}
+
+// printHops returns a bus subscriber that prints each hop as it arrives
+func printHops(w io.Writer) func(events.Event) {
+	return func(e events.Event) {
+		if hop, ok := e.Payload.(trace.TraceHopEvent); ok {
+			fmt.Fprintln(w, hopLine(hop.Hop))
+		}
+	}
+}
+
+// exitCode maps a finished trace to the process exit status
+func exitCode(result *trace.Result, err error, stderr io.Writer) int {
+	if result.Outcome == trace.OutcomeCancelled {
+		fmt.Fprintln(stderr, "trace cancelled")
+		return ExitInterrupted
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		if te := trace.AsTraceError(err); te != nil && te.Remediation != "" {
+			fmt.Fprintln(stderr, "hint:", te.Remediation)
+		}
+		return ExitError
+	}
+	if !result.Reached() {
+		return ExitPartial
+	}
+	return ExitReached
+}
+
+// hopLine formats a hop in a traceroute-like layout with enrichment appended
+//
+//	3  142.250.80.46 (lga34s34-in-f14.1e100.net)  15.6 ms  15.5 ms  New York, US  [Google Cloud]
+func hopLine(hop *trace.Hop) string {
+	if hop.IsTimeout {
+		return fmt.Sprintf("%2d  *", hop.HopNumber)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%2d  %s", hop.HopNumber, hop.IPAddress)
+	if hop.Hostname != "" {
+		fmt.Fprintf(&b, " (%s)", hop.Hostname)
+	}
+	if hop.ASN != "" {
+		fmt.Fprintf(&b, " [%s]", hop.ASN)
+	}
+	b.WriteString(" ")
+	for _, rtt := range hop.RTT {
+		fmt.Fprintf(&b, " %.1f ms", rtt)
+	}
+	if len(hop.RTT) == 0 && hop.AvgRTT > 0 {
+		fmt.Fprintf(&b, " %.1f ms", hop.AvgRTT)
+	}
+	if hop.Unreachable != "" {
+		fmt.Fprintf(&b, "  ! %s", hop.Unreachable.Description())
+	}
+	if len(hop.MPLS) > 0 {
+		labels := make([]string, len(hop.MPLS))
+		for i, entry := range hop.MPLS {
+			labels[i] = strconv.Itoa(entry.Label)
+		}
+		fmt.Fprintf(&b, "  MPLS %s", strings.Join(labels, "/"))
+	}
+	if place := placeName(hop); place != "" {
+		fmt.Fprintf(&b, "  %s", place)
+	}
+	if hop.DataCenter != nil {
+		fmt.Fprintf(&b, "  [%s]", hop.DataCenter.Provider)
+	}
+	return b.String()
+}
+
+// placeName returns "City, CC" for a located hop
+func placeName(hop *trace.Hop) string {
+	if hop.Location == nil {
+		return ""
+	}
+	var parts []string
+	if hop.Location.City != "" {
+		parts = append(parts, hop.Location.City)
+	}
+	if hop.Location.CountryCode != "" {
+		parts = append(parts, hop.Location.CountryCode)
+	} else if hop.Location.Country != "" {
+		parts = append(parts, hop.Location.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// summaryLine describes a completed trace
+func summaryLine(result *trace.Result) string {
+	seconds := float64(result.DurationMs) / 1000
+	if result.Reached() {
+		return fmt.Sprintf("destination reached in %d hops (%.1fs)", len(result.Hops), seconds)
+	}
+	line := fmt.Sprintf("destination not reached after %d hops (%.1fs)", len(result.Hops), seconds)
+	switch _, reason := result.Path(); reason {
+	case trace.ReasonTrailingTimeouts:
+		line += ", stopped after the path went silent"
+	case trace.ReasonDeadline:
+		line += ", stopped when the time ran out"
+	case trace.ReasonUnreachable:
+		last := result.Hops[len(result.Hops)-1]
+		line += fmt.Sprintf(", %s reported %s", last.IPAddress, last.Unreachable.Description())
+	}
+	return line
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"packet-painter/internal/target"
+	"packet-painter/internal/tui"
+)
+
+// runTUI implements `packet-painter tui <target>`
+func runTUI(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: packet-painter tui <target> [options]")
+		fmt.Fprintf(stderr, "\nNeeds a terminal of at least %dx%d.\n\nOptions:\n", tui.MinWidth, tui.MinHeight)
+		fs.PrintDefaults()
+	}
+	options := addOptionFlags(fs)
+
+	flags, positional := splitArgs(args, traceValueFlags)
+	if err := fs.Parse(flags); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitReached
+		}
+		return ExitUsage
+	}
+	if len(positional) != 1 || strings.TrimSpace(positional[0]) == "" {
+		fs.Usage()
+		return ExitUsage
+	}
+	opts, err := options()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+	parsed, err := target.Parse(positional[0])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitUsage
+	}
+
+	if err := tui.Run(ctx, parsed.Host, opts, newSession, os.Stdin, stdout); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return ExitError
+	}
+	return ExitReached
+}
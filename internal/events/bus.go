@@ -0,0 +1,287 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"packet-painter/internal/trace"
+)
+
+// DefaultBuffer is how many events a subscriber may fall behind before new events are dropped for it
+const DefaultBuffer = 1024
+
+// retainedSessions is how many finished sessions keep their events for replay
+const retainedSessions = 16
+
+// Payload is a typed event body such as trace.TraceHopEvent
+type Payload interface {
+	EventName() string      // e.g. "trace:hop"
+	EventSessionID() string // Session the event belongs to, "" if none
+}
+
+// Event is a published payload with its position on the bus
+type Event struct {
+	Seq       uint64
+	Name      string
+	SessionID string
+	Payload   Payload
+}
+
+// MarshalJSON encodes the payload with a "seq" field added, so consumers of
+// the existing event shapes also see the sequence number
+func (e Event) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return data, nil
+	}
+
+	out := make([]byte, 0, len(data)+24)
+	out = append(out, `{"seq":`...)
+	out = strconv.AppendUint(out, e.Seq, 10)
+	if len(data) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, data[1:]...), nil
+}
+
+// IsFinal reports whether an event ends its session
+// Streams and replay use it to decide when a session's events are complete
+func IsFinal(name string) bool {
+	return name == trace.EventCompleted || name == trace.EventCancelled || name == trace.EventError
+}
+
+// SubscribeOptions selects which events a subscriber receives
+type SubscribeOptions struct {
+	SessionID string // Only events of this session; empty for all
+	Replay    bool   // Deliver retained events first: of SessionID, or of every running session
+	AfterSeq  uint64 // With Replay, skip events up to and including this sequence number
+	Buffer    int    // Events queued before new ones are dropped; 0 for DefaultBuffer
+}
+
+// Subscription is one subscriber's buffered view of the bus
+type Subscription struct {
+	bus     *Bus
+	opts    SubscribeOptions
+	ch      chan Event
+	dropped atomic.Uint64
+	closed  bool // Guarded by bus.mu
+	done    chan struct{}
+}
+
+// Events returns the channel events are delivered on; it is closed when the subscription ends
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns how many events were discarded because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.removeLocked(s)
+}
+
+// Done is closed once a Handle subscription's handler has returned for the last time
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// sessionLog holds the events of one session for replay
+type sessionLog struct {
+	events   []Event
+	finished bool
+}
+
+// Bus delivers typed events to subscribers in publish order
+type Bus struct {
+	mu          sync.Mutex
+	seq         uint64
+	subscribers map[*Subscription]struct{}
+	logs        map[string]*sessionLog
+	finished    []string // Finished sessions with retained logs, oldest first
+	closed      bool
+	handlers    sync.WaitGroup
+}
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+		logs:        make(map[string]*sessionLog),
+	}
+}
+
+// Publish assigns the next sequence number to a payload and delivers it
+// Never blocks: subscribers whose buffer is full miss the event
+func (b *Bus) Publish(payload Payload) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e := Event{Seq: b.seq, Name: payload.EventName(), SessionID: payload.EventSessionID(), Payload: payload}
+	if b.closed {
+		return e
+	}
+
+	if e.SessionID != "" {
+		b.record(e)
+	}
+	for sub := range b.subscribers {
+		if sub.opts.SessionID != "" && sub.opts.SessionID != e.SessionID {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+	return e
+}
+
+// record keeps an event for replay, trimming old finished sessions
+func (b *Bus) record(e Event) {
+	log, ok := b.logs[e.SessionID]
+	if !ok {
+		log = &sessionLog{}
+		b.logs[e.SessionID] = log
+	}
+	log.events = append(log.events, e)
+
+	if IsFinal(e.Name) && !log.finished {
+		log.finished = true
+		b.finished = append(b.finished, e.SessionID)
+		if len(b.finished) > retainedSessions {
+			delete(b.logs, b.finished[0])
+			b.finished = b.finished[1:]
+		}
+	}
+}
+
+// Replay returns the retained events of a session after the given sequence number
+func (b *Bus) Replay(sessionID string, afterSeq uint64) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.replayLocked(sessionID, afterSeq)
+}
+
+// LastSeq returns the sequence number of a session's latest retained event, or 0 if none
+func (b *Bus) LastSeq(sessionID string) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	log, ok := b.logs[sessionID]
+	if !ok || len(log.events) == 0 {
+		return 0
+	}
+	return log.events[len(log.events)-1].Seq
+}
+
+// replayLocked collects retained events for a session, or for all running sessions when sessionID is empty
+func (b *Bus) replayLocked(sessionID string, afterSeq uint64) []Event {
+	var events []Event
+	collect := func(log *sessionLog) {
+		for _, e := range log.events {
+			if e.Seq > afterSeq {
+				events = append(events, e)
+			}
+		}
+	}
+
+	if sessionID != "" {
+		if log, ok := b.logs[sessionID]; ok {
+			collect(log)
+		}
+		return events
+	}
+
+	for _, log := range b.logs {
+		if !log.finished {
+			collect(log)
+		}
+	}
+	// Sessions interleave, so restore publish order
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events
+}
+
+// Subscribe registers a subscriber that reads from Events()
+// Replayed events are queued atomically with registration, so none are missed or repeated
+func (b *Bus) Subscribe(opts SubscribeOptions) *Subscription {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if opts.Replay {
+		replay = b.replayLocked(opts.SessionID, opts.AfterSeq)
+	}
+
+	sub := &Subscription{
+		bus:  b,
+		opts: opts,
+		ch:   make(chan Event, opts.Buffer+len(replay)),
+		done: make(chan struct{}),
+	}
+	for _, e := range replay {
+		sub.ch <- e
+	}
+	if b.closed {
+		sub.closed = true
+		close(sub.ch)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Handle subscribes and calls handler for each event on a dedicated goroutine
+// A slow handler only delays its own events
+func (b *Bus) Handle(opts SubscribeOptions, handler func(Event)) *Subscription {
+	sub := b.Subscribe(opts)
+	b.handlers.Add(1)
+	go func() {
+		defer b.handlers.Done()
+		defer close(sub.done)
+		for e := range sub.ch {
+			handler(e)
+		}
+	}()
+	return sub
+}
+
+// Close ends every subscription and waits for Handle subscribers to drain their buffers
+func (b *Bus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	for sub := range b.subscribers {
+		b.removeLocked(sub)
+	}
+	b.mu.Unlock()
+
+	b.handlers.Wait()
+}
+
+// removeLocked unregisters a subscription and closes its channel
+func (b *Bus) removeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
@@ -0,0 +1,267 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"packet-painter/internal/geo"
+	"packet-painter/internal/trace"
+)
+
+// fakeRunner replays a fixed set of hops
+type fakeRunner struct {
+	hops []*trace.Hop
+	err  error
+}
+
+func (r *fakeRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop trace.HopCallback, onComplete trace.CompletedCallback, onError trace.ErrorCallback) error {
+	for _, hop := range r.hops {
+		onHop(hop)
+	}
+	if r.err != nil {
+		onError(r.err)
+		return r.err
+	}
+	onComplete(len(r.hops))
+	return nil
+}
+
+// collect drains a subscription into a slice
+func collect(sub *Subscription) []Event {
+	var got []Event
+	for e := range sub.Events() {
+		got = append(got, e)
+	}
+	return got
+}
+
+// names returns the event names in order
+func names(list []Event) []string {
+	out := make([]string, len(list))
+	for i, e := range list {
+		out[i] = e.Name
+	}
+	return out
+}
+
+func TestPublishSequence(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe(SubscribeOptions{})
+	one := bus.Subscribe(SubscribeOptions{SessionID: "a"})
+
+	bus.Publish(trace.TraceStartedEvent{SessionID: "a"})
+	bus.Publish(trace.TraceStartedEvent{SessionID: "b"})
+	bus.Publish(trace.TraceHopEvent{SessionID: "a", Hop: &trace.Hop{HopNumber: 1}})
+	bus.Close()
+
+	got := collect(all)
+	if len(got) != 3 {
+		t.Fatalf("got %d events, want 3", len(got))
+	}
+	for i, e := range got {
+		if e.Seq != uint64(i+1) {
+			t.Errorf("event %d seq = %d, want %d", i, e.Seq, i+1)
+		}
+	}
+
+	filtered := collect(one)
+	if len(filtered) != 2 || filtered[0].Seq != 1 || filtered[1].Seq != 3 {
+		t.Errorf("session subscriber got %v", filtered)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	bus := NewBus()
+	bus.Publish(trace.TraceStartedEvent{SessionID: "old"})
+	bus.Publish(trace.TraceCompletedEvent{SessionID: "old"})
+	bus.Publish(trace.TraceStartedEvent{SessionID: "a"})
+	bus.Publish(trace.TraceHopEvent{SessionID: "a", Hop: &trace.Hop{HopNumber: 1}})
+	bus.Publish(trace.TraceHopEvent{SessionID: "a", Hop: &trace.Hop{HopNumber: 2}})
+
+	tests := []struct {
+		name     string
+		opts     SubscribeOptions
+		expected []uint64
+	}{
+		{"session", SubscribeOptions{SessionID: "a", Replay: true}, []uint64{3, 4, 5, 6}},
+		{"after seq", SubscribeOptions{SessionID: "a", Replay: true, AfterSeq: 4}, []uint64{5, 6}},
+		{"finished session", SubscribeOptions{SessionID: "old", Replay: true}, []uint64{1, 2}},
+		{"running sessions", SubscribeOptions{Replay: true}, []uint64{3, 4, 5, 6}},
+		{"no replay", SubscribeOptions{SessionID: "a"}, []uint64{6}},
+	}
+
+	var subs []*Subscription
+	for _, tt := range tests {
+		subs = append(subs, bus.Subscribe(tt.opts))
+	}
+	bus.Publish(trace.TraceCompletedEvent{SessionID: "a", TotalHops: 2})
+	bus.Close()
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(subs[i])
+			if len(got) != len(tt.expected) {
+				t.Fatalf("got %v, want seqs %v", names(got), tt.expected)
+			}
+			for j, e := range got {
+				if e.Seq != tt.expected[j] {
+					t.Errorf("event %d seq = %d, want %d", j, e.Seq, tt.expected[j])
+				}
+			}
+		})
+	}
+}
+
+func TestReplayRetention(t *testing.T) {
+	bus := NewBus()
+	for i := 0; i <= retainedSessions; i++ {
+		id := string(rune('a' + i))
+		bus.Publish(trace.TraceStartedEvent{SessionID: id})
+		bus.Publish(trace.TraceCompletedEvent{SessionID: id})
+	}
+
+	if got := bus.Replay("a", 0); len(got) != 0 {
+		t.Errorf("oldest session still retained: %v", names(got))
+	}
+	if got := bus.Replay("b", 0); len(got) != 2 {
+		t.Errorf("Replay(b) = %v, want 2 events", names(got))
+	}
+}
+
+func TestSubscriberBuffer(t *testing.T) {
+	bus := NewBus()
+	slow := bus.Subscribe(SubscribeOptions{Buffer: 2})
+	fast := bus.Subscribe(SubscribeOptions{})
+
+	for i := 1; i <= 5; i++ {
+		bus.Publish(trace.TraceHopEvent{SessionID: "a", Hop: &trace.Hop{HopNumber: i}})
+	}
+	bus.Close()
+
+	if got := collect(slow); len(got) != 2 || got[1].Seq != 2 {
+		t.Errorf("slow subscriber got %d events", len(got))
+	}
+	if slow.Dropped() != 3 {
+		t.Errorf("Dropped() = %d, want 3", slow.Dropped())
+	}
+	if got := collect(fast); len(got) != 5 {
+		t.Errorf("fast subscriber got %d events, want 5", len(got))
+	}
+}
+
+func TestHandleDrainsOnClose(t *testing.T) {
+	bus := NewBus()
+	var mu sync.Mutex
+	var seen []uint64
+	release := make(chan struct{})
+	bus.Handle(SubscribeOptions{}, func(e Event) {
+		<-release
+		mu.Lock()
+		seen = append(seen, e.Seq)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 3; i++ {
+		bus.Publish(trace.TraceHopEvent{SessionID: "a", Hop: &trace.Hop{HopNumber: i}})
+	}
+	close(release)
+	bus.Close()
+
+	if len(seen) != 3 {
+		t.Errorf("handler saw %v, want 3 events", seen)
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	bus := NewBus()
+	e := bus.Publish(trace.TraceCompletedEvent{SessionID: "a", TotalHops: 4, Outcome: trace.PathReached, Reason: trace.ReasonDestination, Timestamp: 10})
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"seq":1,"sessionId":"a","totalHops":4,"outcome":"reached","reason":"destination","timestamp":10}`
+	if string(data) != expected {
+		t.Errorf("got %s, want %s", data, expected)
+	}
+}
+
+func TestRun(t *testing.T) {
+	hops := []*trace.Hop{
+		{HopNumber: 1, IPAddress: "192.168.1.1", AvgRTT: 1, MTU: 1500},
+		{HopNumber: 2, IPAddress: "8.8.8.8", AvgRTT: 12, IsDestination: true, MTU: 1420},
+	}
+
+	tests := []struct {
+		name     string
+		runner   *fakeRunner
+		expected []string
+		outcome  trace.Outcome
+	}{
+		{"completed", &fakeRunner{hops: hops}, []string{"trace:started", "trace:hop", "trace:hop", "trace:completed"}, trace.OutcomeCompleted},
+		{"error", &fakeRunner{hops: hops[:1], err: errors.New("boom")}, []string{"trace:started", "trace:hop", "trace:error"}, trace.OutcomeError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewBus()
+			var finished []*trace.Result
+			recorder := NewRecorder(func(result *trace.Result) {
+				finished = append(finished, result)
+			})
+			bus.Handle(SubscribeOptions{}, recorder.Handle)
+			sub := bus.Subscribe(SubscribeOptions{})
+
+			session := trace.NewSessionWithRunner("8.8.8.8", trace.DefaultOptions(), tt.runner)
+			result, _ := Run(context.Background(), bus, session)
+			bus.Close()
+
+			published := collect(sub)
+			got := names(published)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("events = %v, want %v", got, tt.expected)
+			}
+			for _, e := range published {
+				if completed, ok := e.Payload.(trace.TraceCompletedEvent); ok && completed.PathMTU != 1420 {
+					t.Errorf("completed PathMTU = %d, want 1420", completed.PathMTU)
+				}
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("events[%d] = %s, want %s", i, got[i], tt.expected[i])
+				}
+			}
+
+			if len(finished) != 1 {
+				t.Fatalf("recorder finished %d results, want 1", len(finished))
+			}
+			recorded := finished[0]
+			if recorded.Outcome != tt.outcome || result.Outcome != tt.outcome {
+				t.Errorf("outcome = %s (recorded %s), want %s", result.Outcome, recorded.Outcome, tt.outcome)
+			}
+			if len(recorded.Hops) != len(tt.runner.hops) || recorded.Target != "8.8.8.8" || recorded.Options.MaxHops == 0 {
+				t.Errorf("recorded result = %+v", recorded)
+			}
+			if snapshot, ok := recorder.Snapshot(session.ID); !ok || snapshot.Outcome != tt.outcome {
+				t.Errorf("Snapshot() = %v, %v", snapshot, ok)
+			}
+		})
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	for name, want := range map[string]bool{
+		trace.EventStarted:   false,
+		trace.EventHop:       false,
+		trace.EventCompleted: true,
+		trace.EventCancelled: true,
+		trace.EventError:     true,
+	} {
+		if got := IsFinal(name); got != want {
+			t.Errorf("IsFinal(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
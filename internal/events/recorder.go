@@ -0,0 +1,114 @@
+package events
+
+import (
+	"sync"
+
+	"packet-painter/internal/trace"
+)
+
+// retainedResults is how many finished results a Recorder keeps for Snapshot
+const retainedResults = 8
+
+// Recorder is a subscriber that assembles trace results from bus events
+// It lets stores and exporters consume finished traces without touching the runner
+type Recorder struct {
+	mu       sync.Mutex
+	active   map[string]*trace.Result
+	finished []*trace.Result // Most recent last
+	onFinish func(*trace.Result)
+}
+
+// NewRecorder creates a recorder that calls onFinish with each finished result
+// onFinish may be nil
+func NewRecorder(onFinish func(*trace.Result)) *Recorder {
+	return &Recorder{
+		active:   make(map[string]*trace.Result),
+		onFinish: onFinish,
+	}
+}
+
+// Handle applies one event; pass it to Bus.Handle
+func (r *Recorder) Handle(e Event) {
+	r.mu.Lock()
+	result := r.apply(e)
+	r.mu.Unlock()
+
+	if result != nil && r.onFinish != nil {
+		r.onFinish(result)
+	}
+}
+
+// apply updates the recorded results and returns a copy of the result the event finished, if any
+func (r *Recorder) apply(e Event) *trace.Result {
+	if started, ok := e.Payload.(trace.TraceStartedEvent); ok {
+		r.active[e.SessionID] = &trace.Result{
+			SessionID: started.SessionID,
+			Target:    started.Target,
+			Options:   started.Options,
+			Source:    started.Source,
+			StartedAt: started.Timestamp,
+			Outcome:   trace.OutcomeCancelled,
+		}
+		return nil
+	}
+
+	// Events of sessions that are unknown or already finished are ignored
+	result, ok := r.active[e.SessionID]
+	if !ok {
+		return nil
+	}
+
+	var endedAt int64
+	switch p := e.Payload.(type) {
+	case trace.TraceHopEvent:
+		result.Hops = append(result.Hops, p.Hop)
+		return nil
+	case trace.TraceCompletedEvent:
+		result.Outcome = trace.OutcomeCompleted
+		result.PathOutcome = p.Outcome
+		result.StopReason = p.Reason
+		endedAt = p.Timestamp
+	case trace.TraceCancelledEvent:
+		result.Outcome = trace.OutcomeCancelled
+		endedAt = p.Timestamp
+	case trace.TraceErrorEvent:
+		result.Outcome = trace.OutcomeError
+		result.Error = p.Error
+		result.ErrorCode = p.Code
+		endedAt = p.Timestamp
+	default:
+		return nil
+	}
+
+	result.EndedAt = endedAt
+	result.DurationMs = endedAt - result.StartedAt
+	delete(r.active, e.SessionID)
+	r.finished = append(r.finished, result)
+	if len(r.finished) > retainedResults {
+		r.finished = r.finished[1:]
+	}
+	return copyResult(result)
+}
+
+// Snapshot returns a copy of a running or recently finished session's result
+func (r *Recorder) Snapshot(sessionID string) (*trace.Result, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if result, ok := r.active[sessionID]; ok {
+		return copyResult(result), true
+	}
+	for i := len(r.finished) - 1; i >= 0; i-- {
+		if r.finished[i].SessionID == sessionID {
+			return copyResult(r.finished[i]), true
+		}
+	}
+	return nil, false
+}
+
+// copyResult copies a result so later hops don't change what callers hold
+func copyResult(result *trace.Result) *trace.Result {
+	c := *result
+	c.Hops = append([]*trace.Hop(nil), result.Hops...)
+	return &c
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"packet-painter/internal/trace"
+)
+
+// Run publishes a session's lifecycle to the bus while running it to completion
+// Exactly one final event (completed, cancelled or error) follows the hops
+func Run(ctx context.Context, bus *Bus, session *trace.Session) (*trace.Result, error) {
+	bus.Publish(trace.TraceStartedEvent{
+		SessionID: session.ID,
+		Target:    session.Target,
+		Options:   session.Options,
+		Source:    session.GetSource(),
+		Timestamp: time.Now().UnixMilli(),
+	})
+
+	result, err := session.Run(ctx, func(hop *trace.Hop) {
+		bus.Publish(trace.TraceHopEvent{SessionID: session.ID, Hop: hop})
+	})
+	publishOutcome(bus, result, err)
+	return result, err
+}
+
+// PublishResult replays a finished result, such as an imported trace, as bus events
+func PublishResult(bus *Bus, result *trace.Result) {
+	bus.Publish(trace.TraceStartedEvent{
+		SessionID: result.SessionID,
+		Target:    result.Target,
+		Options:   result.Options,
+		Source:    result.Source,
+		Timestamp: result.StartedAt,
+	})
+	for _, hop := range result.Hops {
+		bus.Publish(trace.TraceHopEvent{SessionID: result.SessionID, Hop: hop})
+	}
+	publishOutcome(bus, result, nil)
+}
+
+// publishOutcome publishes the final event matching a result's outcome
+// err is the run's error, which carries the remediation of a failure
+func publishOutcome(bus *Bus, result *trace.Result, err error) {
+	switch result.Outcome {
+	case trace.OutcomeCompleted:
+		outcome, reason := result.Path()
+		bus.Publish(trace.TraceCompletedEvent{
+			SessionID: result.SessionID,
+			TotalHops: len(result.Hops),
+			Outcome:   outcome,
+			Reason:    reason,
+			PathMTU:   result.PathMTU(),
+			Tunnels:   result.MPLSTunnels(),
+			Timestamp: result.EndedAt,
+		})
+	case trace.OutcomeCancelled:
+		bus.Publish(trace.TraceCancelledEvent{
+			SessionID: result.SessionID,
+			Timestamp: result.EndedAt,
+		})
+	default:
+		event := trace.TraceErrorEvent{
+			SessionID: result.SessionID,
+			Error:     result.Error,
+			Code:      result.ErrorCode,
+			Timestamp: result.EndedAt,
+		}
+		if te := trace.AsTraceError(err); te != nil {
+			event.Code = te.Code
+			event.Remediation = te.Remediation
+		}
+		bus.Publish(event)
+	}
+}
@@ -0,0 +1,105 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"packet-painter/internal/trace"
+)
+
+// csvHeader lists the CSV columns in order
+var csvHeader = []string{
+	"session_id", "target", "hop", "probe", "ip", "hostname",
+	"rtt_ms", "avg_rtt_ms", "timeout", "destination",
+	"city", "region", "country", "country_code", "latitude", "longitude",
+	"isp", "org", "provider",
+	"sent", "loss_percent", "best_ms", "worst_ms", "stddev_ms",
+}
+
+// WriteCSV writes one row per hop, or one row per probe when perProbe is set
+// In per-hop mode the probe column is empty and rtt_ms holds the last probe.
+// Hops measured by mtr or pathping only carry their statistics, so they get
+// a single row in per-probe mode too
+func WriteCSV(w io.Writer, result *trace.Result, perProbe bool) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, hop := range result.Hops {
+		if perProbe && hop.Stats == nil && len(hop.RTT) > 0 {
+			for i, rtt := range hop.RTT {
+				if err := writer.Write(csvRow(result, hop, strconv.Itoa(i+1), formatFloat(rtt))); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		probe := ""
+		if perProbe && hop.Stats == nil {
+			// Timed-out hops still get a single row
+			probe = "1"
+		}
+		rtt := ""
+		if len(hop.RTT) > 0 {
+			rtt = formatFloat(hop.RTT[len(hop.RTT)-1])
+		}
+		if err := writer.Write(csvRow(result, hop, probe, rtt)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvRow builds a single CSV row for a hop
+func csvRow(result *trace.Result, hop *trace.Hop, probe, rtt string) []string {
+	avgRTT := ""
+	if !hop.IsTimeout {
+		avgRTT = formatFloat(hop.AvgRTT)
+	}
+
+	row := []string{
+		result.SessionID,
+		result.Target,
+		strconv.Itoa(hop.HopNumber),
+		probe,
+		hop.IPAddress,
+		hop.Hostname,
+		rtt,
+		avgRTT,
+		strconv.FormatBool(hop.IsTimeout),
+		strconv.FormatBool(hop.IsDestination),
+	}
+
+	if loc := hop.Location; loc != nil {
+		row = append(row,
+			loc.City, loc.Region, loc.Country, loc.CountryCode,
+			formatFloat(loc.Latitude), formatFloat(loc.Longitude),
+			loc.ISP, loc.Org,
+		)
+	} else {
+		row = append(row, "", "", "", "", "", "", "", "")
+	}
+
+	provider := ""
+	if hop.DataCenter != nil {
+		provider = hop.DataCenter.Provider
+	}
+	row = append(row, provider)
+
+	stats := hopStats(hop, result.Options.ProbesPerHop)
+	row = append(row, strconv.Itoa(stats.Sent), formatFloat(stats.LossPercent))
+	if stats.Received == 0 {
+		return append(row, "", "", "")
+	}
+	return append(row, formatFloat(stats.Best), formatFloat(stats.Worst), formatFloat(stats.StdDev))
+}
+
+// formatFloat formats a number without trailing zeros
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
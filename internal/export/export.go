@@ -0,0 +1,77 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"packet-painter/internal/trace"
+)
+
+// Format identifies an export output format
+type Format string
+
+const (
+	FormatJSON      Format = "json"       // Versioned JSON document
+	FormatCSV       Format = "csv"        // One row per hop
+	FormatCSVProbes Format = "csv-probes" // One row per probe
+	FormatText      Format = "text"       // mtr --report style text
+	FormatGeoJSON   Format = "geojson"    // Hop points and path line
+	FormatKML       Format = "kml"        // Time-animated placemarks
+)
+
+// formatInfo describes how each format is saved
+var formatInfo = map[Format]struct {
+	extension   string
+	description string
+}{
+	FormatJSON:      {"json", "JSON"},
+	FormatCSV:       {"csv", "CSV (one row per hop)"},
+	FormatCSVProbes: {"csv", "CSV (one row per probe)"},
+	FormatText:      {"txt", "mtr report"},
+	FormatGeoJSON:   {"geojson", "GeoJSON"},
+	FormatKML:       {"kml", "KML"},
+}
+
+// ParseFormat validates a format name
+func ParseFormat(name string) (Format, error) {
+	format := Format(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := formatInfo[format]; !ok {
+		return "", fmt.Errorf("unsupported export format: %q", name)
+	}
+	return format, nil
+}
+
+// Extension returns the file extension for a format, without the dot
+func (f Format) Extension() string {
+	return formatInfo[f].extension
+}
+
+// Description returns a human-readable name for a format
+func (f Format) Description() string {
+	return formatInfo[f].description
+}
+
+// Write renders a trace in the given format
+func Write(w io.Writer, result *trace.Result, format Format) error {
+	if result == nil {
+		return fmt.Errorf("no trace to export")
+	}
+
+	switch format {
+	case FormatJSON:
+		return WriteJSON(w, result)
+	case FormatCSV:
+		return WriteCSV(w, result, false)
+	case FormatCSVProbes:
+		return WriteCSV(w, result, true)
+	case FormatText:
+		return WriteReport(w, result)
+	case FormatGeoJSON:
+		return WriteGeoJSON(w, result)
+	case FormatKML:
+		return WriteKML(w, result)
+	default:
+		return fmt.Errorf("unsupported export format: %q", format)
+	}
+}
@@ -0,0 +1,271 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"packet-painter/internal/geo"
+	"packet-painter/internal/trace"
+)
+
+func testResult() *trace.Result {
+	return &trace.Result{
+		SessionID: "abc",
+		Target:    "google.com",
+		Options:   trace.Options{MaxHops: 30, ProbesPerHop: 3, WaitSeconds: 1},
+		StartedAt: 1700000000000,
+		EndedAt:   1700000005000,
+		Outcome:   trace.OutcomeCompleted,
+		Hops: []*trace.Hop{
+			{HopNumber: 1, IPAddress: "192.168.1.1", RTT: []float64{0.4, 0.6, 0.8}, AvgRTT: 0.6},
+			{HopNumber: 2, IPAddress: "*", IsTimeout: true},
+			{HopNumber: 3, IPAddress: "142.250.80.46", RTT: []float64{15, 16}, AvgRTT: 15.5, IsDestination: true},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testResult(), FormatJSON); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.SchemaVersion != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", doc.SchemaVersion, SchemaVersion)
+	}
+	if !doc.Session.Reached || len(doc.Hops) != 3 {
+		t.Errorf("document = %+v, want reached with 3 hops", doc.Session)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	tests := []struct {
+		format       Format
+		expectedRows int
+	}{
+		{FormatCSV, 3},
+		{FormatCSVProbes, 6}, // 3 + 1 timeout + 2
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, testResult(), tt.format); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("invalid CSV: %v", err)
+			}
+			if len(records)-1 != tt.expectedRows {
+				t.Errorf("got %d rows, want %d", len(records)-1, tt.expectedRows)
+			}
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testResult(), FormatText); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "Start: ") || !strings.Contains(lines[1], "Loss%   Snt   Last   Avg  Best  Wrst StDev") {
+		t.Errorf("unexpected header:\n%s\n%s", lines[0], lines[1])
+	}
+
+	expected := []struct {
+		prefix string
+		fields []string
+	}{
+		{"  1.|-- 192.168.1.1", []string{"0.0%", "3", "0.8", "0.6", "0.4", "0.8", "0.2"}},
+		{"  2.|-- ???", []string{"100.0", "3", "0.0"}},
+		{"  3.|-- 142.250.80.46", []string{"33.3%", "3", "16.0", "15.5", "15.0", "16.0", "0.5"}},
+	}
+	for i, want := range expected {
+		line := lines[i+2]
+		if !strings.HasPrefix(line, want.prefix) {
+			t.Errorf("line %d = %q, want prefix %q", i, line, want.prefix)
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, want.prefix))
+		for j, field := range want.fields {
+			if fields[j] != field {
+				t.Errorf("line %d column %d = %s, want %s", i, j, fields[j], field)
+			}
+		}
+	}
+}
+
+func TestImportedReportRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		prefix string
+		fields []string
+	}{
+		{
+			"mtr",
+			"Start: 2024-01-02T15:04:05+0000\n" +
+				"HOST: laptop                      Loss%   Snt   Last   Avg  Best  Wrst StDev\n" +
+				"  1.|-- 192.168.1.1                0.0%    10    0.5   0.6   0.4   0.8   0.1\n" +
+				"  2.|-- 142.250.80.46             10.0%    10   15.6  15.8  15.2  16.9   0.5\n",
+			"  2.|-- 142.250.80.46",
+			[]string{"10.0%", "10", "15.6", "15.8", "15.2", "16.9", "0.5"},
+		},
+		{
+			"pathping",
+			"Tracing route to 142.250.80.46 over a maximum of 30 hops\n\n" +
+				"  0  DESKTOP-1234 [192.168.1.10]\n" +
+				"  1  192.168.1.1\n" +
+				"  2  142.250.80.46\n\n" +
+				"Computing statistics for 50 seconds...\n" +
+				"            Source to Here   This Node/Link\n" +
+				"Hop  RTT    Lost/Sent = Pct  Lost/Sent = Pct  Address\n" +
+				"  1    1ms     0/ 100 =  0%     0/ 100 =  0%  192.168.1.1\n" +
+				"  2   15ms     0/ 100 =  0%     0/ 100 =  0%  142.250.80.46\n\n" +
+				"Trace complete.\n",
+			"  2.|-- 142.250.80.46",
+			[]string{"0.0%", "100", "15.0", "15.0", "15.0", "15.0", "0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, err := trace.Import(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			var buf bytes.Buffer
+			if err := WriteReport(&buf, result); err != nil {
+				t.Fatalf("WriteReport() error = %v", err)
+			}
+
+			var line string
+			for _, l := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(l, tt.prefix) {
+					line = l
+				}
+			}
+			if line == "" {
+				t.Fatalf("no line for %q in:\n%s", tt.prefix, buf.String())
+			}
+			fields := strings.Fields(strings.TrimPrefix(line, tt.prefix))
+			if strings.Join(fields, " ") != strings.Join(tt.fields, " ") {
+				t.Errorf("report columns = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestWriteCSVStats(t *testing.T) {
+	result := testResult()
+	result.Hops[0].Stats = &trace.ProbeStats{Sent: 10, Received: 9, LossPercent: 10, Last: 0.8, Avg: 0.6, Best: 0.4, Worst: 0.9, StdDev: 0.1}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, result, true); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+
+	// The hop with statistics gets a single row in per-probe mode
+	if len(records)-1 != 4 {
+		t.Fatalf("got %d rows, want 4", len(records)-1)
+	}
+	row := records[1]
+	got := strings.Join(row[len(row)-5:], ",")
+	if want := "10,10,0.4,0.9,0.1"; got != want {
+		t.Errorf("stats columns = %s, want %s", got, want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(xml) expected error")
+	}
+	if format, err := ParseFormat(" JSON "); err != nil || format != FormatJSON {
+		t.Errorf("ParseFormat(JSON) = %s, %v", format, err)
+	}
+}
+
+func TestWriteGeoJSONAndKMLSkipUnlocatedHops(t *testing.T) {
+	result := testResult()
+	result.Hops[0].Location = &geo.Location{Latitude: 37.77, Longitude: -122.42, City: "San Francisco"}
+	result.Hops[2].Location = &geo.Location{Latitude: 35.68, Longitude: 139.65, City: "Tokyo"}
+	result.Hops = append(result.Hops, &trace.Hop{HopNumber: 4, IPAddress: "10.0.0.1", AvgRTT: 20})
+
+	var buf bytes.Buffer
+	if err := Write(&buf, result, FormatGeoJSON); err != nil {
+		t.Fatalf("Write(geojson) error = %v", err)
+	}
+
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("invalid GeoJSON: %v", err)
+	}
+	if len(collection.Features) != 5 {
+		t.Fatalf("got %d features, want 4 hops + path", len(collection.Features))
+	}
+	points := 0
+	for _, feature := range collection.Features[:4] {
+		if feature.Geometry != nil {
+			points++
+		}
+	}
+	if points != 2 {
+		t.Errorf("got %d located hop points, want 2", points)
+	}
+	path := collection.Features[4]
+	if path.Geometry.Type != "LineString" || len(path.Geometry.Coordinates.([]interface{})) != 2 {
+		t.Errorf("path = %+v, want LineString through 2 located hops", path.Geometry)
+	}
+
+	hop := result.Hops[2]
+	hop.ASN = "AS15169"
+	hop.MTU = 1500
+	hop.Unreachable = trace.UnreachableHost
+	hop.MPLS = []trace.MPLSLabel{{Label: 24001, BottomOfStack: true, TTL: 1}}
+	hop.Stats = &trace.ProbeStats{Sent: 10, Received: 9, LossPercent: 10, Avg: 15.5}
+	props := hopProperties(hop)
+	for _, key := range []string{"asn", "mtu", "unreachable", "mpls", "stats"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("hop properties missing %q", key)
+		}
+	}
+	if _, ok := hopProperties(result.Hops[0])["stats"]; ok {
+		t.Error("hop without stats should not carry a stats property")
+	}
+
+	buf.Reset()
+	if err := Write(&buf, result, FormatKML); err != nil {
+		t.Fatalf("Write(kml) error = %v", err)
+	}
+	kml := buf.String()
+	if got := strings.Count(kml, "<Placemark>"); got != 5 {
+		t.Errorf("got %d placemarks, want 4 hops + 1 path segment", got)
+	}
+	if got := strings.Count(kml, "<Point>"); got != 2 {
+		t.Errorf("got %d points, want 2", got)
+	}
+	if got := strings.Count(kml, "<TimeStamp>"); got != 4 {
+		t.Errorf("got %d timestamps, want one per hop", got)
+	}
+	if !strings.Contains(kml, "<color>ff5ec522</color>") {
+		t.Error("expected KML aabbggrr color for the excellent latency style")
+	}
+}
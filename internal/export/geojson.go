@@ -0,0 +1,170 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"packet-painter/internal/trace"
+)
+
+// geoJSONFeatureCollection is the GeoJSON export document
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// geoJSONFeature is a single GeoJSON feature
+// Geometry is nil for hops without a location, which GeoJSON allows
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONGeometry is a Point or LineString geometry
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// WriteGeoJSON writes the trace as a GeoJSON FeatureCollection
+// Every hop becomes a Point feature carrying all hop properties, followed by
+// a LineString feature for the path through the located hops
+func WriteGeoJSON(w io.Writer, result *trace.Result) error {
+	collection := geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []geoJSONFeature{},
+	}
+
+	for _, hop := range result.Hops {
+		feature := geoJSONFeature{
+			Type:       "Feature",
+			ID:         "hop-" + strconv.Itoa(hop.HopNumber),
+			Properties: hopProperties(hop),
+		}
+		if located(hop) {
+			feature.Geometry = &geoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{hop.Location.Longitude, hop.Location.Latitude},
+			}
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+
+	if path := pathCoordinates(result); len(path) >= 2 {
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type: "Feature",
+			ID:   "path",
+			Geometry: &geoJSONGeometry{
+				Type:        "LineString",
+				Coordinates: path,
+			},
+			Properties: map[string]interface{}{
+				"kind":      "path",
+				"sessionId": result.SessionID,
+				"target":    result.Target,
+				"outcome":   result.Outcome,
+				"reached":   result.Reached(),
+				"startedAt": result.StartedAt,
+				"endedAt":   result.EndedAt,
+				"totalHops": len(result.Hops),
+			},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(collection)
+}
+
+// hopProperties flattens a hop into GeoJSON feature properties
+func hopProperties(hop *trace.Hop) map[string]interface{} {
+	props := map[string]interface{}{
+		"kind":          "hop",
+		"hopNumber":     hop.HopNumber,
+		"ipAddress":     hop.IPAddress,
+		"rtt":           hop.RTT,
+		"avgRtt":        hop.AvgRTT,
+		"isTimeout":     hop.IsTimeout,
+		"isDestination": hop.IsDestination,
+		"timestamp":     hop.Timestamp,
+		"located":       located(hop),
+		"latencyColor":  latencyColor(hop),
+	}
+	if hop.Hostname != "" {
+		props["hostname"] = hop.Hostname
+	}
+	if loc := hop.Location; loc != nil {
+		props["city"] = loc.City
+		props["region"] = loc.Region
+		props["country"] = loc.Country
+		props["countryCode"] = loc.CountryCode
+		props["isp"] = loc.ISP
+		props["org"] = loc.Org
+	}
+	if hop.DataCenter != nil {
+		props["provider"] = hop.DataCenter.Provider
+	}
+	if hop.ASN != "" {
+		props["asn"] = hop.ASN
+	}
+	if len(hop.ASNConflicts) > 0 {
+		props["asnConflicts"] = hop.ASNConflicts
+	}
+	if hop.Stats != nil {
+		props["stats"] = hop.Stats
+	}
+	if hop.MTU > 0 {
+		props["mtu"] = hop.MTU
+	}
+	if hop.ReturnHops > 0 {
+		props["returnHops"] = hop.ReturnHops
+	}
+	if hop.Unreachable != "" {
+		props["unreachable"] = hop.Unreachable
+	}
+	if len(hop.MPLS) > 0 {
+		props["mpls"] = hop.MPLS
+	}
+	return props
+}
+
+// pathCoordinates returns [lng, lat] pairs for the source and every located hop
+// Unlocated hops are skipped, so the path joins their located neighbours
+func pathCoordinates(result *trace.Result) [][]float64 {
+	var path [][]float64
+	if result.Source != nil {
+		path = append(path, []float64{result.Source.Longitude, result.Source.Latitude})
+	}
+	for _, hop := range result.Hops {
+		if located(hop) {
+			path = append(path, []float64{hop.Location.Longitude, hop.Location.Latitude})
+		}
+	}
+	return path
+}
+
+// located reports whether a hop has a usable location
+func located(hop *trace.Hop) bool {
+	return hop.Location != nil && !hop.IsTimeout
+}
+
+// latencyColor returns the hop color used on the globe, as #rrggbb
+func latencyColor(hop *trace.Hop) string {
+	switch {
+	case hop.IsTimeout:
+		return "#6b7280" // Gray
+	case hop.AvgRTT < 50:
+		return "#22c55e" // Green - excellent
+	case hop.AvgRTT < 100:
+		return "#84cc16" // Lime - good
+	case hop.AvgRTT < 150:
+		return "#eab308" // Yellow - moderate
+	case hop.AvgRTT < 200:
+		return "#f97316" // Orange - slow
+	default:
+		return "#ef4444" // Red - very slow
+	}
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"packet-painter/internal/geo"
+	"packet-painter/internal/trace"
+)
+
+// SchemaVersion is bumped whenever the JSON document changes incompatibly
+const SchemaVersion = 1
+
+// Document is the top-level JSON export
+type Document struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	Generator     string       `json:"generator"`
+	ExportedAt    int64        `json:"exportedAt"`
+	Session       SessionInfo  `json:"session"`
+	Hops          []*trace.Hop `json:"hops"`
+}
+
+// SessionInfo holds everything about a trace except its hops
+type SessionInfo struct {
+	ID         string          `json:"id"`
+	Target     string          `json:"target"`
+	Options    trace.Options   `json:"options"`
+	Source     *geo.Location   `json:"source"`
+	StartedAt  int64           `json:"startedAt"`
+	EndedAt    int64           `json:"endedAt"`
+	DurationMs int64           `json:"durationMs"`
+	Outcome    trace.Outcome   `json:"outcome"`
+	Reached    bool            `json:"reached"`
+	Error      string          `json:"error,omitempty"`
+	ErrorCode  trace.ErrorCode `json:"errorCode,omitempty"`
+}
+
+// NewDocument builds the JSON export document for a trace
+func NewDocument(result *trace.Result) *Document {
+	hops := result.Hops
+	if hops == nil {
+		hops = []*trace.Hop{}
+	}
+
+	return &Document{
+		SchemaVersion: SchemaVersion,
+		Generator:     "packet-painter",
+		ExportedAt:    time.Now().UnixMilli(),
+		Session: SessionInfo{
+			ID:         result.SessionID,
+			Target:     result.Target,
+			Options:    result.Options,
+			Source:     result.Source,
+			StartedAt:  result.StartedAt,
+			EndedAt:    result.EndedAt,
+			DurationMs: result.DurationMs,
+			Outcome:    result.Outcome,
+			Reached:    result.Reached(),
+			Error:      result.Error,
+			ErrorCode:  result.ErrorCode,
+		},
+		Hops: hops,
+	}
+}
+
+// WriteJSON writes the versioned JSON document
+func WriteJSON(w io.Writer, result *trace.Result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(NewDocument(result))
+}
@@ -0,0 +1,242 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"packet-painter/internal/trace"
+)
+
+// kmlDocument is the root KML element
+type kmlDocument struct {
+	XMLName  xml.Name `xml:"kml"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Document kmlBody  `xml:"Document"`
+}
+
+// kmlBody holds the styles and placemarks
+type kmlBody struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	Styles      []kmlStyle     `xml:"Style"`
+	Placemarks  []kmlPlacemark `xml:"Placemark"`
+}
+
+// kmlStyle defines an icon and line color
+type kmlStyle struct {
+	ID        string        `xml:"id,attr"`
+	IconStyle *kmlIconStyle `xml:"IconStyle,omitempty"`
+	LineStyle *kmlLineStyle `xml:"LineStyle,omitempty"`
+}
+
+type kmlIconStyle struct {
+	Color string  `xml:"color"`
+	Scale float64 `xml:"scale"`
+}
+
+type kmlLineStyle struct {
+	Color string  `xml:"color"`
+	Width float64 `xml:"width"`
+}
+
+// kmlPlacemark is a hop or path segment
+// Point and LineString are nil for hops without a location
+type kmlPlacemark struct {
+	Name         string           `xml:"name"`
+	Description  string           `xml:"description,omitempty"`
+	TimeStamp    *kmlTimeStamp    `xml:"TimeStamp,omitempty"`
+	TimeSpan     *kmlTimeSpan     `xml:"TimeSpan,omitempty"`
+	StyleURL     string           `xml:"styleUrl"`
+	ExtendedData *kmlExtendedData `xml:"ExtendedData,omitempty"`
+	Point        *kmlPoint        `xml:"Point,omitempty"`
+	LineString   *kmlLineString   `xml:"LineString,omitempty"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlTimeSpan struct {
+	Begin string `xml:"begin"`
+}
+
+type kmlExtendedData struct {
+	Data []kmlData `xml:"Data"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+// kmlLatencyStyles maps each globe latency color to a KML style ID
+var kmlLatencyStyles = []struct {
+	id    string
+	color string
+}{
+	{"timeout", "#6b7280"},
+	{"excellent", "#22c55e"},
+	{"good", "#84cc16"},
+	{"moderate", "#eab308"},
+	{"slow", "#f97316"},
+	{"very-slow", "#ef4444"},
+}
+
+// WriteKML writes the trace as KML with latency-colored placemarks
+// Every placemark carries a time so Google Earth can animate the trace hop
+// by hop. Hops without a location are kept as placemarks without geometry
+// and skipped by the path, matching the GeoJSON export
+func WriteKML(w io.Writer, result *trace.Result) error {
+	doc := kmlDocument{
+		Xmlns: "http://www.opengis.net/kml/2.2",
+		Document: kmlBody{
+			Name:        "Traceroute to " + result.Target,
+			Description: fmt.Sprintf("%d hops, outcome %s", len(result.Hops), result.Outcome),
+		},
+	}
+
+	for _, style := range kmlLatencyStyles {
+		color := kmlColor(style.color)
+		doc.Document.Styles = append(doc.Document.Styles, kmlStyle{
+			ID:        style.id,
+			IconStyle: &kmlIconStyle{Color: color, Scale: 1.1},
+			LineStyle: &kmlLineStyle{Color: color, Width: 3},
+		})
+	}
+
+	// Hop placemarks
+	for _, hop := range result.Hops {
+		placemark := kmlPlacemark{
+			Name:         fmt.Sprintf("Hop %d: %s", hop.HopNumber, hop.IPAddress),
+			Description:  hopDescription(hop),
+			TimeStamp:    &kmlTimeStamp{When: kmlTime(hopTime(hop, result))},
+			StyleURL:     "#" + latencyStyleID(hop),
+			ExtendedData: hopExtendedData(hop),
+		}
+		if located(hop) {
+			placemark.Point = &kmlPoint{Coordinates: kmlCoordinate(hop.Location.Longitude, hop.Location.Latitude)}
+		}
+		doc.Document.Placemarks = append(doc.Document.Placemarks, placemark)
+	}
+
+	// Path segments appear as each hop is reached and stay visible afterwards
+	prevCoord := ""
+	if result.Source != nil {
+		prevCoord = kmlCoordinate(result.Source.Longitude, result.Source.Latitude)
+	}
+	for _, hop := range result.Hops {
+		if !located(hop) {
+			continue
+		}
+		coord := kmlCoordinate(hop.Location.Longitude, hop.Location.Latitude)
+		if prevCoord != "" {
+			doc.Document.Placemarks = append(doc.Document.Placemarks, kmlPlacemark{
+				Name:       fmt.Sprintf("Path to hop %d", hop.HopNumber),
+				TimeSpan:   &kmlTimeSpan{Begin: kmlTime(hopTime(hop, result))},
+				StyleURL:   "#" + latencyStyleID(hop),
+				LineString: &kmlLineString{Tessellate: 1, Coordinates: prevCoord + " " + coord},
+			})
+		}
+		prevCoord = coord
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// hopDescription summarizes a hop for the placemark balloon
+func hopDescription(hop *trace.Hop) string {
+	if hop.IsTimeout {
+		return "Request timed out"
+	}
+
+	var parts []string
+	if hop.Hostname != "" {
+		parts = append(parts, hop.Hostname)
+	}
+	parts = append(parts, fmt.Sprintf("Avg RTT %.1f ms", hop.AvgRTT))
+	if loc := hop.Location; loc != nil {
+		parts = append(parts, strings.Trim(loc.City+", "+loc.Country, ", "))
+		if loc.ISP != "" {
+			parts = append(parts, loc.ISP)
+		}
+	} else {
+		parts = append(parts, "Location unknown")
+	}
+	if hop.DataCenter != nil {
+		parts = append(parts, hop.DataCenter.Provider)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// hopExtendedData exposes the hop properties as KML data fields
+func hopExtendedData(hop *trace.Hop) *kmlExtendedData {
+	data := &kmlExtendedData{}
+	for key, value := range hopProperties(hop) {
+		data.Data = append(data.Data, kmlData{Name: key, Value: fmt.Sprint(value)})
+	}
+	// Map iteration order is random; keep the output stable
+	sort.Slice(data.Data, func(i, j int) bool {
+		return data.Data[i].Name < data.Data[j].Name
+	})
+	return data
+}
+
+// latencyStyleID returns the KML style for a hop's latency
+func latencyStyleID(hop *trace.Hop) string {
+	color := latencyColor(hop)
+	for _, style := range kmlLatencyStyles {
+		if style.color == color {
+			return style.id
+		}
+	}
+	return "timeout"
+}
+
+// hopTime returns when a hop was discovered, falling back to the trace start
+func hopTime(hop *trace.Hop, result *trace.Result) time.Time {
+	if hop.Timestamp > 0 {
+		return time.UnixMilli(hop.Timestamp)
+	}
+	return time.UnixMilli(result.StartedAt)
+}
+
+// kmlTime formats a time as a KML dateTime
+func kmlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// kmlCoordinate formats a lng,lat pair
+func kmlCoordinate(lng, lat float64) string {
+	return fmt.Sprintf("%s,%s", formatFloat(lng), formatFloat(lat))
+}
+
+// kmlColor converts #rrggbb into KML's aabbggrr
+func kmlColor(hex string) string {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return "ffffffff"
+	}
+	return "ff" + hex[4:6] + hex[2:4] + hex[0:2]
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"packet-painter/internal/trace"
+)
+
+// WriteReport writes a plain-text report in the `mtr --report` layout
+//
+//	Start: 2024-01-02T15:04:05+0000
+//	HOST: laptop                      Loss%   Snt   Last   Avg  Best  Wrst StDev
+//	  1.|-- 192.168.1.1                0.0%     3    0.5   0.6   0.4   0.8   0.2
+//	  2.|-- ???                       100.0     3    0.0   0.0   0.0   0.0   0.0
+func WriteReport(w io.Writer, result *trace.Result) error {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	start := time.UnixMilli(result.StartedAt)
+	if _, err := fmt.Fprintf(w, "Start: %s\n", start.Format("2006-01-02T15:04:05-0700")); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "HOST: %-28s Loss%%   Snt   Last   Avg  Best  Wrst StDev\n", host); err != nil {
+		return err
+	}
+
+	for _, hop := range result.Hops {
+		if _, err := fmt.Fprintln(w, reportLine(hop, result.Options.ProbesPerHop)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportLine formats a single hop row
+func reportLine(hop *trace.Hop, probesPerHop int) string {
+	name := hop.IPAddress
+	if hop.Hostname != "" {
+		name = hop.Hostname
+	}
+	if hop.IsTimeout || name == "" || name == "*" {
+		name = "???"
+	}
+
+	stats := hopStats(hop, probesPerHop)
+
+	// mtr drops the percent sign when loss is 100% to keep the column width
+	lossCol := fmt.Sprintf("%5.1f%%", stats.LossPercent)
+	if stats.LossPercent >= 100 {
+		lossCol = fmt.Sprintf("%6.1f", stats.LossPercent)
+	}
+
+	return fmt.Sprintf("%3d.|-- %-28s %s %5d %6.1f %5.1f %5.1f %5.1f %5.1f",
+		hop.HopNumber, name, lossCol, stats.Sent, stats.Last, stats.Avg, stats.Best, stats.Worst, stats.StdDev)
+}
+
+// hopStats returns the statistics a backend such as mtr or pathping reported
+// for a hop, or computes them from the hop's RTTs
+// probesPerHop is how many probes were sent when the hop has no ProbeStats
+func hopStats(hop *trace.Hop, probesPerHop int) trace.ProbeStats {
+	if hop.Stats != nil {
+		return *hop.Stats
+	}
+
+	sent := probesPerHop
+	if len(hop.RTT) > sent {
+		sent = len(hop.RTT)
+	}
+	if sent == 0 {
+		sent = 1
+	}
+	stats := trace.ProbeStats{
+		Sent:        sent,
+		Received:    len(hop.RTT),
+		LossPercent: float64(sent-len(hop.RTT)) / float64(sent) * 100,
+	}
+	stats.Last, stats.Avg, stats.Best, stats.Worst, stats.StdDev = rttStats(hop.RTT)
+	return stats
+}
+
+// rttStats computes the mtr statistics columns for a set of RTTs
+func rttStats(rtts []float64) (last, avg, best, worst, stdDev float64) {
+	if len(rtts) == 0 {
+		return 0, 0, 0, 0, 0
+	}
+
+	last = rtts[len(rtts)-1]
+	best = rtts[0]
+	worst = rtts[0]
+	sum := 0.0
+	for _, rtt := range rtts {
+		sum += rtt
+		best = math.Min(best, rtt)
+		worst = math.Max(worst, rtt)
+	}
+	avg = sum / float64(len(rtts))
+
+	variance := 0.0
+	for _, rtt := range rtts {
+		variance += (rtt - avg) * (rtt - avg)
+	}
+	stdDev = math.Sqrt(variance / float64(len(rtts)))
+
+	return last, avg, best, worst, stdDev
+}
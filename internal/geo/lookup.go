@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,6 +20,7 @@ type Location struct {
 	CountryCode string  `json:"countryCode,omitempty"`
 	ISP         string  `json:"isp,omitempty"`
 	Org         string  `json:"org,omitempty"`
+	ASN         string  `json:"asn,omitempty"` // e.g. "AS15169"; input to trace.mergeASN only, read Hop.ASN instead
 }
 
 // apiResponse represents the response from ip-api.com
@@ -32,6 +34,7 @@ type apiResponse struct {
 	Lon         float64 `json:"lon"`
 	ISP         string  `json:"isp"`
 	Org         string  `json:"org"`
+	AS          string  `json:"as"` // e.g. "AS15169 Google LLC"
 }
 
 // Lookup provides IP geolocation with caching
@@ -116,9 +119,20 @@ func (l *Lookup) fetchFromAPI(ip string) *Location {
 		CountryCode: data.CountryCode,
 		ISP:         data.ISP,
 		Org:         data.Org,
+		ASN:         parseASN(data.AS),
 	}
 }
 
+// parseASN extracts the AS number from ip-api's "as" field
+// Example: "AS15169 Google LLC" -> "AS15169"
+func parseASN(as string) string {
+	fields := strings.Fields(as)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "AS") {
+		return ""
+	}
+	return fields[0]
+}
+
 // isPrivateIP checks if an IP address is private/reserved
 func isPrivateIP(ipStr string) bool {
 	// Handle empty or wildcard
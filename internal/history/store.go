@@ -0,0 +1,438 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"packet-painter/internal/trace"
+)
+
+// Summary describes a stored trace without its hops, for listing
+type Summary struct {
+	ID         string        `json:"id"`
+	Target     string        `json:"target"`
+	StartedAt  int64         `json:"startedAt"`
+	EndedAt    int64         `json:"endedAt"`
+	DurationMs int64         `json:"durationMs"`
+	Outcome    trace.Outcome `json:"outcome"`
+	HopCount   int           `json:"hopCount"`
+	Reached    bool          `json:"reached"`
+}
+
+// Filter narrows the traces returned by List
+// Zero values match everything
+type Filter struct {
+	Target  string        `json:"target"`  // Case-insensitive substring match
+	From    int64         `json:"from"`    // Unix millis, inclusive
+	To      int64         `json:"to"`      // Unix millis, inclusive
+	Outcome trace.Outcome `json:"outcome"` // Exact match
+	Limit   int           `json:"limit"`
+}
+
+var (
+	// ErrNotFound is returned when a trace ID is unknown
+	ErrNotFound = errors.New("trace not found")
+	// ErrClosed is returned when saving to a closed store
+	ErrClosed = errors.New("history store is closed")
+)
+
+// indexFile holds the summary of every stored trace, so opening the store
+// does not decode each trace in full. It is rebuilt from the trace files
+// when missing, and entries whose file has gone are dropped
+const indexFile = "index.summaries"
+
+// Store persists completed traces as one JSON file per trace
+// Writes happen on a background goroutine so callers never block on disk
+type Store struct {
+	dir     string
+	index   map[string]Summary
+	mu      sync.RWMutex
+	pending []*trace.Result
+	queueMu sync.Mutex
+	wake    chan struct{}
+	done    chan struct{}
+	closed  bool
+	dirty   bool // The index file is behind the in-memory index; guarded by mu
+}
+
+// Open loads the store at dir, creating the directory if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history dir: %w", err)
+	}
+
+	s := &Store{
+		dir:   dir,
+		index: make(map[string]Summary),
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+
+	if err := s.loadIndex(); err != nil {
+		return nil, err
+	}
+
+	go s.writeLoop()
+	// Bring the index file up to date with what was found on disk
+	if s.dirty {
+		s.wake <- struct{}{}
+	}
+	return s, nil
+}
+
+// Save queues a trace to be written and returns immediately
+// The trace is visible to List and Get as soon as Save returns
+// Returns ErrClosed once the store is closed, as the trace would never be written
+func (s *Store) Save(result *trace.Result) error {
+	if result == nil || result.SessionID == "" {
+		return nil
+	}
+
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+
+	s.mu.Lock()
+	s.index[result.SessionID] = summarize(result)
+	s.dirty = true
+	s.mu.Unlock()
+
+	s.pending = append(s.pending, result)
+	s.signal()
+	return nil
+}
+
+// signal wakes the writer; the caller holds queueMu
+func (s *Store) signal() {
+	if s.closed {
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// List returns summaries matching the filter, newest first
+func (s *Store) List(filter Filter) []Summary {
+	target := strings.ToLower(filter.Target)
+
+	s.mu.RLock()
+	summaries := make([]Summary, 0, len(s.index))
+	for _, summary := range s.index {
+		if target != "" && !strings.Contains(strings.ToLower(summary.Target), target) {
+			continue
+		}
+		if filter.From > 0 && summary.StartedAt < filter.From {
+			continue
+		}
+		if filter.To > 0 && summary.StartedAt > filter.To {
+			continue
+		}
+		if filter.Outcome != "" && summary.Outcome != filter.Outcome {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].StartedAt > summaries[j].StartedAt
+	})
+
+	if filter.Limit > 0 && len(summaries) > filter.Limit {
+		summaries = summaries[:filter.Limit]
+	}
+	return summaries
+}
+
+// Get loads a full trace by ID
+func (s *Store) Get(id string) (*trace.Result, error) {
+	s.mu.RLock()
+	_, ok := s.index[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// The trace may still be waiting in the write queue
+	s.queueMu.Lock()
+	for _, result := range s.pending {
+		if result.SessionID == id {
+			s.queueMu.Unlock()
+			return result, nil
+		}
+	}
+	s.queueMu.Unlock()
+
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var result trace.Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("corrupt trace %s: %w", id, err)
+	}
+	return &result, nil
+}
+
+// Delete removes a trace
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	if _, ok := s.index[id]; !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(s.index, id)
+	s.dirty = true
+	s.mu.Unlock()
+
+	s.dropPending(id)
+	s.queueMu.Lock()
+	s.signal()
+	s.queueMu.Unlock()
+
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Prune deletes traces older than maxAge and keeps at most maxCount of the newest
+// A zero maxAge or maxCount disables that limit
+// Returns the number of traces deleted
+func (s *Store) Prune(maxAge time.Duration, maxCount int) (int, error) {
+	summaries := s.List(Filter{})
+
+	var cutoff int64
+	if maxAge > 0 {
+		cutoff = time.Now().Add(-maxAge).UnixMilli()
+	}
+
+	deleted := 0
+	for i, summary := range summaries {
+		tooOld := cutoff > 0 && summary.StartedAt < cutoff
+		tooMany := maxCount > 0 && i >= maxCount
+		if !tooOld && !tooMany {
+			continue
+		}
+		if err := s.Delete(summary.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Close flushes pending writes and stops the writer
+func (s *Store) Close() {
+	s.queueMu.Lock()
+	if s.closed {
+		s.queueMu.Unlock()
+		return
+	}
+	s.closed = true
+	s.queueMu.Unlock()
+
+	close(s.wake)
+	<-s.done
+}
+
+// writeLoop writes queued traces to disk until the store is closed
+func (s *Store) writeLoop() {
+	defer close(s.done)
+
+	for range s.wake {
+		s.flush()
+		s.writeIndex()
+	}
+	s.flush()
+	s.writeIndex()
+}
+
+// flush writes every queued trace
+func (s *Store) flush() {
+	for {
+		s.queueMu.Lock()
+		if len(s.pending) == 0 {
+			s.queueMu.Unlock()
+			return
+		}
+		result := s.pending[0]
+		s.queueMu.Unlock()
+
+		// Skip traces deleted while they were queued
+		s.mu.RLock()
+		_, ok := s.index[result.SessionID]
+		s.mu.RUnlock()
+
+		if ok {
+			if err := s.write(result); err != nil {
+				println("Failed to save trace:", err.Error())
+			}
+
+			// Undo the write if the trace was deleted while writing
+			s.mu.RLock()
+			_, ok = s.index[result.SessionID]
+			s.mu.RUnlock()
+			if !ok {
+				os.Remove(s.path(result.SessionID))
+			}
+		}
+
+		// Only dequeue after writing so Get can still find it meanwhile
+		s.queueMu.Lock()
+		if len(s.pending) > 0 && s.pending[0] == result {
+			s.pending = s.pending[1:]
+		}
+		s.queueMu.Unlock()
+	}
+}
+
+// write atomically writes a trace file
+func (s *Store) write(result *trace.Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(s.path(result.SessionID), data)
+}
+
+// writeIndex saves the summaries if they changed since the last write
+// Trace files are written first, so a crash leaves at most index entries
+// without a file, which loadIndex drops
+func (s *Store) writeIndex() {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return
+	}
+	summaries := make([]Summary, 0, len(s.index))
+	for _, summary := range s.index {
+		summaries = append(summaries, summary)
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	data, err := json.Marshal(summaries)
+	if err == nil {
+		err = writeAtomic(filepath.Join(s.dir, indexFile), data)
+	}
+	if err != nil {
+		println("Failed to save history index:", err.Error())
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+}
+
+// writeAtomic replaces a file through a temporary one
+func writeAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// dropPending removes a trace from the write queue
+// The head of the queue may be mid-write, so it is left for flush to skip
+func (s *Store) dropPending(id string) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	for i := 1; i < len(s.pending); i++ {
+		if s.pending[i].SessionID == id {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// loadIndex builds the in-memory index from the index file and the trace files on disk
+// Only traces missing from the index file are decoded in full
+func (s *Store) loadIndex() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read history dir: %w", err)
+	}
+
+	indexed := s.readIndexFile()
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		if summary, ok := indexed[entry.Name()]; ok {
+			s.index[summary.ID] = summary
+			continue
+		}
+		s.dirty = true
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		var result trace.Result
+		if err := json.Unmarshal(data, &result); err != nil || result.SessionID == "" {
+			// Skip corrupt files rather than failing to open
+			continue
+		}
+		s.index[result.SessionID] = summarize(&result)
+	}
+	// Entries whose trace file has gone
+	if len(s.index) != len(indexed) {
+		s.dirty = true
+	}
+	return nil
+}
+
+// readIndexFile returns the stored summaries keyed by trace file name
+// A missing or corrupt index is treated as empty
+func (s *Store) readIndexFile() map[string]Summary {
+	data, err := os.ReadFile(filepath.Join(s.dir, indexFile))
+	if err != nil {
+		return nil
+	}
+	var summaries []Summary
+	if err := json.Unmarshal(data, &summaries); err != nil {
+		return nil
+	}
+	indexed := make(map[string]Summary, len(summaries))
+	for _, summary := range summaries {
+		indexed[filepath.Base(s.path(summary.ID))] = summary
+	}
+	return indexed
+}
+
+// path returns the file path for a trace ID
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
+
+// summarize builds the index entry for a trace
+func summarize(result *trace.Result) Summary {
+	return Summary{
+		ID:         result.SessionID,
+		Target:     result.Target,
+		StartedAt:  result.StartedAt,
+		EndedAt:    result.EndedAt,
+		DurationMs: result.DurationMs,
+		Outcome:    result.Outcome,
+		HopCount:   len(result.Hops),
+		Reached:    result.Reached(),
+	}
+}
@@ -0,0 +1,189 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"packet-painter/internal/trace"
+)
+
+func newResult(id, target string, startedAt int64, outcome trace.Outcome) *trace.Result {
+	return &trace.Result{
+		SessionID: id,
+		Target:    target,
+		StartedAt: startedAt,
+		EndedAt:   startedAt + 1000,
+		Outcome:   outcome,
+		Hops: []*trace.Hop{
+			{HopNumber: 1, IPAddress: "192.168.1.1"},
+			{HopNumber: 2, IPAddress: "8.8.8.8", IsDestination: true},
+		},
+	}
+}
+
+func TestStorePersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	store.Save(newResult("a", "google.com", 1000, trace.OutcomeCompleted))
+	store.Close()
+
+	store, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	result, err := store.Get("a")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if result.Target != "google.com" || len(result.Hops) != 2 {
+		t.Errorf("Get() = %+v, want google.com with 2 hops", result)
+	}
+
+	summaries := store.List(Filter{})
+	if len(summaries) != 1 || !summaries[0].Reached {
+		t.Errorf("List() = %+v, want one reached trace", summaries)
+	}
+}
+
+func TestStoreListFilters(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	store.Save(newResult("a", "google.com", 1000, trace.OutcomeCompleted))
+	store.Save(newResult("b", "example.org", 2000, trace.OutcomeError))
+	store.Save(newResult("c", "mail.google.com", 3000, trace.OutcomeCompleted))
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"all newest first", Filter{}, []string{"c", "b", "a"}},
+		{"target substring", Filter{Target: "GOOGLE"}, []string{"c", "a"}},
+		{"date range", Filter{From: 1500, To: 2500}, []string{"b"}},
+		{"outcome", Filter{Outcome: trace.OutcomeError}, []string{"b"}},
+		{"limit", Filter{Limit: 1}, []string{"c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summaries := store.List(tt.filter)
+			if len(summaries) != len(tt.expected) {
+				t.Fatalf("List() returned %d traces, want %d", len(summaries), len(tt.expected))
+			}
+			for i, summary := range summaries {
+				if summary.ID != tt.expected[i] {
+					t.Errorf("List()[%d] = %s, want %s", i, summary.ID, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStoreDeleteAndPrune(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now().UnixMilli()
+	old := time.Now().Add(-48 * time.Hour).UnixMilli()
+
+	store.Save(newResult("old", "a", old, trace.OutcomeCompleted))
+	store.Save(newResult("new1", "b", now-2, trace.OutcomeCompleted))
+	store.Save(newResult("new2", "c", now-1, trace.OutcomeCompleted))
+	store.Save(newResult("new3", "d", now, trace.OutcomeCompleted))
+
+	if err := store.Delete("new3"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get("new3"); err != ErrNotFound {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+
+	deleted, err := store.Prune(24*time.Hour, 1)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Prune() deleted %d, want 2", deleted)
+	}
+
+	summaries := store.List(Filter{})
+	if len(summaries) != 1 || summaries[0].ID != "new2" {
+		t.Errorf("List() after Prune = %+v, want only new2", summaries)
+	}
+}
+
+func TestStoreSaveAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	store.Close()
+
+	if err := store.Save(newResult("late", "google.com", 1000, trace.OutcomeCompleted)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Save() after Close error = %v, want ErrClosed", err)
+	}
+	if summaries := store.List(Filter{}); len(summaries) != 0 {
+		t.Errorf("List() after a rejected Save = %+v, want none", summaries)
+	}
+}
+
+func TestStoreOpensFromIndex(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	store.Save(newResult("a", "google.com", 1000, trace.OutcomeCompleted))
+	store.Save(newResult("b", "example.org", 2000, trace.OutcomeCompleted))
+	store.Close()
+
+	// Indexed traces are listed without decoding their files
+	if err := os.WriteFile(filepath.Join(dir, "a.json"), []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Traces whose file has gone are dropped
+	if err := os.Remove(filepath.Join(dir, "b.json")); err != nil {
+		t.Fatal(err)
+	}
+	// Traces missing from the index, e.g. written before it existed, are decoded
+	data, _ := json.Marshal(newResult("c", "mail.google.com", 3000, trace.OutcomeCompleted))
+	if err := os.WriteFile(filepath.Join(dir, "c.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	var ids []string
+	for _, summary := range store.List(Filter{}) {
+		ids = append(ids, summary.ID)
+		if summary.HopCount != 2 || !summary.Reached {
+			t.Errorf("summary %s = %+v", summary.ID, summary)
+		}
+	}
+	if strings.Join(ids, ",") != "c,a" {
+		t.Errorf("List() = %v, want c and a", ids)
+	}
+}
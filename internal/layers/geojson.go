@@ -0,0 +1,207 @@
+package layers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// geoJSON represents any top-level GeoJSON object
+// A FeatureCollection, a single Feature or a bare Geometry are all accepted
+type geoJSON struct {
+	Type        string                 `json:"type"`
+	Features    []geoJSONFeature       `json:"features"`
+	Properties  map[string]interface{} `json:"properties"`
+	Geometry    *geoJSONGeometry       `json:"geometry"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+	Geometries  []geoJSONGeometry      `json:"geometries"`
+}
+
+// geoJSONFeature represents a single GeoJSON feature
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+}
+
+// geoJSONGeometry represents a GeoJSON geometry object
+type geoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []geoJSONGeometry `json:"geometries"`
+}
+
+// parseGeoJSON converts raw GeoJSON into flattened layer features
+// Multi* geometries are split into one feature per part, like cable routes
+func parseGeoJSON(data []byte, base Style) ([]Feature, error) {
+	var doc geoJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+
+	var raw []geoJSONFeature
+	switch doc.Type {
+	case "FeatureCollection":
+		raw = doc.Features
+	case "Feature":
+		raw = []geoJSONFeature{{Properties: doc.Properties, Geometry: doc.Geometry}}
+	case "":
+		return nil, fmt.Errorf("invalid GeoJSON: missing type")
+	default:
+		// Bare geometry
+		raw = []geoJSONFeature{{Geometry: &geoJSONGeometry{
+			Type:        doc.Type,
+			Coordinates: doc.Coordinates,
+			Geometries:  doc.Geometries,
+		}}}
+	}
+
+	var features []Feature
+	for i, f := range raw {
+		if f.Geometry == nil {
+			continue
+		}
+
+		id := featureID(f.ID, i)
+		style := featureStyle(base, f.Properties)
+		name := propertyString(f.Properties, "name")
+
+		parts := flattenGeometry(*f.Geometry)
+		for j, part := range parts {
+			part.ID = id
+			if len(parts) > 1 {
+				part.ID = id + "-" + strconv.Itoa(j)
+			}
+			part.Name = name
+			part.Properties = f.Properties
+			part.Style = style
+			features = append(features, part)
+		}
+	}
+
+	return features, nil
+}
+
+// flattenGeometry converts a geometry into one or more simple features
+// Unsupported or malformed geometries are skipped
+func flattenGeometry(g geoJSONGeometry) []Feature {
+	var features []Feature
+
+	switch g.Type {
+	case "Point":
+		var coords []float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil || len(coords) < 2 {
+			return nil
+		}
+		features = append(features, Feature{Kind: KindPoint, Point: coords})
+
+	case "MultiPoint":
+		var multi [][]float64
+		if err := json.Unmarshal(g.Coordinates, &multi); err != nil {
+			return nil
+		}
+		for _, coords := range multi {
+			if len(coords) >= 2 {
+				features = append(features, Feature{Kind: KindPoint, Point: coords})
+			}
+		}
+
+	case "LineString":
+		var coords [][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil || len(coords) < 2 {
+			return nil
+		}
+		features = append(features, Feature{Kind: KindLine, Path: coords})
+
+	case "MultiLineString":
+		var multi [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &multi); err != nil {
+			return nil
+		}
+		for _, coords := range multi {
+			if len(coords) >= 2 {
+				features = append(features, Feature{Kind: KindLine, Path: coords})
+			}
+		}
+
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil || len(rings) == 0 {
+			return nil
+		}
+		features = append(features, Feature{Kind: KindPolygon, Rings: rings})
+
+	case "MultiPolygon":
+		var multi [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &multi); err != nil {
+			return nil
+		}
+		for _, rings := range multi {
+			if len(rings) > 0 {
+				features = append(features, Feature{Kind: KindPolygon, Rings: rings})
+			}
+		}
+
+	case "GeometryCollection":
+		for _, child := range g.Geometries {
+			features = append(features, flattenGeometry(child)...)
+		}
+	}
+
+	return features
+}
+
+// featureID returns a stable string ID for a feature
+func featureID(id interface{}, index int) string {
+	switch v := id.(type) {
+	case string:
+		if v != "" {
+			return v
+		}
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.Itoa(index)
+}
+
+// featureStyle overlays simplestyle-spec properties on top of the layer style
+// See https://github.com/mapbox/simplestyle-spec
+func featureStyle(base Style, props map[string]interface{}) Style {
+	style := base
+
+	if v := propertyString(props, "stroke"); v != "" {
+		style.Color = v
+	}
+	if v := propertyString(props, "marker-color"); v != "" {
+		style.Color = v
+	}
+	if v := propertyString(props, "fill"); v != "" {
+		style.FillColor = v
+	}
+	if v, ok := propertyFloat(props, "stroke-width"); ok {
+		style.StrokeWidth = v
+	}
+	if v, ok := propertyFloat(props, "stroke-opacity"); ok {
+		style.Opacity = v
+	}
+	if v, ok := propertyFloat(props, "fill-opacity"); ok {
+		style.FillOpacity = v
+	}
+
+	return style
+}
+
+// propertyString returns a string property or "" if missing
+func propertyString(props map[string]interface{}, key string) string {
+	if v, ok := props[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// propertyFloat returns a numeric property and whether it was present
+func propertyFloat(props map[string]interface{}, key string) (float64, bool) {
+	v, ok := props[key].(float64)
+	return v, ok
+}
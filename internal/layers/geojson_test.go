@@ -0,0 +1,87 @@
+package layers
+
+import (
+	"testing"
+)
+
+func TestParseGeoJSON(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedKinds []GeometryKind
+	}{
+		{
+			name:          "point feature",
+			input:         `{"type":"Feature","properties":{"name":"PoP"},"geometry":{"type":"Point","coordinates":[4.9,52.3]}}`,
+			expectedKinds: []GeometryKind{KindPoint},
+		},
+		{
+			name:          "bare line string",
+			input:         `{"type":"LineString","coordinates":[[0,0],[1,1]]}`,
+			expectedKinds: []GeometryKind{KindLine},
+		},
+		{
+			name: "collection with multi geometries",
+			input: `{"type":"FeatureCollection","features":[
+				{"type":"Feature","id":"fibre","properties":{},"geometry":{"type":"MultiLineString","coordinates":[[[0,0],[1,1]],[[2,2],[3,3]]]}},
+				{"type":"Feature","properties":{},"geometry":{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]]]}},
+				{"type":"Feature","properties":{},"geometry":{"type":"MultiPoint","coordinates":[[0,0],[1,1]]}}
+			]}`,
+			expectedKinds: []GeometryKind{KindLine, KindLine, KindPolygon, KindPoint, KindPoint},
+		},
+		{
+			name:          "degenerate line is skipped",
+			input:         `{"type":"LineString","coordinates":[[0,0]]}`,
+			expectedKinds: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features, err := parseGeoJSON([]byte(tt.input), Style{Color: "red"})
+			if err != nil {
+				t.Fatalf("parseGeoJSON() error = %v", err)
+			}
+			if len(features) != len(tt.expectedKinds) {
+				t.Fatalf("got %d features, want %d", len(features), len(tt.expectedKinds))
+			}
+			for i, f := range features {
+				if f.Kind != tt.expectedKinds[i] {
+					t.Errorf("feature %d kind = %s, want %s", i, f.Kind, tt.expectedKinds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFeatureStyleOverrides(t *testing.T) {
+	input := `{"type":"Feature","properties":{"stroke":"#ff0000","stroke-width":3},"geometry":{"type":"LineString","coordinates":[[0,0],[1,1]]}}`
+
+	features, err := parseGeoJSON([]byte(input), Style{Color: "blue", StrokeWidth: 1, Opacity: 0.5})
+	if err != nil {
+		t.Fatalf("parseGeoJSON() error = %v", err)
+	}
+	if len(features) != 1 {
+		t.Fatalf("got %d features, want 1", len(features))
+	}
+
+	style := features[0].Style
+	if style.Color != "#ff0000" {
+		t.Errorf("Color = %s, want #ff0000", style.Color)
+	}
+	if style.StrokeWidth != 3 {
+		t.Errorf("StrokeWidth = %f, want 3", style.StrokeWidth)
+	}
+	if style.Opacity != 0.5 {
+		t.Errorf("Opacity = %f, want 0.5 from layer style", style.Opacity)
+	}
+}
+
+func TestParseGeoJSONInvalid(t *testing.T) {
+	if _, err := parseGeoJSON([]byte(`{"features":[]}`), Style{}); err == nil {
+		t.Error("expected error for GeoJSON without type")
+	}
+	if _, err := parseGeoJSON([]byte(`not json`), Style{}); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+}
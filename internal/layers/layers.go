@@ -0,0 +1,447 @@
+package layers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GeometryKind identifies the shape of a flattened feature
+type GeometryKind string
+
+const (
+	KindPoint   GeometryKind = "point"
+	KindLine    GeometryKind = "line"
+	KindPolygon GeometryKind = "polygon"
+)
+
+// Style holds the rendering properties for a layer or feature
+type Style struct {
+	Color       string  `json:"color,omitempty"`
+	FillColor   string  `json:"fillColor,omitempty"`
+	StrokeWidth float64 `json:"strokeWidth,omitempty"`
+	PointRadius float64 `json:"pointRadius,omitempty"`
+	Opacity     float64 `json:"opacity,omitempty"`
+	FillOpacity float64 `json:"fillOpacity,omitempty"`
+}
+
+// Feature represents a single simple geometry on a layer
+// Coordinates are [lng, lat] pairs, matching GeoJSON and cable routes
+type Feature struct {
+	ID         string                 `json:"id"`
+	Kind       GeometryKind           `json:"kind"`
+	Name       string                 `json:"name,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Style      Style                  `json:"style"`
+	Point      []float64              `json:"point,omitempty"`
+	Path       [][]float64            `json:"path,omitempty"`
+	Rings      [][][]float64          `json:"rings,omitempty"`
+}
+
+// Config describes where a layer comes from and how it is drawn
+type Config struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Source  string `json:"source"` // Local file path or http(s) URL
+	Style   Style  `json:"style"`
+	Visible bool   `json:"visible"`
+}
+
+// Info summarizes a layer without its features, for listing
+type Info struct {
+	Config
+	FeatureCount int    `json:"featureCount"`
+	LoadedAt     int64  `json:"loadedAt"` // When the features were last loaded successfully
+	Error        string `json:"error,omitempty"`
+	Stale        bool   `json:"stale,omitempty"` // The latest reload failed; features are from the last good load
+}
+
+// Layer is a loaded layer with all of its features
+type Layer struct {
+	Info
+	Features []Feature `json:"features"`
+}
+
+// ErrNotFound is returned when a layer ID is unknown
+var ErrNotFound = errors.New("layer not found")
+
+const (
+	defaultLayerColor = "rgba(120, 200, 255, 0.8)"
+	pollInterval      = 2 * time.Second
+)
+
+// entry holds the cached state of a single layer
+type entry struct {
+	config    Config
+	features  []Feature
+	loadedAt  time.Time // Last successful load
+	checkedAt time.Time // Last load attempt
+	modTime   time.Time
+	size      int64
+	err       error
+}
+
+// Service loads GeoJSON layers from files or URLs with caching
+type Service struct {
+	entries    map[string]*entry
+	order      []string
+	configPath string
+	mu         sync.RWMutex
+	client     *http.Client
+	cacheTTL   time.Duration
+}
+
+// NewService creates a new layer service
+// Layer definitions are persisted to configPath when it is not empty
+func NewService(configPath string) *Service {
+	return &Service{
+		entries:    make(map[string]*entry),
+		configPath: configPath,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		cacheTTL: 1 * time.Hour, // Remote layers are refreshed hourly
+	}
+}
+
+// LoadConfig reads the persisted layer definitions and loads each layer
+// A missing config file is not an error
+func (s *Service) LoadConfig() error {
+	if s.configPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.configPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var configs []Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("invalid layer config: %w", err)
+	}
+
+	for _, cfg := range configs {
+		// Broken sources are kept so the user can see the error
+		s.add(cfg)
+	}
+	return nil
+}
+
+// Add registers a new layer and loads its data
+func (s *Service) Add(cfg Config) (*Info, error) {
+	if strings.TrimSpace(cfg.Source) == "" {
+		return nil, errors.New("layer source is required")
+	}
+	if cfg.ID == "" {
+		cfg.ID = uuid.New().String()
+	}
+	if cfg.Name == "" {
+		cfg.Name = filepath.Base(cfg.Source)
+	}
+
+	info, undo := s.add(cfg)
+	if err := s.saveConfig(); err != nil {
+		// Don't show a layer that will be gone after a restart
+		undo()
+		return nil, err
+	}
+	return info, nil
+}
+
+// add stores and loads a layer without persisting the config
+// undo puts back whatever the layer's ID held before
+func (s *Service) add(cfg Config) (info *Info, undo func()) {
+	if cfg.Style.Color == "" {
+		cfg.Style.Color = defaultLayerColor
+	}
+
+	e := &entry{config: cfg}
+	s.load(e)
+
+	s.mu.Lock()
+	previous, exists := s.entries[cfg.ID]
+	if !exists {
+		s.order = append(s.order, cfg.ID)
+	}
+	s.entries[cfg.ID] = e
+	added := e.info()
+	s.mu.Unlock()
+
+	undo = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.entries[cfg.ID] != e {
+			return
+		}
+		if exists {
+			s.entries[cfg.ID] = previous
+			return
+		}
+		s.removeLocked(cfg.ID)
+	}
+	return &added, undo
+}
+
+// Remove deletes a layer
+func (s *Service) Remove(id string) error {
+	s.mu.Lock()
+	if _, ok := s.entries[id]; !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	s.removeLocked(id)
+	s.mu.Unlock()
+
+	return s.saveConfig()
+}
+
+// removeLocked drops a layer; s.mu must be held
+func (s *Service) removeLocked(id string) {
+	delete(s.entries, id)
+	for i, existing := range s.order {
+		if existing == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// SetVisible toggles whether a layer should be drawn
+func (s *Service) SetVisible(id string, visible bool) error {
+	s.mu.Lock()
+	e, ok := s.entries[id]
+	if !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	e.config.Visible = visible
+	s.mu.Unlock()
+
+	return s.saveConfig()
+}
+
+// List returns a summary of every layer in insertion order
+func (s *Service) List() []Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]Info, 0, len(s.order))
+	for _, id := range s.order {
+		infos = append(infos, s.entries[id].info())
+	}
+	return infos
+}
+
+// Get returns a layer with its features, refreshing stale remote data
+func (s *Service) Get(id string) (*Layer, error) {
+	s.mu.RLock()
+	e, ok := s.entries[id]
+	stale := ok && isURL(e.config.Source) && time.Since(e.checkedAt) >= s.cacheTTL
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if stale {
+		s.reload(id)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok = s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &Layer{
+		Info:     e.info(),
+		Features: e.features,
+	}, nil
+}
+
+// Watch polls local layer files and reloads them when they change
+// onChange is called with the layer ID after every successful reload
+// Blocks until ctx is cancelled
+func (s *Service) Watch(ctx context.Context, onChange func(id string)) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, id := range s.changedFiles() {
+			s.reload(id)
+			if onChange != nil {
+				onChange(id)
+			}
+		}
+	}
+}
+
+// changedFiles returns the IDs of file layers whose modification time or size changed
+func (s *Service) changedFiles() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var changed []string
+	for _, id := range s.order {
+		e := s.entries[id]
+		if isURL(e.config.Source) {
+			continue
+		}
+		stat, err := os.Stat(e.config.Source)
+		if err != nil {
+			// Report a file that disappeared once, then stay quiet
+			if e.err == nil {
+				changed = append(changed, id)
+			}
+			continue
+		}
+		if !stat.ModTime().Equal(e.modTime) || stat.Size() != e.size {
+			changed = append(changed, id)
+		}
+	}
+	return changed
+}
+
+// reload fetches a layer's data again outside of the lock
+// A failed reload keeps the features already loaded and only records the error,
+// so a transient fetch error or a half-written file doesn't empty the layer
+func (s *Service) reload(id string) {
+	s.mu.RLock()
+	e, ok := s.entries[id]
+	if !ok {
+		s.mu.RUnlock()
+		return
+	}
+	fresh := &entry{config: e.config}
+	s.mu.RUnlock()
+
+	s.load(fresh)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.entries[id]
+	if !ok {
+		return
+	}
+	if fresh.err != nil {
+		current.err = fresh.err
+		current.checkedAt = fresh.checkedAt
+		// Wait for the file to change again rather than retrying it every poll
+		current.modTime = fresh.modTime
+		current.size = fresh.size
+		return
+	}
+	// Keep any visibility change made while loading
+	fresh.config = current.config
+	s.entries[id] = fresh
+}
+
+// load reads and parses the layer source into the entry
+func (s *Service) load(e *entry) {
+	var data []byte
+	var err error
+
+	if isURL(e.config.Source) {
+		data, err = s.fetchURL(e.config.Source)
+	} else {
+		var stat os.FileInfo
+		stat, err = os.Stat(e.config.Source)
+		if err == nil {
+			e.modTime = stat.ModTime()
+			e.size = stat.Size()
+			data, err = os.ReadFile(e.config.Source)
+		}
+	}
+
+	e.checkedAt = time.Now()
+	if err != nil {
+		e.err = err
+		return
+	}
+
+	e.features, e.err = parseGeoJSON(data, e.config.Style)
+	if e.err == nil {
+		e.loadedAt = e.checkedAt
+	}
+}
+
+// fetchURL downloads a remote GeoJSON document
+func (s *Service) fetchURL(url string) ([]byte, error) {
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// saveConfig persists the current layer definitions
+func (s *Service) saveConfig() error {
+	if s.configPath == "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	configs := make([]Config, 0, len(s.order))
+	for _, id := range s.order {
+		configs = append(configs, s.entries[id].config)
+	}
+	s.mu.RUnlock()
+
+	data, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.configPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.configPath, data, 0o644)
+}
+
+// info builds the summary for an entry
+func (e *entry) info() Info {
+	info := Info{
+		Config:       e.config,
+		FeatureCount: len(e.features),
+	}
+	if !e.loadedAt.IsZero() {
+		info.LoadedAt = e.loadedAt.UnixMilli()
+	}
+	if e.err != nil {
+		info.Error = e.err.Error()
+		info.Stale = !e.loadedAt.IsZero()
+	}
+	return info
+}
+
+// isURL reports whether a layer source is a remote URL
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
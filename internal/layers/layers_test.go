@@ -0,0 +1,58 @@
+package layers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadKeepsFeaturesOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pops.geojson")
+	if err := os.WriteFile(path, []byte(`{"type":"Point","coordinates":[4.9,52.3]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewService("")
+	info, err := s.Add(Config{Source: path})
+	if err != nil || info.FeatureCount != 1 {
+		t.Fatalf("Add() = %+v, %v", info, err)
+	}
+
+	// A half-written file must not wipe out what was working
+	if err := os.WriteFile(path, []byte(`{"type":"Poi`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.reload(info.ID)
+	layer, err := s.Get(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layer.Features) != 1 || layer.Error == "" || !layer.Stale {
+		t.Errorf("after a failed reload: %d features, error %q, stale %v; want 1 stale feature with the error", len(layer.Features), layer.Error, layer.Stale)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"type":"LineString","coordinates":[[0,0],[1,1]]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.reload(info.ID)
+	layer, _ = s.Get(info.ID)
+	if len(layer.Features) != 1 || layer.Features[0].Kind != KindLine || layer.Error != "" || layer.Stale {
+		t.Errorf("after a good reload: %+v", layer)
+	}
+}
+
+func TestAddRollsBackWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The config's directory is a file, so saving fails
+	s := NewService(filepath.Join(blocker, "layers.json"))
+
+	if _, err := s.Add(Config{Source: filepath.Join(dir, "missing.geojson")}); err == nil {
+		t.Fatal("Add() succeeded with an unwritable config")
+	}
+	if layers := s.List(); len(layers) != 0 {
+		t.Errorf("List() = %+v after a failed Add, want none", layers)
+	}
+}
@@ -0,0 +1,303 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"packet-painter/internal/trace"
+)
+
+// DefaultMaxTargets bounds how many targets are exported at once
+const DefaultMaxTargets = 64
+
+// maxHopSeries bounds the per-hop series kept for a single target
+const maxHopSeries = 64
+
+// rttBuckets are the end-to-end RTT histogram bounds in seconds
+var rttBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.15, 0.2, 0.3, 0.5, 1}
+
+// Result label values for packet_painter_traces_total
+const (
+	resultReached   = "reached"
+	resultUnreached = "unreached"
+	resultError     = "error"
+	resultCancelled = "cancelled"
+)
+
+// hopSeries is the latest measurement of one hop
+type hopSeries struct {
+	hop     int
+	ip      string
+	asn     string
+	rtt     float64 // Seconds
+	hasRTT  bool
+	loss    float64 // 0-1
+	timeout bool
+}
+
+// targetMetrics holds everything exported for one target
+type targetMetrics struct {
+	lastSeen time.Time
+	hops     int
+	rtt      float64 // Seconds, end to end
+	hasRTT   bool
+	results  map[string]uint64
+	buckets  []uint64
+	rttSum   float64
+	rttCount uint64
+	hopData  []hopSeries
+}
+
+// Registry turns finished traces into Prometheus metrics
+// Per-hop series only describe the most recent trace of a target, so a path
+// change replaces series rather than adding to them, and the least recently
+// traced targets are evicted beyond the target limit
+type Registry struct {
+	mu         sync.Mutex
+	maxTargets int
+	targets    map[string]*targetMetrics
+	evicted    uint64
+	now        func() time.Time
+}
+
+// NewRegistry creates a registry exporting at most maxTargets targets
+func NewRegistry(maxTargets int) *Registry {
+	if maxTargets <= 0 {
+		maxTargets = DefaultMaxTargets
+	}
+	return &Registry{
+		maxTargets: maxTargets,
+		targets:    make(map[string]*targetMetrics),
+		now:        time.Now,
+	}
+}
+
+// Observe records a finished trace
+func (r *Registry) Observe(result *trace.Result) {
+	if result == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.targets[result.Target]
+	if !ok {
+		r.evictLocked()
+		t = &targetMetrics{
+			results: make(map[string]uint64),
+			buckets: make([]uint64, len(rttBuckets)),
+		}
+		r.targets[result.Target] = t
+	}
+	t.lastSeen = r.now()
+	t.results[resultLabel(result)]++
+
+	// Cancelled and failed runs say nothing about the current path
+	if result.Outcome != trace.OutcomeCompleted {
+		return
+	}
+
+	t.hops = len(result.Hops)
+	t.hasRTT = false
+	if dest := destination(result); dest != nil && dest.AvgRTT > 0 {
+		t.rtt = dest.AvgRTT / 1000
+		t.hasRTT = true
+		t.rttSum += t.rtt
+		t.rttCount++
+		for i, bound := range rttBuckets {
+			if t.rtt <= bound {
+				t.buckets[i]++
+			}
+		}
+	}
+
+	t.hopData = t.hopData[:0]
+	for _, hop := range result.Hops {
+		if len(t.hopData) == maxHopSeries {
+			break
+		}
+		series := hopSeries{
+			hop:     hop.HopNumber,
+			ip:      hop.IPAddress,
+			loss:    hop.LossRatio(result.Options.ProbesPerHop),
+			timeout: hop.IsTimeout,
+			asn:     hop.ASN,
+		}
+		if !hop.IsTimeout && hop.AvgRTT > 0 {
+			series.rtt = hop.AvgRTT / 1000
+			series.hasRTT = true
+		}
+		t.hopData = append(t.hopData, series)
+	}
+}
+
+// evictLocked drops the least recently traced target when the registry is full
+func (r *Registry) evictLocked() {
+	if len(r.targets) < r.maxTargets {
+		return
+	}
+	oldest := ""
+	var oldestSeen time.Time
+	for target, t := range r.targets {
+		if oldest == "" || t.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = target, t.lastSeen
+		}
+	}
+	delete(r.targets, oldest)
+	r.evicted++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	r.write(bw)
+	bw.Flush()
+}
+
+// write renders every metric family
+func (r *Registry) write(w *bufio.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	targets := make([]string, 0, len(r.targets))
+	for target := range r.targets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	header(w, "packet_painter_traces_total", "counter", "Traces finished, by result (reached, unreached, error, cancelled).")
+	for _, target := range targets {
+		results := r.targets[target].results
+		names := make([]string, 0, len(results))
+		for name := range results {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sample(w, "packet_painter_traces_total", labels("target", target, "result", name), float64(results[name]))
+		}
+	}
+
+	header(w, "packet_painter_path_hops", "gauge", "Hop count of the latest completed trace.")
+	for _, target := range targets {
+		if t := r.targets[target]; t.hops > 0 {
+			sample(w, "packet_painter_path_hops", labels("target", target), float64(t.hops))
+		}
+	}
+
+	header(w, "packet_painter_path_rtt_seconds", "gauge", "End-to-end RTT of the latest trace that reached the destination.")
+	for _, target := range targets {
+		if t := r.targets[target]; t.hasRTT {
+			sample(w, "packet_painter_path_rtt_seconds", labels("target", target), t.rtt)
+		}
+	}
+
+	header(w, "packet_painter_path_rtt_distribution_seconds", "histogram", "End-to-end RTT of traces that reached the destination.")
+	for _, target := range targets {
+		t := r.targets[target]
+		if t.rttCount == 0 {
+			continue
+		}
+		for i, bound := range rttBuckets {
+			sample(w, "packet_painter_path_rtt_distribution_seconds_bucket", labels("target", target, "le", formatFloat(bound)), float64(t.buckets[i]))
+		}
+		sample(w, "packet_painter_path_rtt_distribution_seconds_bucket", labels("target", target, "le", "+Inf"), float64(t.rttCount))
+		sample(w, "packet_painter_path_rtt_distribution_seconds_sum", labels("target", target), t.rttSum)
+		sample(w, "packet_painter_path_rtt_distribution_seconds_count", labels("target", target), float64(t.rttCount))
+	}
+
+	header(w, "packet_painter_hop_rtt_seconds", "gauge", "Average RTT of each responding hop in the latest trace.")
+	for _, target := range targets {
+		for _, hop := range r.targets[target].hopData {
+			if hop.hasRTT {
+				sample(w, "packet_painter_hop_rtt_seconds", hopLabels(target, hop), hop.rtt)
+			}
+		}
+	}
+
+	header(w, "packet_painter_hop_loss_ratio", "gauge", "Share of unanswered probes for each hop in the latest trace.")
+	for _, target := range targets {
+		for _, hop := range r.targets[target].hopData {
+			sample(w, "packet_painter_hop_loss_ratio", hopLabels(target, hop), hop.loss)
+		}
+	}
+
+	header(w, "packet_painter_tracked_targets", "gauge", "Targets currently exported.")
+	sample(w, "packet_painter_tracked_targets", "", float64(len(r.targets)))
+
+	header(w, "packet_painter_evicted_targets_total", "counter", "Targets dropped to stay under the target limit.")
+	sample(w, "packet_painter_evicted_targets_total", "", float64(r.evicted))
+}
+
+// resultLabel classifies a trace for the traces counter
+func resultLabel(result *trace.Result) string {
+	switch result.Outcome {
+	case trace.OutcomeCompleted:
+		if result.Reached() {
+			return resultReached
+		}
+		return resultUnreached
+	case trace.OutcomeCancelled:
+		return resultCancelled
+	default:
+		return resultError
+	}
+}
+
+// destination returns the hop that answered as the destination
+func destination(result *trace.Result) *trace.Hop {
+	for _, hop := range result.Hops {
+		if hop.IsDestination {
+			return hop
+		}
+	}
+	return nil
+}
+
+// hopLabels returns the label set of a per-hop series
+func hopLabels(target string, hop hopSeries) string {
+	return labels("target", target, "hop", strconv.Itoa(hop.hop), "ip", hop.ip, "asn", hop.asn)
+}
+
+// header writes the HELP and TYPE lines of a metric family
+func header(w *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one sample line
+func sample(w *bufio.Writer, name, labelSet string, value float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labelSet, formatFloat(value))
+}
+
+// labels formats name/value pairs as a label set
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatFloat formats a sample value
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
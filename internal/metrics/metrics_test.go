@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"packet-painter/internal/trace"
+)
+
+func completedTrace(target string, hops ...*trace.Hop) *trace.Result {
+	return &trace.Result{
+		Target:  target,
+		Options: trace.Options{ProbesPerHop: 2},
+		Outcome: trace.OutcomeCompleted,
+		Hops:    hops,
+	}
+}
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry(0)
+	r.Observe(completedTrace("google.com",
+		&trace.Hop{HopNumber: 1, IPAddress: "192.168.1.1", RTT: []float64{1}, AvgRTT: 1},
+		&trace.Hop{HopNumber: 2, IPAddress: "*", IsTimeout: true},
+		&trace.Hop{HopNumber: 3, IPAddress: "8.8.8.8", RTT: []float64{20, 20}, AvgRTT: 20, IsDestination: true, ASN: "AS15169"},
+	))
+	r.Observe(&trace.Result{Target: "google.com", Outcome: trace.OutcomeError})
+
+	out := scrape(t, r)
+	expected := []string{
+		`packet_painter_traces_total{target="google.com",result="reached"} 1`,
+		`packet_painter_traces_total{target="google.com",result="error"} 1`,
+		`packet_painter_path_hops{target="google.com"} 3`,
+		`packet_painter_path_rtt_seconds{target="google.com"} 0.02`,
+		`packet_painter_path_rtt_distribution_seconds_bucket{target="google.com",le="0.01"} 0`,
+		`packet_painter_path_rtt_distribution_seconds_bucket{target="google.com",le="0.025"} 1`,
+		`packet_painter_path_rtt_distribution_seconds_bucket{target="google.com",le="+Inf"} 1`,
+		`packet_painter_path_rtt_distribution_seconds_count{target="google.com"} 1`,
+		`packet_painter_hop_rtt_seconds{target="google.com",hop="3",ip="8.8.8.8",asn="AS15169"} 0.02`,
+		`packet_painter_hop_loss_ratio{target="google.com",hop="1",ip="192.168.1.1",asn=""} 0.5`,
+		`packet_painter_hop_loss_ratio{target="google.com",hop="2",ip="*",asn=""} 1`,
+		"# TYPE packet_painter_path_rtt_distribution_seconds histogram",
+	}
+	for _, want := range expected {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing line %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `packet_painter_hop_rtt_seconds{target="google.com",hop="2"`) {
+		t.Error("timed out hop should have no RTT series")
+	}
+}
+
+func TestRegistryBoundsCardinality(t *testing.T) {
+	r := NewRegistry(2)
+	clock := time.Unix(0, 0)
+	r.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	// A changing path replaces the per-hop series instead of adding to them
+	for i := 0; i < 50; i++ {
+		r.Observe(completedTrace("a", &trace.Hop{HopNumber: 1, IPAddress: "10.0.0." + string(rune('0'+i%10)), AvgRTT: 1, RTT: []float64{1}}))
+	}
+	if got := strings.Count(scrape(t, r), "packet_painter_hop_rtt_seconds{"); got != 1 {
+		t.Errorf("got %d hop RTT series, want 1", got)
+	}
+
+	r.Observe(completedTrace("b"))
+	r.Observe(completedTrace("c"))
+	out := scrape(t, r)
+	if strings.Contains(out, `target="a"`) {
+		t.Error("least recently traced target was not evicted")
+	}
+	if !strings.Contains(out, "packet_painter_tracked_targets 2\n") || !strings.Contains(out, "packet_painter_evicted_targets_total 1\n") {
+		t.Errorf("unexpected target gauges:\n%s", out)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	if got := labels("target", "a\"b\\c\nd"); got != `{target="a\"b\\c\nd"}` {
+		t.Errorf("labels() = %s", got)
+	}
+}
@@ -0,0 +1,601 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"packet-painter/internal/events"
+	"packet-painter/internal/sessions"
+	"packet-painter/internal/target"
+	"packet-painter/internal/trace"
+)
+
+const (
+	// MinInterval is the shortest allowed time between runs of a job
+	MinInterval = 1 * time.Minute
+
+	// defaultRunTimeout bounds a single scheduled trace
+	defaultRunTimeout = 2 * time.Minute
+)
+
+// Thresholds control when a run is reported as a change from the previous run
+type Thresholds struct {
+	RouteChange            bool    `json:"routeChange"`            // Report any change in the path
+	LatencyIncreaseMs      float64 `json:"latencyIncreaseMs"`      // Report end-to-end increases above this, 0 disables
+	LatencyIncreasePercent float64 `json:"latencyIncreasePercent"` // Report end-to-end increases above this percentage, 0 disables
+}
+
+// DefaultThresholds returns the thresholds used when a job omits them
+// A job that sets every threshold to zero or false gets no alerts
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		RouteChange:            true,
+		LatencyIncreaseMs:      20,
+		LatencyIncreasePercent: 50,
+	}
+}
+
+// Job is a recurring trace to a single target
+type Job struct {
+	ID              string        `json:"id"`
+	Target          string        `json:"target"`
+	IntervalSeconds int           `json:"intervalSeconds"`
+	JitterSeconds   int           `json:"jitterSeconds"` // Random extra delay added to each interval
+	Options         trace.Options `json:"options"`
+	Thresholds      *Thresholds   `json:"thresholds,omitempty"` // Nil takes DefaultThresholds
+	Enabled         bool          `json:"enabled"`
+	LastRunAt       int64         `json:"lastRunAt"`
+	LastSessionID   string        `json:"lastSessionId"`
+	LastOutcome     trace.Outcome `json:"lastOutcome,omitempty"`
+}
+
+// RouteChangedEvent is emitted when a scheduled run takes a different path
+type RouteChangedEvent struct {
+	JobID             string           `json:"jobId"`
+	Target            string           `json:"target"`
+	PreviousSessionID string           `json:"previousSessionId"`
+	SessionID         string           `json:"sessionId"`
+	Diff              *trace.TraceDiff `json:"diff"`
+	Timestamp         int64            `json:"timestamp"`
+}
+
+// LatencyRegressionEvent is emitted when end-to-end latency rises past a threshold
+type LatencyRegressionEvent struct {
+	JobID             string  `json:"jobId"`
+	Target            string  `json:"target"`
+	PreviousSessionID string  `json:"previousSessionId"`
+	SessionID         string  `json:"sessionId"`
+	PreviousMs        float64 `json:"previousMs"`
+	CurrentMs         float64 `json:"currentMs"`
+	DeltaMs           float64 `json:"deltaMs"`
+	DeltaPercent      float64 `json:"deltaPercent"`
+	Timestamp         int64   `json:"timestamp"`
+}
+
+// EventName and EventSessionID place scheduler events on the event bus
+// They carry session IDs for reference but are not part of a session's lifecycle
+
+func (e RouteChangedEvent) EventName() string           { return "trace:route-changed" }
+func (e RouteChangedEvent) EventSessionID() string      { return "" }
+func (e LatencyRegressionEvent) EventName() string      { return "trace:latency-regression" }
+func (e LatencyRegressionEvent) EventSessionID() string { return "" }
+
+// Store saves scheduled results and loads previous runs for comparison
+type Store interface {
+	Save(result *trace.Result) error
+	Get(id string) (*trace.Result, error)
+}
+
+// Emitter publishes scheduler events such as RouteChangedEvent
+type Emitter func(event events.Payload)
+
+// SessionFactory creates the session for a scheduled run
+type SessionFactory func(target string, opts trace.Options) *trace.Session
+
+var (
+	// ErrNotFound is returned when a job ID is unknown
+	ErrNotFound = errors.New("job not found")
+	// ErrRunning is returned when a job's trace is already in flight
+	ErrRunning = errors.New("job is already running")
+)
+
+// Scheduler runs recurring traces and reports route and latency changes
+type Scheduler struct {
+	path       string
+	store      Store
+	registry   *sessions.Registry
+	emit       Emitter
+	newSession SessionFactory
+	observer   func(result *trace.Result)
+	jobs       map[string]*Job
+	order      []string
+	cancels    map[string]context.CancelFunc
+	inFlight   map[string]chan struct{} // Closed when the job's trace finishes
+	ctx        context.Context
+	mu         sync.Mutex
+	saveMu     sync.Mutex
+	wg         sync.WaitGroup
+}
+
+// New creates a scheduler that persists its jobs to path
+// Scheduled traces run on registry, so they share its limits and publish
+// their events to its bus like any other trace
+func New(path string, store Store, registry *sessions.Registry, emit Emitter) *Scheduler {
+	return &Scheduler{
+		path:       path,
+		store:      store,
+		registry:   registry,
+		emit:       emit,
+		newSession: trace.NewSessionWithOptions,
+		jobs:       make(map[string]*Job),
+		cancels:    make(map[string]context.CancelFunc),
+		inFlight:   make(map[string]chan struct{}),
+	}
+}
+
+// SetSessionFactory overrides how sessions are created for scheduled runs
+func (s *Scheduler) SetSessionFactory(factory SessionFactory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.newSession = factory
+}
+
+// SetObserver registers a function called with the result of every finished run
+// Used to feed metrics exporters
+func (s *Scheduler) SetObserver(observer func(result *trace.Result)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observer = observer
+}
+
+// Load reads the persisted job definitions
+// A missing file is not an error
+func (s *Scheduler) Load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("invalid scheduler config: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range jobs {
+		if job.ID == "" {
+			continue
+		}
+		if _, exists := s.jobs[job.ID]; !exists {
+			s.order = append(s.order, job.ID)
+		}
+		defaultThresholds(job)
+		s.jobs[job.ID] = job
+	}
+	return nil
+}
+
+// Start begins running every enabled job until ctx is cancelled or Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ctx = ctx
+	for _, id := range s.order {
+		if s.jobs[id].Enabled {
+			s.startJobLocked(id)
+		}
+	}
+}
+
+// Stop cancels all running jobs and waits for in-flight traces to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	for id, cancel := range s.cancels {
+		cancel()
+		delete(s.cancels, id)
+	}
+	s.ctx = nil
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Jobs returns a copy of every job in insertion order
+func (s *Scheduler) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]Job, 0, len(s.order))
+	for _, id := range s.order {
+		job := *s.jobs[id]
+		if job.Thresholds != nil {
+			thresholds := *job.Thresholds
+			job.Thresholds = &thresholds
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// AddJob validates and registers a new job, starting it if enabled
+func (s *Scheduler) AddJob(job Job) (*Job, error) {
+	job.ID = uuid.New().String()
+	job.LastRunAt = 0
+	job.LastSessionID = ""
+	job.LastOutcome = ""
+	if err := validateJob(&job); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.jobs[job.ID] = &job
+	s.order = append(s.order, job.ID)
+	if job.Enabled {
+		s.startJobLocked(job.ID)
+	}
+	s.mu.Unlock()
+
+	return &job, s.save()
+}
+
+// UpdateJob replaces a job's definition and restarts it
+// Run history (last run time and session) is kept
+func (s *Scheduler) UpdateJob(job Job) (*Job, error) {
+	if err := validateJob(&job); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	existing, ok := s.jobs[job.ID]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	job.LastRunAt = existing.LastRunAt
+	job.LastSessionID = existing.LastSessionID
+	job.LastOutcome = existing.LastOutcome
+	s.jobs[job.ID] = &job
+
+	s.stopJobLocked(job.ID)
+	if job.Enabled {
+		s.startJobLocked(job.ID)
+	}
+	s.mu.Unlock()
+
+	return &job, s.save()
+}
+
+// RemoveJob stops and deletes a job
+func (s *Scheduler) RemoveJob(id string) error {
+	s.mu.Lock()
+	if _, ok := s.jobs[id]; !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	s.stopJobLocked(id)
+	delete(s.jobs, id)
+	for i, existing := range s.order {
+		if existing == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	return s.save()
+}
+
+// RunNow traces a job immediately, outside its schedule
+// Blocks until the trace finishes and returns its result. Returns ErrRunning
+// if the job is already being traced
+func (s *Scheduler) RunNow(ctx context.Context, id string) (*trace.Result, error) {
+	return s.run(ctx, id)
+}
+
+// startJobLocked launches the loop for a job; s.mu must be held
+func (s *Scheduler) startJobLocked(id string) {
+	if s.ctx == nil {
+		// Not started yet; Start will launch it
+		return
+	}
+	if _, running := s.cancels[id]; running {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.cancels[id] = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx, id)
+	}()
+}
+
+// stopJobLocked cancels the loop for a job; s.mu must be held
+func (s *Scheduler) stopJobLocked(id string) {
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
+
+// loop waits for each scheduled time and runs the job
+func (s *Scheduler) loop(ctx context.Context, id string) {
+	for {
+		s.mu.Lock()
+		job, ok := s.jobs[id]
+		if !ok {
+			s.mu.Unlock()
+			return
+		}
+		snapshot := *job
+		s.mu.Unlock()
+
+		timer := time.NewTimer(nextDelay(snapshot, time.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		_, err := s.run(ctx, id)
+		switch {
+		case errors.Is(err, ErrRunning):
+			// RunNow is tracing the job; the next run is scheduled from that one
+			s.waitIdle(ctx, id)
+		case err != nil && ctx.Err() == nil:
+			println("Scheduled trace failed:", snapshot.Target, err.Error())
+		}
+	}
+}
+
+// begin marks a job's trace as in flight
+// Returns nil if the job is already being traced
+func (s *Scheduler) begin(id string) (finish func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.inFlight[id]; busy {
+		return nil
+	}
+	done := make(chan struct{})
+	s.inFlight[id] = done
+	return func() {
+		s.mu.Lock()
+		delete(s.inFlight, id)
+		s.mu.Unlock()
+		close(done)
+	}
+}
+
+// waitIdle blocks until the job's trace in flight, if any, has finished
+func (s *Scheduler) waitIdle(ctx context.Context, id string) {
+	s.mu.Lock()
+	done := s.inFlight[id]
+	s.mu.Unlock()
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// nextDelay returns how long to wait before the job's next run
+// Overdue jobs run after the jitter alone, so restarts catch up promptly
+func nextDelay(job Job, now time.Time) time.Duration {
+	interval := time.Duration(job.IntervalSeconds) * time.Second
+
+	var jitter time.Duration
+	if job.JitterSeconds > 0 {
+		jitter = time.Duration(rand.Int63n(int64(job.JitterSeconds) * int64(time.Second)))
+	}
+
+	if job.LastRunAt == 0 {
+		return jitter
+	}
+	next := time.UnixMilli(job.LastRunAt).Add(interval)
+	if wait := next.Sub(now); wait > 0 {
+		return wait + jitter
+	}
+	return jitter
+}
+
+// run executes a single trace for a job, stores it and reports changes
+// Only one trace per job is in flight, so each run compares against the one before it
+func (s *Scheduler) run(ctx context.Context, id string) (*trace.Result, error) {
+	finish := s.begin(id)
+	if finish == nil {
+		return nil, ErrRunning
+	}
+	defer finish()
+
+	s.mu.Lock()
+	current, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	job := *current
+	newSession := s.newSession
+	observer := s.observer
+	s.mu.Unlock()
+
+	result, err := s.runSession(ctx, newSession(job.Target, job.Options))
+	if result == nil {
+		return nil, err
+	}
+	if observer != nil {
+		observer(result)
+	}
+
+	if s.store != nil {
+		if err := s.store.Save(result); err != nil {
+			println("Failed to save scheduled trace:", err.Error())
+		}
+	}
+
+	var previous *trace.Result
+	if job.LastSessionID != "" && s.store != nil {
+		previous, _ = s.store.Get(job.LastSessionID)
+	}
+
+	// Only compare successful runs; a failed run says nothing about the path
+	if previous != nil && result.Outcome == trace.OutcomeCompleted && previous.Outcome == trace.OutcomeCompleted {
+		s.compare(job, previous, result)
+	}
+
+	s.mu.Lock()
+	if current, ok := s.jobs[job.ID]; ok {
+		current.LastRunAt = result.StartedAt
+		if result.Outcome == trace.OutcomeCompleted {
+			// Keep comparing against the last good run
+			current.LastSessionID = result.SessionID
+		}
+		current.LastOutcome = result.Outcome
+	}
+	s.mu.Unlock()
+
+	if saveErr := s.save(); saveErr != nil {
+		println("Failed to save scheduler config:", saveErr.Error())
+	}
+	return result, err
+}
+
+// compare emits change events between two runs of a job
+func (s *Scheduler) compare(job Job, previous, current *trace.Result) {
+	if s.emit == nil {
+		return
+	}
+	now := time.Now().UnixMilli()
+	diff := trace.DiffResults(previous, current)
+
+	thresholds := *job.Thresholds
+
+	if thresholds.RouteChange && diff.RouteChanged {
+		s.emit(RouteChangedEvent{
+			JobID:             job.ID,
+			Target:            job.Target,
+			PreviousSessionID: previous.SessionID,
+			SessionID:         current.SessionID,
+			Diff:              diff,
+			Timestamp:         now,
+		})
+	}
+
+	if !previous.Reached() || !current.Reached() || diff.EndToEndA <= 0 {
+		return
+	}
+
+	deltaPercent := diff.EndToEndDeltaMs / diff.EndToEndA * 100
+	overMs := thresholds.LatencyIncreaseMs > 0 && diff.EndToEndDeltaMs > thresholds.LatencyIncreaseMs
+	overPercent := thresholds.LatencyIncreasePercent > 0 && deltaPercent > thresholds.LatencyIncreasePercent
+	if overMs || overPercent {
+		s.emit(LatencyRegressionEvent{
+			JobID:             job.ID,
+			Target:            job.Target,
+			PreviousSessionID: previous.SessionID,
+			SessionID:         current.SessionID,
+			PreviousMs:        diff.EndToEndA,
+			CurrentMs:         diff.EndToEndB,
+			DeltaMs:           diff.EndToEndDeltaMs,
+			DeltaPercent:      deltaPercent,
+			Timestamp:         now,
+		})
+	}
+}
+
+// runSession runs a session on the registry to completion with the run timeout
+// Scheduled runs wait their turn when the registry is at its limit, even with
+// queueing off, rather than being skipped. Returns nil if the trace was
+// cancelled, since a shutdown mid-trace is not worth recording
+func (s *Scheduler) runSession(ctx context.Context, session *trace.Session) (*trace.Result, error) {
+	policy := session.DeadlinePolicy()
+	if policy.Max <= 0 || policy.Max > defaultRunTimeout {
+		policy.Max = defaultRunTimeout
+		session.SetDeadlinePolicy(policy)
+	}
+
+	if _, err := s.registry.StartGroup([]*trace.Session{session}); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { s.registry.Cancel(session.ID) })
+	defer stop()
+
+	result, err := s.registry.Wait(context.Background(), session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if result.Outcome == trace.OutcomeCancelled {
+		return nil, context.Canceled
+	}
+	if result.Error != "" {
+		return result, errors.New(result.Error)
+	}
+	return result, nil
+}
+
+// validateJob checks a job and fills in defaults
+func validateJob(job *Job) error {
+	parsed, err := target.Parse(job.Target)
+	if err != nil {
+		return err
+	}
+	job.Target = parsed.Host
+	if time.Duration(job.IntervalSeconds)*time.Second < MinInterval {
+		return fmt.Errorf("interval must be at least %s", MinInterval)
+	}
+	if job.JitterSeconds < 0 || job.JitterSeconds > job.IntervalSeconds {
+		return errors.New("jitter must be between 0 and the interval")
+	}
+	defaultThresholds(job)
+	job.Options = job.Options.WithDefaults()
+	return nil
+}
+
+// defaultThresholds gives a job that omits its thresholds the defaults
+func defaultThresholds(job *Job) {
+	if job.Thresholds == nil {
+		thresholds := DefaultThresholds()
+		job.Thresholds = &thresholds
+	}
+}
+
+// save persists the job definitions
+func (s *Scheduler) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(s.Jobs(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,276 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"packet-painter/internal/events"
+	"packet-painter/internal/geo"
+	"packet-painter/internal/sessions"
+	"packet-painter/internal/trace"
+)
+
+// fakeRunner replays a fixed set of hops
+type fakeRunner struct {
+	hops []*trace.Hop
+}
+
+func (r *fakeRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop trace.HopCallback, onComplete trace.CompletedCallback, onError trace.ErrorCallback) error {
+	for _, hop := range r.hops {
+		copied := *hop
+		onHop(&copied)
+	}
+	onComplete(len(r.hops))
+	return nil
+}
+
+// memoryStore keeps results in memory
+type memoryStore struct {
+	mu      sync.Mutex
+	results map[string]*trace.Result
+}
+
+func (m *memoryStore) Save(result *trace.Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[result.SessionID] = result
+	return nil
+}
+
+func (m *memoryStore) Get(id string) (*trace.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if result, ok := m.results[id]; ok {
+		return result, nil
+	}
+	return nil, ErrNotFound
+}
+
+// blockingRunner waits to be released or cancelled before completing
+type blockingRunner struct {
+	release chan struct{}
+}
+
+func (r *blockingRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop trace.HopCallback, onComplete trace.CompletedCallback, onError trace.ErrorCallback) error {
+	onHop(&trace.Hop{HopNumber: 1, IPAddress: target, AvgRTT: 1, IsDestination: true})
+	select {
+	case <-r.release:
+		onComplete(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newRegistry returns a session registry that is closed with the test
+func newRegistry(t *testing.T) *sessions.Registry {
+	t.Helper()
+	bus := events.NewBus()
+	registry := sessions.New(bus)
+	t.Cleanup(func() {
+		registry.Close()
+		bus.Close()
+	})
+	return registry
+}
+
+func TestSchedulerDetectsChanges(t *testing.T) {
+	store := &memoryStore{results: make(map[string]*trace.Result)}
+	var emitted []string
+	var mu sync.Mutex
+
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	s := New(path, store, newRegistry(t), func(event events.Payload) {
+		mu.Lock()
+		emitted = append(emitted, event.EventName())
+		mu.Unlock()
+	})
+
+	runs := [][]*trace.Hop{
+		{
+			{HopNumber: 1, IPAddress: "192.168.1.1", AvgRTT: 1},
+			{HopNumber: 2, IPAddress: "8.8.8.8", AvgRTT: 20, IsDestination: true},
+		},
+		{
+			{HopNumber: 1, IPAddress: "192.168.1.1", AvgRTT: 1},
+			{HopNumber: 2, IPAddress: "8.8.8.8", AvgRTT: 21, IsDestination: true},
+		},
+		{
+			{HopNumber: 1, IPAddress: "192.168.1.1", AvgRTT: 1},
+			{HopNumber: 2, IPAddress: "10.9.9.9", AvgRTT: 30},
+			{HopNumber: 3, IPAddress: "8.8.8.8", AvgRTT: 60, IsDestination: true},
+		},
+	}
+	run := 0
+	s.SetSessionFactory(func(target string, opts trace.Options) *trace.Session {
+		runner := &fakeRunner{hops: runs[run]}
+		run++
+		return trace.NewSessionWithRunner(target, opts, runner)
+	})
+
+	job, err := s.AddJob(Job{Target: "8.8.8.8", IntervalSeconds: 900})
+	if err != nil {
+		t.Fatalf("AddJob() error = %v", err)
+	}
+
+	expected := [][]string{
+		nil, // First run has nothing to compare against
+		nil, // Same path, 1ms slower
+		{"trace:route-changed", "trace:latency-regression"},
+	}
+	for i, want := range expected {
+		mu.Lock()
+		emitted = nil
+		mu.Unlock()
+
+		if _, err := s.RunNow(context.Background(), job.ID); err != nil {
+			t.Fatalf("run %d: RunNow() error = %v", i, err)
+		}
+
+		mu.Lock()
+		got := emitted
+		mu.Unlock()
+		if len(got) != len(want) {
+			t.Fatalf("run %d: emitted = %v, want %v", i, got, want)
+		}
+		for j := range want {
+			if got[j] != want[j] {
+				t.Errorf("run %d: emitted[%d] = %s, want %s", i, j, got[j], want[j])
+			}
+		}
+	}
+
+	// Jobs and their last run survive a restart
+	reloaded := New(path, store, nil, nil)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	jobs := reloaded.Jobs()
+	if len(jobs) != 1 || jobs[0].LastSessionID == "" || jobs[0].LastOutcome != trace.OutcomeCompleted {
+		t.Errorf("reloaded jobs = %+v, want one job with its last run", jobs)
+	}
+}
+
+func TestRunOnRegistry(t *testing.T) {
+	registry := newRegistry(t)
+	s := New("", nil, registry, nil)
+	runner := &blockingRunner{release: make(chan struct{})}
+	s.SetSessionFactory(func(target string, opts trace.Options) *trace.Session {
+		return trace.NewSessionWithRunner(target, opts, runner)
+	})
+	job, err := s.AddJob(Job{Target: "8.8.8.8", IntervalSeconds: 900})
+	if err != nil {
+		t.Fatalf("AddJob() error = %v", err)
+	}
+
+	type run struct {
+		result *trace.Result
+		err    error
+	}
+	first := make(chan run, 1)
+	go func() {
+		result, err := s.RunNow(context.Background(), job.ID)
+		first <- run{result, err}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for registry.Running() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduled trace never reached the registry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A second run of the same job would compare against a stale previous run
+	if _, err := s.RunNow(context.Background(), job.ID); !errors.Is(err, ErrRunning) {
+		t.Errorf("concurrent RunNow() error = %v, want ErrRunning", err)
+	}
+
+	close(runner.release)
+	got := <-first
+	if got.err != nil || got.result == nil {
+		t.Fatalf("RunNow() = %v, %v", got.result, got.err)
+	}
+	if replay := registry.Bus().Replay(got.result.SessionID, 0); len(replay) == 0 || replay[0].Name != "trace:started" {
+		t.Errorf("published events = %+v, want the run's trace events", replay)
+	}
+}
+
+func TestValidateJob(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{"valid", Job{Target: "example.com", IntervalSeconds: 900, JitterSeconds: 60}, false},
+		{"missing target", Job{IntervalSeconds: 900}, true},
+		{"unsafe target", Job{Target: "-m 255 example.com", IntervalSeconds: 900}, true},
+		{"interval too short", Job{Target: "example.com", IntervalSeconds: 5}, true},
+		{"jitter longer than interval", Job{Target: "example.com", IntervalSeconds: 60, JitterSeconds: 120}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateJob(&tt.job)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateJob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextDelay(t *testing.T) {
+	now := time.Now()
+	job := Job{IntervalSeconds: 900, LastRunAt: now.Add(-5 * time.Minute).UnixMilli()}
+
+	if delay := nextDelay(job, now); delay < 9*time.Minute || delay > 11*time.Minute {
+		t.Errorf("nextDelay() = %s, want about 10m", delay)
+	}
+
+	job.LastRunAt = now.Add(-time.Hour).UnixMilli()
+	if delay := nextDelay(job, now); delay != 0 {
+		t.Errorf("nextDelay() for overdue job = %s, want 0", delay)
+	}
+}
+
+func TestThresholdDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	config := `[
+		{"id": "omitted", "target": "example.com", "intervalSeconds": 900},
+		{"id": "off", "target": "example.com", "intervalSeconds": 900, "thresholds": {"routeChange": false, "latencyIncreaseMs": 0, "latencyIncreasePercent": 0}}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var emitted []string
+	s := New(path, nil, nil, func(event events.Payload) { emitted = append(emitted, event.EventName()) })
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	jobs := s.Jobs()
+	if len(jobs) != 2 || *jobs[0].Thresholds != DefaultThresholds() || *jobs[1].Thresholds != (Thresholds{}) {
+		t.Fatalf("loaded thresholds = %+v and %+v", jobs[0].Thresholds, jobs[1].Thresholds)
+	}
+
+	added, err := s.AddJob(Job{Target: "example.com", IntervalSeconds: 900, Thresholds: &Thresholds{}})
+	if err != nil || *added.Thresholds != (Thresholds{}) {
+		t.Fatalf("AddJob() = %+v, %v; want alerts left off", added.Thresholds, err)
+	}
+
+	previous := &trace.Result{Hops: []*trace.Hop{{HopNumber: 1, IPAddress: "8.8.8.8", AvgRTT: 20, IsDestination: true}}}
+	current := &trace.Result{Hops: []*trace.Hop{{HopNumber: 1, IPAddress: "1.1.1.1"}, {HopNumber: 2, IPAddress: "8.8.8.8", AvgRTT: 90, IsDestination: true}}}
+	s.compare(jobs[1], previous, current)
+	if len(emitted) != 0 {
+		t.Errorf("job with alerts off emitted %v", emitted)
+	}
+	s.compare(jobs[0], previous, current)
+	if len(emitted) != 2 {
+		t.Errorf("job with default thresholds emitted %v, want a route change and a latency regression", emitted)
+	}
+}
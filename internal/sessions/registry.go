@@ -0,0 +1,495 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"packet-painter/internal/events"
+	"packet-painter/internal/geo"
+	"packet-painter/internal/trace"
+)
+
+// DefaultMaxConcurrent is how many traces run at once when no limit is configured
+const DefaultMaxConcurrent = 4
+
+// retainedFinished is how many finished sessions stay listed
+const retainedFinished = 50
+
+var (
+	// ErrNotFound is returned for unknown session IDs
+	ErrNotFound = errors.New("session not found")
+	// ErrLimitReached is returned when the concurrency limit is reached and queueing is off
+	ErrLimitReached = errors.New("too many running traces")
+	// ErrClosed is returned once the registry is shutting down
+	ErrClosed = errors.New("session registry is closed")
+)
+
+// State is where a session is in its lifecycle
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateCompleted State = "completed"
+	StateCancelled State = "cancelled"
+	StateError     State = "error"
+)
+
+// Limits controls how many traces run at once
+type Limits struct {
+	MaxConcurrent int  `json:"maxConcurrent"` // Traces run at once; 0 for DefaultMaxConcurrent
+	Queue         bool `json:"queue"`         // Queue traces beyond the limit instead of rejecting them
+}
+
+// Info describes a queued, running or recently finished session
+type Info struct {
+	ID            string        `json:"id"`
+	Target        string        `json:"target"`
+	Options       trace.Options `json:"options"`
+	State         State         `json:"state"`
+	QueuePosition int           `json:"queuePosition,omitempty"` // 1-based while queued
+	QueuedAt      int64         `json:"queuedAt"`
+	StartedAt     int64         `json:"startedAt,omitempty"`
+	EndedAt       int64         `json:"endedAt,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// Snapshot is everything needed to rebuild the view of a session
+// Seq is read before the state is copied, so every event up to Seq is
+// reflected. Later events may repeat what the snapshot already holds and
+// are safe to apply again
+type Snapshot struct {
+	Info
+	Source  *geo.Location `json:"source"`
+	Hops    []*trace.Hop  `json:"hops"`
+	Outcome trace.Outcome `json:"outcome,omitempty"`
+	Seq     uint64        `json:"seq"`
+
+	PathOutcome trace.PathOutcome  `json:"pathOutcome,omitempty"`
+	StopReason  trace.StopReason   `json:"stopReason,omitempty"`
+	PathMTU     int                `json:"pathMtu,omitempty"`
+	Tunnels     []trace.MPLSTunnel `json:"mplsTunnels,omitempty"`
+}
+
+// entry is one registered session
+type entry struct {
+	order   uint64 // Registration order
+	session *trace.Session
+	info    Info
+	cancel  context.CancelFunc // Set once running
+	done    chan struct{}      // Closed once the final event is published
+}
+
+// Registry runs trace sessions side by side, publishing their events to a bus
+type Registry struct {
+	ctx      context.Context
+	stop     context.CancelFunc
+	bus      *events.Bus
+	mu       sync.Mutex
+	limits   Limits
+	entries  map[string]*entry
+	next     uint64
+	queue    []*entry
+	running  int
+	finished []string // Finished session IDs, oldest first
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// New creates a registry whose traces publish to bus
+// Each session's own deadline policy decides how long it runs
+func New(bus *events.Bus) *Registry {
+	ctx, stop := context.WithCancel(context.Background())
+	return &Registry{
+		ctx:     ctx,
+		stop:    stop,
+		bus:     bus,
+		limits:  Limits{MaxConcurrent: DefaultMaxConcurrent},
+		entries: make(map[string]*entry),
+	}
+}
+
+// Bus returns the bus the registry's traces publish to
+func (r *Registry) Bus() *events.Bus {
+	return r.bus
+}
+
+// Limits returns the current concurrency limits
+func (r *Registry) Limits() Limits {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.limits
+}
+
+// SetLimits changes the concurrency limits
+// Raising the limit starts queued sessions; running sessions are never stopped
+func (r *Registry) SetLimits(limits Limits) {
+	if limits.MaxConcurrent <= 0 {
+		limits.MaxConcurrent = DefaultMaxConcurrent
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limits = limits
+	r.startQueuedLocked()
+}
+
+// Start runs a session, or queues it when the limit is reached and queueing is on
+func (r *Registry) Start(session *trace.Session) (Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return Info{}, ErrClosed
+	}
+	if r.running >= r.limits.MaxConcurrent && !r.limits.Queue {
+		return Info{}, ErrLimitReached
+	}
+	return r.infoLocked(r.startLocked(session)), nil
+}
+
+// StartGroup runs sessions that belong together, such as traces to every
+// address of a host. Sessions beyond the limit are queued even when queueing
+// is off, so the group is never cut short
+func (r *Registry) StartGroup(sessions []*trace.Session) ([]Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries, err := r.startGroupLocked(sessions)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]Info, len(entries))
+	for i, e := range entries {
+		infos[i] = r.infoLocked(e)
+	}
+	return infos, nil
+}
+
+// startGroupLocked registers and runs or queues every session of a group
+func (r *Registry) startGroupLocked(sessions []*trace.Session) ([]*entry, error) {
+	if r.closed {
+		return nil, ErrClosed
+	}
+	entries := make([]*entry, len(sessions))
+	for i, session := range sessions {
+		entries[i] = r.startLocked(session)
+	}
+	return entries, nil
+}
+
+// startLocked registers a session and runs or queues it
+func (r *Registry) startLocked(session *trace.Session) *entry {
+	r.next++
+	e := &entry{
+		order:   r.next,
+		session: session,
+		done:    make(chan struct{}),
+		info: Info{
+			ID:       session.ID,
+			Target:   session.Target,
+			Options:  session.Options,
+			State:    StateQueued,
+			QueuedAt: time.Now().UnixMilli(),
+		},
+	}
+	r.entries[session.ID] = e
+
+	if r.running < r.limits.MaxConcurrent {
+		r.launchLocked(e)
+		return e
+	}
+
+	r.queue = append(r.queue, e)
+	r.bus.Publish(trace.TraceQueuedEvent{
+		SessionID: session.ID,
+		Target:    session.Target,
+		Position:  len(r.queue),
+		Timestamp: e.info.QueuedAt,
+	})
+	return e
+}
+
+// launchLocked starts a session on its own goroutine
+func (r *Registry) launchLocked(e *entry) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	e.cancel = cancel
+	e.info.State = StateRunning
+	e.info.StartedAt = time.Now().UnixMilli()
+	r.running++
+	r.wg.Add(1)
+	go r.run(ctx, e)
+}
+
+// run drives a session and records how it ended
+func (r *Registry) run(ctx context.Context, e *entry) {
+	defer r.wg.Done()
+
+	result, _ := events.Run(ctx, r.bus, e.session)
+	e.cancel()
+
+	r.mu.Lock()
+	e.info.EndedAt = result.EndedAt
+	e.info.Error = result.Error
+	switch result.Outcome {
+	case trace.OutcomeCompleted:
+		e.info.State = StateCompleted
+	case trace.OutcomeCancelled:
+		e.info.State = StateCancelled
+	default:
+		e.info.State = StateError
+	}
+	r.running--
+	r.finishLocked(e)
+	r.startQueuedLocked()
+	r.mu.Unlock()
+
+	close(e.done)
+}
+
+// startQueuedLocked starts queued sessions, oldest first, while there is room
+func (r *Registry) startQueuedLocked() {
+	for len(r.queue) > 0 && r.running < r.limits.MaxConcurrent && !r.closed {
+		e := r.queue[0]
+		r.queue = r.queue[1:]
+		r.launchLocked(e)
+	}
+}
+
+// finishLocked keeps a finished session listed, dropping the oldest beyond the retention limit
+func (r *Registry) finishLocked(e *entry) {
+	r.finished = append(r.finished, e.info.ID)
+	if len(r.finished) > retainedFinished {
+		delete(r.entries, r.finished[0])
+		r.finished = r.finished[1:]
+	}
+}
+
+// infoLocked returns a session's info with its current queue position
+func (r *Registry) infoLocked(e *entry) Info {
+	info := e.info
+	if info.State == StateQueued {
+		for i, queued := range r.queue {
+			if queued == e {
+				info.QueuePosition = i + 1
+				break
+			}
+		}
+	}
+	return info
+}
+
+// List returns every known session, oldest first
+func (r *Registry) List() []Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]*entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].order < entries[j].order })
+
+	list := make([]Info, len(entries))
+	for i, e := range entries {
+		list[i] = r.infoLocked(e)
+	}
+	return list
+}
+
+// Get returns one session's info
+func (r *Registry) Get(id string) (Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[id]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return r.infoLocked(e), nil
+}
+
+// Snapshot returns a session's state together with the last event it reflects
+func (r *Registry) Snapshot(id string) (Snapshot, error) {
+	r.mu.Lock()
+	e, ok := r.entries[id]
+	r.mu.Unlock()
+	if !ok {
+		return Snapshot{}, ErrNotFound
+	}
+
+	seq := r.bus.LastSeq(id)
+	result := e.session.Snapshot()
+
+	r.mu.Lock()
+	info := r.infoLocked(e)
+	r.mu.Unlock()
+
+	snapshot := Snapshot{
+		Info:    info,
+		Source:  result.Source,
+		Hops:    result.Hops,
+		Outcome: result.Outcome,
+		Seq:     seq,
+
+		PathOutcome: result.PathOutcome,
+		StopReason:  result.StopReason,
+		PathMTU:     result.PathMTU(),
+		Tunnels:     result.MPLSTunnels(),
+	}
+	if snapshot.Hops == nil {
+		snapshot.Hops = []*trace.Hop{}
+	}
+	return snapshot, nil
+}
+
+// Wait blocks until a session has finished and returns its result
+// Returns early with ctx's error if ctx ends first
+func (r *Registry) Wait(ctx context.Context, id string) (*trace.Result, error) {
+	r.mu.Lock()
+	e, ok := r.entries[id]
+	r.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r.wait(ctx, e)
+}
+
+// wait blocks until an entry has finished and returns its result
+// Works from the entry itself, so it still answers once the session is no longer listed
+func (r *Registry) wait(ctx context.Context, e *entry) (*trace.Result, error) {
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	result := e.session.Snapshot()
+	// A session cancelled while queued never ran, so only the registry knows how it ended
+	if result.Outcome == "" {
+		r.mu.Lock()
+		result.Outcome = trace.OutcomeCancelled
+		result.EndedAt = e.info.EndedAt
+		r.mu.Unlock()
+	}
+	return result, nil
+}
+
+// TraceAll traces host at every one of addresses side by side and compares the paths
+// newSession creates the session for one address. Cancelling ctx cancels the
+// traces still going, and the comparison covers whatever they found
+func (r *Registry) TraceAll(ctx context.Context, host string, addresses []string, newSession func(address string) *trace.Session) ([]*trace.PathComparison, error) {
+	group := make([]*trace.Session, len(addresses))
+	for i, address := range addresses {
+		group[i] = newSession(address)
+	}
+	r.mu.Lock()
+	entries, err := r.startGroupLocked(group)
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait regardless of ctx, so the comparison includes every trace.
+	// Later sessions go first so queued ones don't start as earlier ones stop
+	stop := context.AfterFunc(ctx, func() {
+		for i := len(group) - 1; i >= 0; i-- {
+			r.Cancel(group[i].ID)
+		}
+	})
+	defer stop()
+
+	// Sessions that finish early may stop being listed before their turn,
+	// so results come from the entries rather than from their IDs
+	results := make([]*trace.Result, len(entries))
+	for i, e := range entries {
+		if results[i], err = r.wait(context.Background(), e); err != nil {
+			return nil, err
+		}
+	}
+	return trace.ComparePaths(host, results), nil
+}
+
+// Running returns how many sessions are running
+func (r *Registry) Running() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+// Cancel stops a queued or running session and waits for its final event
+// Cancelling a finished session does nothing
+func (r *Registry) Cancel(id string) error {
+	r.mu.Lock()
+	e, ok := r.entries[id]
+	if !ok {
+		r.mu.Unlock()
+		return ErrNotFound
+	}
+
+	switch e.info.State {
+	case StateQueued:
+		r.dequeueLocked(e)
+		r.mu.Unlock()
+		r.bus.Publish(trace.TraceCancelledEvent{SessionID: id, Timestamp: e.info.EndedAt})
+		close(e.done)
+		return nil
+	case StateRunning:
+		cancel := e.cancel
+		r.mu.Unlock()
+		// Run publishes trace:cancelled once the runner has stopped
+		cancel()
+		<-e.done
+		return nil
+	default:
+		r.mu.Unlock()
+		return nil
+	}
+}
+
+// dequeueLocked removes a queued session as cancelled
+func (r *Registry) dequeueLocked(e *entry) {
+	for i, queued := range r.queue {
+		if queued == e {
+			r.queue = append(r.queue[:i], r.queue[i+1:]...)
+			break
+		}
+	}
+	e.info.State = StateCancelled
+	e.info.EndedAt = time.Now().UnixMilli()
+	r.finishLocked(e)
+}
+
+// CancelAll stops every queued and running session
+// Queued sessions go first so none start as running ones finish
+func (r *Registry) CancelAll() {
+	r.mu.Lock()
+	var ids []string
+	for _, e := range r.queue {
+		ids = append(ids, e.info.ID)
+	}
+	for id, e := range r.entries {
+		if e.info.State == StateRunning {
+			ids = append(ids, id)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, id := range ids {
+		r.Cancel(id)
+	}
+}
+
+// Close cancels every session and waits for them to finish; later Starts fail
+func (r *Registry) Close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	r.CancelAll()
+	r.stop()
+	r.wg.Wait()
+}
@@ -0,0 +1,315 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"packet-painter/internal/events"
+	"packet-painter/internal/geo"
+	"packet-painter/internal/trace"
+)
+
+// blockingRunner emits one hop and then waits to be released or cancelled
+type blockingRunner struct {
+	release chan struct{}
+}
+
+func (r *blockingRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop trace.HopCallback, onComplete trace.CompletedCallback, onError trace.ErrorCallback) error {
+	onHop(&trace.Hop{HopNumber: 1, IPAddress: "192.168.1.1"})
+	select {
+	case <-r.release:
+		onComplete(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newSession(runner trace.Runner) *trace.Session {
+	return trace.NewSessionWithRunner("8.8.8.8", trace.DefaultOptions(), runner)
+}
+
+// waitFor polls until cond holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// state returns a session's state, failing the test if it is unknown
+func state(t *testing.T, r *Registry, id string) State {
+	t.Helper()
+	info, err := r.Get(id)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", id, err)
+	}
+	return info.State
+}
+
+func TestRegistryLimit(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	r := New(bus)
+	defer r.Close()
+	r.SetLimits(Limits{MaxConcurrent: 2})
+
+	runner := &blockingRunner{release: make(chan struct{})}
+	first, err := r.Start(newSession(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Start(newSession(runner)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Start(newSession(runner)); !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("third Start error = %v, want ErrLimitReached", err)
+	}
+	if got := r.Running(); got != 2 {
+		t.Errorf("Running() = %d, want 2", got)
+	}
+
+	if err := r.Cancel(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := state(t, r, first.ID); got != StateCancelled {
+		t.Errorf("cancelled session state = %s", got)
+	}
+	if _, err := r.Start(newSession(runner)); err != nil {
+		t.Errorf("Start after cancel: %v", err)
+	}
+	if err := r.Cancel("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel(missing) = %v, want ErrNotFound", err)
+	}
+
+	close(runner.release)
+	waitFor(t, "sessions to complete", func() bool { return r.Running() == 0 })
+	if list := r.List(); len(list) != 3 || list[0].ID != first.ID {
+		t.Errorf("List() = %+v", list)
+	}
+}
+
+func TestRegistryQueue(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	queued := bus.Subscribe(events.SubscribeOptions{})
+	r := New(bus)
+	defer r.Close()
+	r.SetLimits(Limits{MaxConcurrent: 1, Queue: true})
+
+	running := &blockingRunner{release: make(chan struct{})}
+	next := &blockingRunner{release: make(chan struct{})}
+	a, _ := r.Start(newSession(running))
+	b, _ := r.Start(newSession(next))
+	c, _ := r.Start(newSession(next))
+
+	if b.State != StateQueued || b.QueuePosition != 1 {
+		t.Fatalf("second session = %+v, want queued at 1", b)
+	}
+	if info, _ := r.Get(c.ID); info.QueuePosition != 2 {
+		t.Errorf("third session position = %d, want 2", info.QueuePosition)
+	}
+
+	// Cancelling a queued session moves the rest up without starting it
+	if err := r.Cancel(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := r.Get(c.ID); info.QueuePosition != 1 {
+		t.Errorf("third session position after cancel = %d, want 1", info.QueuePosition)
+	}
+
+	close(running.release)
+	waitFor(t, "queued session to start", func() bool { return state(t, r, c.ID) == StateRunning })
+	if got := state(t, r, a.ID); got != StateCompleted {
+		t.Errorf("first session state = %s, want completed", got)
+	}
+
+	r.CancelAll()
+	if got := state(t, r, c.ID); got != StateCancelled {
+		t.Errorf("state after CancelAll = %s", got)
+	}
+
+	// Every event is tagged with its session
+	bus.Close()
+	names := make(map[string][]string)
+	for e := range queued.Events() {
+		names[e.SessionID] = append(names[e.SessionID], e.Name)
+	}
+	if got := names[b.ID]; len(got) != 2 || got[0] != trace.EventQueued || got[1] != trace.EventCancelled {
+		t.Errorf("events of cancelled queued session = %v", got)
+	}
+	if got := names[a.ID]; len(got) != 3 || got[2] != trace.EventCompleted {
+		t.Errorf("events of first session = %v", got)
+	}
+}
+
+func TestRegistryClose(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	r := New(bus)
+
+	runner := &blockingRunner{release: make(chan struct{})}
+	info, _ := r.Start(newSession(runner))
+	r.Close()
+
+	if got := state(t, r, info.ID); got != StateCancelled {
+		t.Errorf("state after Close = %s, want cancelled", got)
+	}
+	if _, err := r.Start(newSession(runner)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Start after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestRegistrySnapshot(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	r := New(bus)
+	defer r.Close()
+
+	runner := &blockingRunner{release: make(chan struct{})}
+	info, _ := r.Start(newSession(runner))
+	waitFor(t, "first hop", func() bool { return bus.LastSeq(info.ID) >= 2 })
+
+	snapshot, err := r.Snapshot(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.State != StateRunning || len(snapshot.Hops) != 1 || snapshot.Outcome != "" || snapshot.Seq != bus.LastSeq(info.ID) {
+		t.Errorf("running snapshot = %+v", snapshot)
+	}
+
+	// Only events after the snapshot's Seq need applying
+	sub := bus.Subscribe(events.SubscribeOptions{SessionID: info.ID, Replay: true, AfterSeq: snapshot.Seq})
+	close(runner.release)
+	waitFor(t, "completion", func() bool { return r.Running() == 0 })
+	sub.Close()
+	var after []string
+	for e := range sub.Events() {
+		after = append(after, e.Name)
+	}
+	if len(after) != 1 || after[0] != trace.EventCompleted {
+		t.Errorf("events after snapshot = %v, want only completion", after)
+	}
+
+	snapshot, _ = r.Snapshot(info.ID)
+	if snapshot.State != StateCompleted || snapshot.Outcome != trace.OutcomeCompleted || snapshot.EndedAt == 0 {
+		t.Errorf("finished snapshot = %+v", snapshot)
+	}
+	if _, err := r.Snapshot("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Snapshot(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+// pathRunner replays a fixed path for each target
+type pathRunner map[string][]string
+
+func (r pathRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop trace.HopCallback, onComplete trace.CompletedCallback, onError trace.ErrorCallback) error {
+	for i, ip := range r[target] {
+		onHop(&trace.Hop{HopNumber: i + 1, IPAddress: ip, AvgRTT: float64(i + 1), IsDestination: ip == target})
+	}
+	onComplete(len(r[target]))
+	return nil
+}
+
+func TestRegistryTraceAll(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	r := New(bus)
+	defer r.Close()
+	// The group is queued past the limit even with queueing off
+	r.SetLimits(Limits{MaxConcurrent: 1})
+
+	runner := pathRunner{
+		"192.0.2.1":   {"10.0.0.1", "198.51.100.1", "192.0.2.1"},
+		"192.0.2.2":   {"10.0.0.1", "203.0.113.1", "192.0.2.2"},
+		"2001:db8::1": {"fe80::1", "2001:db8::1"},
+	}
+	comparisons, err := r.TraceAll(context.Background(), "example.com", []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}, func(address string) *trace.Session {
+		return trace.NewSessionWithRunner(address, trace.DefaultOptions(), runner)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comparisons) != 2 {
+		t.Fatalf("got %d comparisons, want one per family", len(comparisons))
+	}
+	v4 := comparisons[0]
+	if v4.Family != 4 || len(v4.Paths) != 2 || len(v4.SharedHops) != 1 || v4.DivergeHop != 2 {
+		t.Errorf("IPv4 comparison = %+v", v4)
+	}
+	for _, p := range v4.Paths {
+		if !p.Reached || p.EndToEndMs != 3 || p.Outcome != trace.OutcomeCompleted {
+			t.Errorf("path = %+v", p)
+		}
+	}
+	if len(r.List()) != 3 {
+		t.Errorf("List() = %d sessions, want 3", len(r.List()))
+	}
+}
+
+func TestRegistryTraceAllOutlivesRetention(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	r := New(bus)
+	defer r.Close()
+	r.SetLimits(Limits{MaxConcurrent: retainedFinished + 2})
+
+	// The first trace finishes last, after the ones behind it have stopped being listed
+	slow := &blockingRunner{release: make(chan struct{})}
+	fast := pathRunner{}
+	addresses := make([]string, retainedFinished+2)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("192.0.2.%d", i+1)
+		fast[addresses[i]] = []string{addresses[i]}
+	}
+	go func() {
+		waitFor(t, "fast traces", func() bool { return r.Running() == 1 })
+		close(slow.release)
+	}()
+
+	comparisons, err := r.TraceAll(context.Background(), "example.com", addresses, func(address string) *trace.Session {
+		if address == addresses[0] {
+			return trace.NewSessionWithRunner(address, trace.DefaultOptions(), slow)
+		}
+		return trace.NewSessionWithRunner(address, trace.DefaultOptions(), fast)
+	})
+	if err != nil {
+		t.Fatalf("TraceAll() error = %v", err)
+	}
+	if len(comparisons) != 1 || len(comparisons[0].Paths) != len(addresses) {
+		t.Errorf("comparisons = %+v, want one with %d paths", comparisons, len(addresses))
+	}
+}
+
+func TestRegistryTraceAllCancel(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	r := New(bus)
+	defer r.Close()
+	r.SetLimits(Limits{MaxConcurrent: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := &blockingRunner{release: make(chan struct{})}
+	go func() {
+		waitFor(t, "first trace", func() bool { return r.Running() == 1 })
+		cancel()
+	}()
+
+	comparisons, err := r.TraceAll(ctx, "example.com", []string{"192.0.2.1", "192.0.2.2"}, func(address string) *trace.Session {
+		return trace.NewSessionWithRunner(address, trace.DefaultOptions(), runner)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := comparisons[0].Paths
+	if len(paths) != 2 || paths[0].Outcome != trace.OutcomeCancelled || paths[1].Outcome != trace.OutcomeCancelled || paths[0].Hops != 1 {
+		t.Errorf("paths after cancel = %+v", paths)
+	}
+}
@@ -0,0 +1,258 @@
+// Package target validates what the user asked to trace and resolves it to addresses
+package target
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	// ErrEmpty is returned when no target is given
+	ErrEmpty = errors.New("target is required")
+	// ErrUnsafe is returned for targets a trace tool could mistake for an option
+	ErrUnsafe = errors.New("target must not start with '-'")
+	// ErrInvalid is returned for targets that are not a hostname, IP address or URL
+	ErrInvalid = errors.New("not a valid hostname, IP address or URL")
+	// ErrNoAddresses is returned when a hostname resolves to nothing
+	ErrNoAddresses = errors.New("hostname has no IPv4 or IPv6 addresses")
+)
+
+// Error is a target that failed validation or resolution
+type Error struct {
+	Input string
+	Err   error
+}
+
+// Error returns the reason with the offending input
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %q", e.Err, e.Input)
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Resolver looks up the addresses of a hostname; *net.Resolver satisfies it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Target is a validated trace target
+type Target struct {
+	Input     string   `json:"input"`             // What the user typed
+	Host      string   `json:"host"`              // ASCII hostname or normalised IP address, safe to pass to a trace tool
+	Unicode   string   `json:"unicode,omitempty"` // The hostname in Unicode, for internationalised names
+	Port      int      `json:"port,omitempty"`    // Port given in a URL or host:port, if any
+	IsLiteral bool     `json:"isLiteral"`         // Host is an IP address
+	Addresses []string `json:"addresses"`         // Every address the host resolves to, IPv4 first
+}
+
+// defaultPorts are the ports implied by URL schemes
+var defaultPorts = map[string]int{
+	"http":  80,
+	"https": 443,
+	"ws":    80,
+	"wss":   443,
+	"ftp":   21,
+	"ssh":   22,
+}
+
+// Parse validates input without touching the network
+// Accepts hostnames, internationalised hostnames, IPv4 and IPv6 literals,
+// host:port, [IPv6]:port and URLs. Addresses is only filled in for literals
+func Parse(input string) (Target, error) {
+	t := Target{Input: input}
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return t, &Error{Input: t.Input, Err: ErrEmpty}
+	}
+	if strings.HasPrefix(input, "-") {
+		return t, &Error{Input: t.Input, Err: ErrUnsafe}
+	}
+
+	host, port, err := splitHostPort(input)
+	if err != nil {
+		return t, &Error{Input: t.Input, Err: ErrInvalid}
+	}
+	t.Port = port
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if addr.IsUnspecified() {
+			return t, &Error{Input: t.Input, Err: ErrInvalid}
+		}
+		t.Host = addr.Unmap().String()
+		t.IsLiteral = true
+		t.Addresses = []string{t.Host}
+		return t, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil || !validHostname(ascii) {
+		return t, &Error{Input: t.Input, Err: ErrInvalid}
+	}
+	t.Host = ascii
+	if unicode, err := idna.Display.ToUnicode(ascii); err == nil && unicode != ascii {
+		t.Unicode = unicode
+	}
+	return t, nil
+}
+
+// Resolve validates input and looks up every A and AAAA record of a hostname
+// resolver may be nil to use the system resolver
+func Resolve(ctx context.Context, resolver Resolver, input string) (Target, error) {
+	t, err := Parse(input)
+	if err != nil || t.IsLiteral {
+		return t, err
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, t.Host)
+	if err != nil {
+		return t, &Error{Input: t.Input, Err: err}
+	}
+
+	var v4, v6 []string
+	seen := make(map[string]bool)
+	for _, a := range addrs {
+		addr, ok := netip.AddrFromSlice(a.IP)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap().WithZone(a.Zone)
+		s := addr.String()
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		if addr.Is4() {
+			v4 = append(v4, s)
+		} else {
+			v6 = append(v6, s)
+		}
+	}
+	t.Addresses = append(v4, v6...)
+	if len(t.Addresses) == 0 {
+		return t, &Error{Input: t.Input, Err: ErrNoAddresses}
+	}
+	return t, nil
+}
+
+// IPv4 returns the target's IPv4 addresses
+func (t Target) IPv4() []string {
+	return t.family(true)
+}
+
+// IPv6 returns the target's IPv6 addresses
+func (t Target) IPv6() []string {
+	return t.family(false)
+}
+
+// Select returns the addresses to trace when tracing them all: those of the
+// first family, which is IPv4 when there is any, or every address if bothFamilies is set
+func (t Target) Select(bothFamilies bool) []string {
+	if bothFamilies {
+		return t.Addresses
+	}
+	if v4 := t.IPv4(); len(v4) > 0 {
+		return v4
+	}
+	return t.IPv6()
+}
+
+// family returns the addresses that are, or are not, IPv4
+func (t Target) family(v4 bool) []string {
+	var addrs []string
+	for _, s := range t.Addresses {
+		if addr, err := netip.ParseAddr(s); err == nil && addr.Is4() == v4 {
+			addrs = append(addrs, s)
+		}
+	}
+	return addrs
+}
+
+// splitHostPort pulls the host and optional port out of a URL, host:port or bare host
+func splitHostPort(input string) (string, int, error) {
+	if strings.Contains(input, "://") {
+		u, err := url.Parse(input)
+		if err != nil || u.Hostname() == "" {
+			return "", 0, ErrInvalid
+		}
+		port := defaultPorts[strings.ToLower(u.Scheme)]
+		if u.Port() != "" {
+			if port, err = parsePort(u.Port()); err != nil {
+				return "", 0, err
+			}
+		}
+		return u.Hostname(), port, nil
+	}
+
+	// A bare host may still carry a path, e.g. example.com/index.html
+	if i := strings.IndexAny(input, "/?#"); i >= 0 {
+		input = input[:i]
+	}
+
+	switch {
+	case strings.HasPrefix(input, "["):
+		if !strings.HasSuffix(input, "]") {
+			host, port, err := net.SplitHostPort(input)
+			if err != nil {
+				return "", 0, err
+			}
+			p, err := parsePort(port)
+			return host, p, err
+		}
+		return input[1 : len(input)-1], 0, nil
+	case strings.Count(input, ":") == 1:
+		host, port, err := net.SplitHostPort(input)
+		if err != nil {
+			return "", 0, err
+		}
+		p, err := parsePort(port)
+		return host, p, err
+	default:
+		// No colon, or an unbracketed IPv6 literal
+		return input, 0, nil
+	}
+}
+
+// parsePort parses a port number from 1 to 65535
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, ErrInvalid
+	}
+	return port, nil
+}
+
+// validHostname checks an ASCII hostname against DNS label rules
+// A numeric last label is rejected, as tools would read names like 1.2.3 as an IP address
+func validHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	last := labels[len(labels)-1]
+	_, err := strconv.Atoi(last)
+	return err != nil
+}
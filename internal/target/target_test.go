@@ -0,0 +1,152 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		host      string
+		unicode   string
+		port      int
+		isLiteral bool
+		err       error
+	}{
+		{"hostname", "example.com", "example.com", "", 0, false, nil},
+		{"uppercase and trailing dot", " Example.COM. ", "example.com", "", 0, false, nil},
+		{"idn", "bücher.example", "xn--bcher-kva.example", "bücher.example", 0, false, nil},
+		{"punycode", "xn--bcher-kva.example", "xn--bcher-kva.example", "bücher.example", 0, false, nil},
+		{"ipv4", "8.8.8.8", "8.8.8.8", "", 0, true, nil},
+		{"ipv6", "2001:4860:4860::8888", "2001:4860:4860::8888", "", 0, true, nil},
+		{"ipv6 uncompressed", "2001:4860:4860:0:0:0:0:8888", "2001:4860:4860::8888", "", 0, true, nil},
+		{"bracketed ipv6", "[2001:db8::1]", "2001:db8::1", "", 0, true, nil},
+		{"bracketed ipv6 with port", "[2001:db8::1]:8443", "2001:db8::1", "", 8443, true, nil},
+		{"ipv4-mapped ipv6", "::ffff:192.0.2.1", "192.0.2.1", "", 0, true, nil},
+		{"host and port", "example.com:8080", "example.com", "", 8080, false, nil},
+		{"https url", "https://example.com/path?q=1", "example.com", "", 443, false, nil},
+		{"url with port", "http://user@example.com:8080/", "example.com", "", 8080, false, nil},
+		{"url with ipv6", "https://[2001:db8::1]:8443/", "2001:db8::1", "", 8443, true, nil},
+		{"host with path", "example.com/index.html", "example.com", "", 0, false, nil},
+		{"empty", "   ", "", "", 0, false, ErrEmpty},
+		{"option injection", "-w10", "", "", 0, false, ErrUnsafe},
+		{"option injection with spaces", "  --help", "", "", 0, false, ErrUnsafe},
+		{"leading hyphen label", "-example.com", "", "", 0, false, ErrUnsafe},
+		{"hyphen label", "foo.-bar.com", "", "", 0, false, ErrInvalid},
+		{"spaces", "example .com", "", "", 0, false, ErrInvalid},
+		{"shell characters", "example.com;ls", "", "", 0, false, ErrInvalid},
+		{"numeric last label", "1.2.3", "", "", 0, false, ErrInvalid},
+		{"out of range ipv4", "256.1.1.1", "", "", 0, false, ErrInvalid},
+		{"unspecified", "0.0.0.0", "", "", 0, false, ErrInvalid},
+		{"bad port", "example.com:99999", "", "", 0, false, ErrInvalid},
+		{"url without host", "https:///path", "", "", 0, false, ErrInvalid},
+		{"long label", "a123456789012345678901234567890123456789012345678901234567890123.com", "", "", 0, false, ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := Parse(tt.input)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Parse(%q) error = %v, want %v", tt.input, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			if target.Host != tt.host || target.Unicode != tt.unicode || target.Port != tt.port || target.IsLiteral != tt.isLiteral {
+				t.Errorf("Parse(%q) = %+v", tt.input, target)
+			}
+			if tt.isLiteral && !reflect.DeepEqual(target.Addresses, []string{tt.host}) {
+				t.Errorf("literal Addresses = %v", target.Addresses)
+			}
+		})
+	}
+}
+
+// fakeResolver answers lookups from a table
+type fakeResolver struct {
+	hosts  map[string][]string
+	lookup []string
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lookup = append(r.lookup, host)
+	ips, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func TestResolve(t *testing.T) {
+	resolver := &fakeResolver{hosts: map[string][]string{
+		"dual.example":          {"2001:db8::1", "192.0.2.1", "192.0.2.2", "192.0.2.1"},
+		"xn--bcher-kva.example": {"192.0.2.3"},
+		"empty.example":         {},
+	}}
+
+	tests := []struct {
+		name      string
+		input     string
+		addresses []string
+		err       error
+	}{
+		{"ipv4 first, deduplicated", "dual.example", []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}, nil},
+		{"looks up punycode", "https://bücher.example", []string{"192.0.2.3"}, nil},
+		{"literal skips lookup", "192.0.2.9", []string{"192.0.2.9"}, nil},
+		{"no records", "empty.example", nil, ErrNoAddresses},
+		{"unknown host", "missing.example", nil, &net.DNSError{}},
+		{"unsafe never looked up", "-f", nil, ErrUnsafe},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := Resolve(context.Background(), resolver, tt.input)
+			switch want := tt.err.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("Resolve(%q) error = %v", tt.input, err)
+				}
+			case *net.DNSError:
+				if !errors.As(err, &want) || !want.IsNotFound {
+					t.Fatalf("Resolve(%q) error = %v, want DNS not found", tt.input, err)
+				}
+			default:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Resolve(%q) error = %v, want %v", tt.input, err, tt.err)
+				}
+			}
+			if !reflect.DeepEqual(target.Addresses, tt.addresses) {
+				t.Errorf("Addresses = %v, want %v", target.Addresses, tt.addresses)
+			}
+			if got := len(target.IPv4()) + len(target.IPv6()); got != len(tt.addresses) {
+				t.Errorf("IPv4() and IPv6() hold %d addresses, want %d", got, len(tt.addresses))
+			}
+		})
+	}
+
+	dual, _ := Resolve(context.Background(), resolver, "dual.example")
+	if got := dual.Select(false); !reflect.DeepEqual(got, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Errorf("Select(false) = %v", got)
+	}
+	if got := dual.Select(true); len(got) != 3 {
+		t.Errorf("Select(true) = %v", got)
+	}
+
+	for _, host := range resolver.lookup {
+		if host == "-f" || host == "192.0.2.9" {
+			t.Errorf("resolver was asked for %q", host)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"packet-painter/internal/trace"
+)
+
+// scopeName identifies this instrumentation in exported data
+const scopeName = "packet-painter/internal/telemetry"
+
+// exportQueueSize bounds how many finished traces Observe holds while the collector is slow
+const exportQueueSize = 64
+
+// Config controls where telemetry is sent
+type Config struct {
+	Endpoint        string            // Collector base URL, e.g. http://localhost:4318
+	TracesEndpoint  string            // Full URL for spans, used as is; overrides Endpoint
+	MetricsEndpoint string            // Full URL for metrics, used as is; overrides Endpoint
+	Headers         map[string]string // Extra request headers, e.g. for auth
+	ServiceName     string
+	Timeout         time.Duration
+}
+
+// ConfigFromEnv reads the standard OTEL_EXPORTER_OTLP_* and OTEL_SERVICE_NAME variables
+// Header values are URL-decoded as the OTLP exporter spec requires
+// Export is disabled when the returned config is not Enabled
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Endpoint:        os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		TracesEndpoint:  os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		MetricsEndpoint: os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"),
+		ServiceName:     os.Getenv("OTEL_SERVICE_NAME"),
+		Headers:         make(map[string]string),
+	}
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		key, keyErr := url.QueryUnescape(strings.TrimSpace(key))
+		value, valueErr := url.QueryUnescape(strings.TrimSpace(value))
+		if keyErr != nil || valueErr != nil || key == "" {
+			println("Ignoring malformed OTEL_EXPORTER_OTLP_HEADERS entry")
+			continue
+		}
+		cfg.Headers[key] = value
+	}
+	return cfg
+}
+
+// Enabled reports whether any signal has somewhere to go
+func (c Config) Enabled() bool {
+	return c.Endpoint != "" || c.TracesEndpoint != "" || c.MetricsEndpoint != ""
+}
+
+// signalURL returns where to send one signal, or "" if it has nowhere to go
+// path is the signal's path under the base Endpoint, e.g. "/v1/traces"
+func (c Config) signalURL(override, path string) string {
+	if override != "" {
+		return override
+	}
+	if c.Endpoint != "" {
+		return c.Endpoint + path
+	}
+	return ""
+}
+
+// Exporter sends finished traceroutes to an OTLP/HTTP collector
+// Each session becomes a trace whose root span is the session, with one child span per hop
+type Exporter struct {
+	cfg     Config
+	client  *http.Client
+	queue   chan *trace.Result // Traces waiting for Observe's background export
+	start   sync.Once
+	pending sync.WaitGroup // Queued and in-flight exports
+}
+
+// NewExporter creates an exporter for the given config
+func NewExporter(cfg Config) *Exporter {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "packet-painter"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &Exporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan *trace.Result, exportQueueSize),
+	}
+}
+
+// Export sends a finished trace as spans and its hop measurements as metrics
+// Signals without an endpoint are skipped
+func (e *Exporter) Export(ctx context.Context, result *trace.Result) error {
+	var traceErr, metricsErr error
+	if endpoint := e.cfg.signalURL(e.cfg.TracesEndpoint, "/v1/traces"); endpoint != "" {
+		traceErr = e.post(ctx, endpoint, e.spans(result))
+	}
+	if endpoint := e.cfg.signalURL(e.cfg.MetricsEndpoint, "/v1/metrics"); endpoint != "" {
+		metricsErr = e.post(ctx, endpoint, e.metrics(result))
+	}
+	return errors.Join(traceErr, metricsErr)
+}
+
+// Observe queues a trace for export in the background, logging failures
+// Matches the observer hooks of the scheduler and API server. A single
+// worker exports queued traces in turn; when the collector falls so far
+// behind that the queue is full, the trace is dropped
+func (e *Exporter) Observe(result *trace.Result) {
+	if result == nil {
+		return
+	}
+	e.start.Do(func() { go e.exportLoop() })
+
+	e.pending.Add(1)
+	select {
+	case e.queue <- result:
+	default:
+		e.pending.Done()
+		println("Dropping trace telemetry: export queue is full")
+	}
+}
+
+// exportLoop exports queued traces one at a time
+func (e *Exporter) exportLoop() {
+	for result := range e.queue {
+		if err := e.Export(context.Background(), result); err != nil {
+			println("Failed to export trace telemetry:", err.Error())
+		}
+		e.pending.Done()
+	}
+}
+
+// Flush waits for queued and in-flight exports to finish
+func (e *Exporter) Flush() {
+	e.pending.Wait()
+}
+
+// post sends one OTLP/JSON request
+func (e *Exporter) post(ctx context.Context, endpoint string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export to %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("export to %s failed: collector returned %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+// resource describes the process producing the telemetry
+func (e *Exporter) resource() resource {
+	attrs := []keyValue{stringAttr("service.name", e.cfg.ServiceName)}
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, stringAttr("host.name", host))
+	}
+	return resource{Attributes: attrs}
+}
+
+// spans builds the export request for a session and its hops
+func (e *Exporter) spans(result *trace.Result) exportTraceRequest {
+	traceID := traceIDFor(result.SessionID)
+	rootID := spanIDFor(result.SessionID, "session")
+
+	root := span{
+		TraceID:           traceID,
+		SpanID:            rootID,
+		Name:              "traceroute " + result.Target,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: unixNano(result.StartedAt),
+		EndTimeUnixNano:   unixNano(maxInt64(result.EndedAt, result.StartedAt)),
+		Attributes: []keyValue{
+			stringAttr("traceroute.session_id", result.SessionID),
+			stringAttr("traceroute.target", result.Target),
+			stringAttr("traceroute.outcome", string(result.Outcome)),
+			boolAttr("traceroute.reached", result.Reached()),
+			intAttr("traceroute.hop_count", int64(len(result.Hops))),
+			intAttr("traceroute.max_hops", int64(result.Options.MaxHops)),
+			intAttr("traceroute.probes_per_hop", int64(result.Options.ProbesPerHop)),
+		},
+		Status: status{Code: statusOK},
+	}
+	if result.Outcome == trace.OutcomeError {
+		root.Status = status{Code: statusError, Message: result.Error}
+	}
+
+	spans := []span{root}
+	previous := result.StartedAt
+	for _, hop := range result.Hops {
+		// Hops arrive in order, so each hop's span covers the wait since the previous one
+		end := hop.Timestamp
+		if end < previous {
+			end = previous
+		}
+		spans = append(spans, span{
+			TraceID:           traceID,
+			SpanID:            spanIDFor(result.SessionID, "hop-"+strconv.Itoa(hop.HopNumber)),
+			ParentSpanID:      rootID,
+			Name:              "hop " + strconv.Itoa(hop.HopNumber),
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(previous),
+			EndTimeUnixNano:   unixNano(end),
+			Attributes:        hopAttributes(hop),
+			Status:            hopStatus(hop),
+		})
+		previous = end
+	}
+
+	return exportTraceRequest{ResourceSpans: []resourceSpans{{
+		Resource:   e.resource(),
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}, Spans: spans}},
+	}}}
+}
+
+// hopAttributes describes a hop on its span
+func hopAttributes(hop *trace.Hop) []keyValue {
+	attrs := []keyValue{
+		intAttr("traceroute.hop.number", int64(hop.HopNumber)),
+		boolAttr("traceroute.hop.timeout", hop.IsTimeout),
+		boolAttr("traceroute.hop.destination", hop.IsDestination),
+	}
+	if !hop.IsTimeout {
+		attrs = append(attrs,
+			stringAttr("network.peer.address", hop.IPAddress),
+			doubleArrayAttr("traceroute.hop.rtt_ms", hop.RTT),
+			doubleAttr("traceroute.hop.avg_rtt_ms", hop.AvgRTT),
+		)
+	}
+	if hop.Hostname != "" {
+		attrs = append(attrs, stringAttr("traceroute.hop.hostname", hop.Hostname))
+	}
+	if loc := hop.Location; loc != nil {
+		attrs = append(attrs,
+			doubleAttr("geo.location.lat", loc.Latitude),
+			doubleAttr("geo.location.lon", loc.Longitude),
+		)
+		for _, attr := range []struct{ key, value string }{
+			{"geo.locality.name", loc.City},
+			{"geo.region.name", loc.Region},
+			{"geo.country.name", loc.Country},
+			{"geo.country.iso_code", loc.CountryCode},
+			{"traceroute.hop.isp", loc.ISP},
+			{"traceroute.hop.org", loc.Org},
+		} {
+			if attr.value != "" {
+				attrs = append(attrs, stringAttr(attr.key, attr.value))
+			}
+		}
+	}
+	if hop.ASN != "" {
+		attrs = append(attrs, stringAttr("traceroute.hop.asn", hop.ASN))
+	}
+	if hop.DataCenter != nil {
+		attrs = append(attrs, stringAttr("traceroute.hop.datacenter", hop.DataCenter.Provider))
+	}
+	return attrs
+}
+
+// hopStatus marks unanswered hops as errors so they stand out in trace views
+func hopStatus(hop *trace.Hop) status {
+	if hop.IsTimeout {
+		return status{Code: statusError, Message: "no reply"}
+	}
+	return status{Code: statusOK}
+}
+
+// metrics builds the export request for a session's measurements
+func (e *Exporter) metrics(result *trace.Result) exportMetricsRequest {
+	now := unixNano(maxInt64(result.EndedAt, result.StartedAt))
+	target := stringAttr("traceroute.target", result.Target)
+
+	var rtt, loss []numberDataPoint
+	for _, hop := range result.Hops {
+		attrs := []keyValue{
+			target,
+			intAttr("traceroute.hop.number", int64(hop.HopNumber)),
+			stringAttr("network.peer.address", hop.IPAddress),
+		}
+		if hop.ASN != "" {
+			attrs = append(attrs, stringAttr("traceroute.hop.asn", hop.ASN))
+		}
+		if !hop.IsTimeout {
+			rtt = append(rtt, doublePoint(attrs, now, hop.AvgRTT))
+		}
+		loss = append(loss, doublePoint(attrs, now, hop.LossRatio(result.Options.ProbesPerHop)))
+	}
+
+	count := "1"
+	metrics := []metric{
+		{
+			Name:        "traceroute.traces",
+			Description: "Finished traceroute sessions",
+			Unit:        "{trace}",
+			Sum: &sum{
+				AggregationTemporality: aggregationDelta,
+				IsMonotonic:            true,
+				DataPoints: []numberDataPoint{{
+					Attributes: []keyValue{
+						target,
+						stringAttr("traceroute.outcome", string(result.Outcome)),
+						boolAttr("traceroute.reached", result.Reached()),
+					},
+					StartTimeUnixNano: unixNano(result.StartedAt),
+					TimeUnixNano:      now,
+					AsInt:             &count,
+				}},
+			},
+		},
+		{
+			Name:        "traceroute.path.hops",
+			Description: "Hops in the traced path",
+			Unit:        "{hop}",
+			Gauge:       &gauge{DataPoints: []numberDataPoint{doublePoint([]keyValue{target}, now, float64(len(result.Hops)))}},
+		},
+	}
+	for _, hop := range result.Hops {
+		if hop.IsDestination && hop.AvgRTT > 0 {
+			metrics = append(metrics, metric{
+				Name:        "traceroute.path.rtt",
+				Description: "End-to-end round-trip time",
+				Unit:        "ms",
+				Gauge:       &gauge{DataPoints: []numberDataPoint{doublePoint([]keyValue{target}, now, hop.AvgRTT)}},
+			})
+			break
+		}
+	}
+	if len(rtt) > 0 {
+		metrics = append(metrics, metric{Name: "traceroute.hop.rtt", Description: "Average round-trip time to each hop", Unit: "ms", Gauge: &gauge{DataPoints: rtt}})
+	}
+	if len(loss) > 0 {
+		metrics = append(metrics, metric{Name: "traceroute.hop.loss", Description: "Share of unanswered probes per hop", Unit: "1", Gauge: &gauge{DataPoints: loss}})
+	}
+
+	return exportMetricsRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     e.resource(),
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: scopeName}, Metrics: metrics}},
+	}}}
+}
+
+// doublePoint builds a gauge data point
+func doublePoint(attrs []keyValue, timeUnixNano string, value float64) numberDataPoint {
+	return numberDataPoint{Attributes: attrs, TimeUnixNano: timeUnixNano, AsDouble: &value}
+}
+
+// traceIDFor derives the 16-byte trace ID from a session ID
+// Session IDs are UUIDs, which are already 16 random bytes
+func traceIDFor(sessionID string) string {
+	if id := strings.ReplaceAll(sessionID, "-", ""); len(id) == 32 {
+		if _, err := hex.DecodeString(id); err == nil {
+			return strings.ToLower(id)
+		}
+	}
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// spanIDFor derives a stable 8-byte span ID, so re-exporting a session yields the same spans
+func spanIDFor(sessionID, name string) string {
+	sum := sha256.Sum256([]byte(sessionID + "/" + name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// maxInt64 returns the larger of a and b
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
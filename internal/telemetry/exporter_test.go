@@ -0,0 +1,257 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"packet-painter/internal/datacenter"
+	"packet-painter/internal/geo"
+	"packet-painter/internal/trace"
+)
+
+// receiver is a stand-in OTLP/HTTP collector that keeps the decoded requests
+type receiver struct {
+	mu      sync.Mutex
+	traces  []exportTraceRequest
+	metrics []exportMetricsRequest
+	headers http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.headers = req.Header.Clone()
+
+	if req.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	var err error
+	switch req.URL.Path {
+	case "/v1/traces":
+		var msg exportTraceRequest
+		err = json.Unmarshal(body, &msg)
+		r.traces = append(r.traces, msg)
+	case "/v1/metrics":
+		var msg exportMetricsRequest
+		err = json.Unmarshal(body, &msg)
+		r.metrics = append(r.metrics, msg)
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{}"))
+}
+
+func testResult() *trace.Result {
+	return &trace.Result{
+		SessionID: "0f8fad5b-d9cb-469f-a165-70867728950e",
+		Target:    "google.com",
+		Options:   trace.Options{MaxHops: 30, ProbesPerHop: 2, WaitSeconds: 1},
+		StartedAt: 1700000000000,
+		EndedAt:   1700000003000,
+		Outcome:   trace.OutcomeCompleted,
+		Hops: []*trace.Hop{
+			{HopNumber: 1, IPAddress: "192.168.1.1", RTT: []float64{1, 2}, AvgRTT: 1.5, Timestamp: 1700000000500},
+			{HopNumber: 2, IPAddress: "*", IsTimeout: true, Timestamp: 1700000001500},
+			{HopNumber: 3, IPAddress: "142.250.80.46", Hostname: "lga34s34-in-f14.1e100.net", RTT: []float64{15}, AvgRTT: 15,
+				IsDestination: true, Timestamp: 1700000002000, ASN: "AS15169",
+				Location:   &geo.Location{Latitude: 40.7, Longitude: -74, City: "New York", CountryCode: "US"},
+				DataCenter: &datacenter.DataCenter{Provider: "Google Cloud"}},
+		},
+	}
+}
+
+func attr(attrs []keyValue, key string) *anyValue {
+	for i := range attrs {
+		if attrs[i].Key == key {
+			return &attrs[i].Value
+		}
+	}
+	return nil
+}
+
+func TestExportToReceiver(t *testing.T) {
+	recv := &receiver{}
+	collector := httptest.NewServer(recv)
+	defer collector.Close()
+
+	exporter := NewExporter(Config{Endpoint: collector.URL + "/", Headers: map[string]string{"X-Api-Key": "k"}})
+	if err := exporter.Export(context.Background(), testResult()); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if len(recv.traces) != 1 || len(recv.metrics) != 1 {
+		t.Fatalf("receiver got %d trace and %d metric requests, want 1 each", len(recv.traces), len(recv.metrics))
+	}
+	if recv.headers.Get("X-Api-Key") != "k" {
+		t.Error("configured header was not sent")
+	}
+
+	spans := recv.traces[0].ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want session + 3 hops", len(spans))
+	}
+	root := spans[0]
+	if root.TraceID != "0f8fad5bd9cb469fa16570867728950e" || root.ParentSpanID != "" || root.Name != "traceroute google.com" {
+		t.Errorf("root span = %+v", root)
+	}
+	if root.StartTimeUnixNano != "1700000000000000000" || root.EndTimeUnixNano != "1700000003000000000" {
+		t.Errorf("root span times = %s..%s", root.StartTimeUnixNano, root.EndTimeUnixNano)
+	}
+	for _, hop := range spans[1:] {
+		if hop.TraceID != root.TraceID || hop.ParentSpanID != root.SpanID || len(hop.SpanID) != 16 {
+			t.Errorf("hop span %s is not a child of the session: %+v", hop.Name, hop)
+		}
+	}
+	if spans[2].Status.Code != statusError {
+		t.Error("timed out hop should have error status")
+	}
+
+	dest := spans[3].Attributes
+	checks := map[string]string{
+		"network.peer.address":      "142.250.80.46",
+		"traceroute.hop.hostname":   "lga34s34-in-f14.1e100.net",
+		"geo.locality.name":         "New York",
+		"traceroute.hop.asn":        "AS15169",
+		"traceroute.hop.datacenter": "Google Cloud",
+	}
+	for key, want := range checks {
+		if v := attr(dest, key); v == nil || v.StringValue == nil || *v.StringValue != want {
+			t.Errorf("attribute %s = %+v, want %s", key, v, want)
+		}
+	}
+	if v := attr(dest, "traceroute.hop.rtt_ms"); v == nil || v.ArrayValue == nil || len(v.ArrayValue.Values) != 1 {
+		t.Errorf("rtt attribute = %+v", v)
+	}
+
+	metrics := map[string]metric{}
+	for _, m := range recv.metrics[0].ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	if m, ok := metrics["traceroute.hop.rtt"]; !ok || len(m.Gauge.DataPoints) != 2 {
+		t.Errorf("hop RTT metric = %+v, want 2 responding hops", m)
+	}
+	if m, ok := metrics["traceroute.hop.loss"]; !ok || len(m.Gauge.DataPoints) != 3 || *m.Gauge.DataPoints[1].AsDouble != 1 {
+		t.Errorf("hop loss metric = %+v", m)
+	}
+	if m, ok := metrics["traceroute.path.rtt"]; !ok || *m.Gauge.DataPoints[0].AsDouble != 15 {
+		t.Errorf("path RTT metric = %+v", m)
+	}
+	if m, ok := metrics["traceroute.traces"]; !ok || !m.Sum.IsMonotonic || *m.Sum.DataPoints[0].AsInt != "1" {
+		t.Errorf("traces metric = %+v", m)
+	}
+}
+
+func TestExportReportsCollectorErrors(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	if err := NewExporter(Config{Endpoint: collector.URL}).Export(context.Background(), testResult()); err == nil {
+		t.Error("Export() expected error from failing collector")
+	}
+}
+
+func TestSpanIDsAreStable(t *testing.T) {
+	if spanIDFor("a", "hop-1") != spanIDFor("a", "hop-1") || spanIDFor("a", "hop-1") == spanIDFor("a", "hop-2") {
+		t.Error("span IDs should be deterministic and distinct per hop")
+	}
+	if id := traceIDFor("not-a-uuid"); len(id) != 32 {
+		t.Errorf("traceIDFor() = %s, want 32 hex chars", id)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://traces:4318/custom/spans")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Basic%20dXNlcjpwYXNz, x-tenant = a%2Cb,bad=%zz")
+
+	cfg := ConfigFromEnv()
+	if !cfg.Enabled() || cfg.TracesEndpoint != "http://traces:4318/custom/spans" {
+		t.Errorf("ConfigFromEnv() = %+v", cfg)
+	}
+	want := map[string]string{"Authorization": "Basic dXNlcjpwYXNz", "x-tenant": "a,b"}
+	if len(cfg.Headers) != len(want) {
+		t.Errorf("Headers = %v, want %v", cfg.Headers, want)
+	}
+	for key, value := range want {
+		if cfg.Headers[key] != value {
+			t.Errorf("Headers[%s] = %q, want %q", key, cfg.Headers[key], value)
+		}
+	}
+}
+
+func TestExportPerSignalEndpoints(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"traces overridden", Config{Endpoint: collector.URL, TracesEndpoint: collector.URL + "/custom/spans"}, "/custom/spans /v1/metrics"},
+		{"metrics only", Config{MetricsEndpoint: collector.URL + "/custom/metrics"}, "/custom/metrics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths = nil
+			if err := NewExporter(tt.cfg).Export(context.Background(), testResult()); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if got := strings.Join(paths, " "); got != tt.want {
+				t.Errorf("exported to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObserveQueueIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	exported := 0
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		if r.URL.Path == "/v1/traces" {
+			mu.Lock()
+			exported++
+			mu.Unlock()
+		}
+	}))
+	defer collector.Close()
+
+	// The collector holds every request until released, so the queue fills
+	exporter := NewExporter(Config{Endpoint: collector.URL})
+	for i := 0; i < exportQueueSize+20; i++ {
+		exporter.Observe(testResult())
+	}
+	close(release)
+	exporter.Flush()
+
+	// One export may already be in flight when the queue fills
+	if exported < exportQueueSize || exported > exportQueueSize+1 {
+		t.Errorf("exported %d traces, want the %d queued", exported, exportQueueSize)
+	}
+}
@@ -0,0 +1,150 @@
+package telemetry
+
+import (
+	"strconv"
+)
+
+// OTLP/JSON message types, a subset of opentelemetry-proto sufficient for traces and gauges
+// Field names follow the proto JSON mapping; 64-bit integers are encoded as strings
+// and trace/span IDs as lowercase hex, as the OTLP/HTTP JSON encoding requires
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type exportTraceRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes"`
+	Status            status     `json:"status"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Span kind and status codes from the OTLP spec
+const (
+	spanKindInternal = 1
+	statusOK         = 1
+	statusError      = 2
+)
+
+type exportMetricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	Gauge       *gauge `json:"gauge,omitempty"`
+	Sum         *sum   `json:"sum,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+// aggregationDelta reports each data point as a change since the previous export
+const aggregationDelta = 1
+
+type numberDataPoint struct {
+	Attributes        []keyValue `json:"attributes"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsDouble          *float64   `json:"asDouble,omitempty"`
+	AsInt             *string    `json:"asInt,omitempty"`
+}
+
+// stringAttr, intAttr, doubleAttr and boolAttr build attributes
+
+func stringAttr(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
+
+func intAttr(key string, value int64) keyValue {
+	s := strconv.FormatInt(value, 10)
+	return keyValue{Key: key, Value: anyValue{IntValue: &s}}
+}
+
+func doubleAttr(key string, value float64) keyValue {
+	return keyValue{Key: key, Value: anyValue{DoubleValue: &value}}
+}
+
+func boolAttr(key string, value bool) keyValue {
+	return keyValue{Key: key, Value: anyValue{BoolValue: &value}}
+}
+
+// doubleArrayAttr builds an array attribute of doubles
+func doubleArrayAttr(key string, values []float64) keyValue {
+	array := &arrayValue{Values: make([]anyValue, len(values))}
+	for i := range values {
+		v := values[i]
+		array.Values[i] = anyValue{DoubleValue: &v}
+	}
+	return keyValue{Key: key, Value: anyValue{ArrayValue: array}}
+}
+
+// unixNano formats Unix milliseconds as an OTLP nanosecond timestamp
+func unixNano(ms int64) string {
+	return strconv.FormatInt(ms*1_000_000, 10)
+}
@@ -0,0 +1,67 @@
+package trace
+
+import (
+	"strings"
+)
+
+// ASNSource names where a hop's AS number came from
+type ASNSource string
+
+const (
+	ASNSourceTraceroute ASNSource = "traceroute" // traceroute's own lookup (-A on Linux, -a on macOS)
+	ASNSourceGeo        ASNSource = "geo"        // The geolocation lookup
+)
+
+// ASNClaim is an AS number one source gave for a hop
+type ASNClaim struct {
+	ASN    string    `json:"asn"` // e.g. "AS15169"
+	Source ASNSource `json:"source"`
+	IP     string    `json:"ip,omitempty"` // Another router that answered a probe for the same hop
+}
+
+// parseASTag reads a traceroute AS tag such as "[AS15169]" or "[AS15169/AS36040]"
+// An address announced by several ASes lists them all. "[*]" means no AS is
+// known and returns no numbers. Returns false for fields that are not tags
+func parseASTag(field string) ([]string, bool) {
+	if len(field) < 3 || field[0] != '[' || field[len(field)-1] != ']' {
+		return nil, false
+	}
+	inner := field[1 : len(field)-1]
+	if inner == "*" {
+		return nil, true
+	}
+	var asns []string
+	for _, asn := range strings.Split(inner, "/") {
+		if len(asn) < 3 || !strings.HasPrefix(asn, "AS") || strings.Trim(asn[2:], "0123456789") != "" {
+			return nil, false
+		}
+		asns = append(asns, asn)
+	}
+	return asns, true
+}
+
+// mergeASN records an AS number for a hop
+// The first AS number the hop's own address is given is kept. Claims that
+// differ, or that were made for another router answering the same hop, are
+// recorded as conflicts rather than overwriting it
+func mergeASN(hop *Hop, claim ASNClaim) {
+	if claim.ASN == "" {
+		return
+	}
+	if claim.IP == hop.IPAddress {
+		claim.IP = ""
+	}
+	if claim.IP == "" && hop.ASN == "" {
+		hop.ASN = claim.ASN
+		return
+	}
+	if claim.ASN == hop.ASN {
+		return
+	}
+	for _, seen := range hop.ASNConflicts {
+		if seen == claim {
+			return
+		}
+	}
+	hop.ASNConflicts = append(hop.ASNConflicts, claim)
+}
@@ -0,0 +1,147 @@
+package trace
+
+import (
+	"reflect"
+	"testing"
+
+	"packet-painter/internal/geo"
+)
+
+func TestParseASTag(t *testing.T) {
+	tests := []struct {
+		input string
+		asns  []string
+		ok    bool
+	}{
+		{"[AS15169]", []string{"AS15169"}, true},
+		{"[AS3356/AS3549]", []string{"AS3356", "AS3549"}, true},
+		{"[*]", nil, true},
+		{"[ASx]", nil, false},
+		{"[142.250.80.46]", nil, false},
+		{"AS15169", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			asns, ok := parseASTag(tt.input)
+			if ok != tt.ok || !reflect.DeepEqual(asns, tt.asns) {
+				t.Errorf("parseASTag(%q) = %v, %v; want %v, %v", tt.input, asns, ok, tt.asns, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseUnixHopLineASN(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		ip        string
+		hostname  string
+		rtts      int
+		asn       string
+		conflicts []ASNClaim
+	}{
+		{
+			name:  "address with tag",
+			input: " 6  8.8.8.8 [AS15169]  22.015 ms",
+			ip:    "8.8.8.8",
+			rtts:  1,
+			asn:   "AS15169",
+		},
+		{
+			name:     "hostname with tag",
+			input:    " 4  ae-1.r01.fra.example.net (62.115.42.1) [AS1299]  21.402 ms  21.388 ms  21.417 ms",
+			ip:       "62.115.42.1",
+			hostname: "ae-1.r01.fra.example.net",
+			rtts:     3,
+			asn:      "AS1299",
+		},
+		{
+			name:  "no AS for a private address",
+			input: " 1  192.168.1.1 [*]  0.512 ms  0.498 ms",
+			ip:    "192.168.1.1",
+			rtts:  2,
+		},
+		{
+			name:      "several origins",
+			input:     " 5  4.69.140.1 [AS3356/AS3549]  30.1 ms",
+			ip:        "4.69.140.1",
+			rtts:      1,
+			asn:       "AS3356",
+			conflicts: []ASNClaim{{ASN: "AS3549", Source: ASNSourceTraceroute}},
+		},
+		{
+			name:      "probes answered from different ASes",
+			input:     " 7  10.0.0.1 [AS64500]  5.1 ms 10.0.0.2 [AS64501]  5.3 ms 10.0.0.1 [AS64500]  5.2 ms",
+			ip:        "10.0.0.1",
+			rtts:      3,
+			asn:       "AS64500",
+			conflicts: []ASNClaim{{ASN: "AS64501", Source: ASNSourceTraceroute, IP: "10.0.0.2"}},
+		},
+		{
+			name:  "probes answered from the same AS",
+			input: " 7  10.0.0.1 [AS64500]  5.1 ms * 10.0.0.2 [AS64500]  5.3 ms",
+			ip:    "10.0.0.1",
+			rtts:  2,
+			asn:   "AS64500",
+		},
+		{
+			name:      "macOS puts the tag first",
+			input:     " 7  [AS64500] 10.0.0.1  5.1 ms [AS64501] router.example.net (10.0.0.2)  5.3 ms",
+			ip:        "10.0.0.1",
+			rtts:      2,
+			asn:       "AS64500",
+			conflicts: []ASNClaim{{ASN: "AS64501", Source: ASNSourceTraceroute, IP: "10.0.0.2"}},
+		},
+		{
+			name:  "tag with MPLS extension",
+			input: " 3  62.115.42.1 [AS1299]  21.4 ms <MPLS:L=24001,E=0,S=1,T=1>  21.3 ms",
+			ip:    "62.115.42.1",
+			rtts:  2,
+			asn:   "AS1299",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hop := parseUnixHopLine(tt.input, "8.8.8.8", nil)
+			if hop == nil {
+				t.Fatalf("%q not parsed", tt.input)
+			}
+			if hop.IPAddress != tt.ip || hop.Hostname != tt.hostname || len(hop.RTT) != tt.rtts {
+				t.Errorf("hop = %+v", hop)
+			}
+			if hop.ASN != tt.asn || !reflect.DeepEqual(hop.ASNConflicts, tt.conflicts) {
+				t.Errorf("ASN = %q, conflicts = %+v; want %q, %+v", hop.ASN, hop.ASNConflicts, tt.asn, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeASNWithGeo(t *testing.T) {
+	lookup := func(asn string) GeoLookupFunc {
+		return func(ip string) *geo.Location { return &geo.Location{City: "Frankfurt", ASN: asn} }
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		geoASN    string
+		asn       string
+		conflicts []ASNClaim
+	}{
+		{"agree", " 4  62.115.42.1 [AS1299]  21.4 ms", "AS1299", "AS1299", nil},
+		{"traceroute is kept", " 4  62.115.42.1 [AS1299]  21.4 ms", "AS3320", "AS1299", []ASNClaim{{ASN: "AS3320", Source: ASNSourceGeo}}},
+		{"geo fills in", " 4  62.115.42.1  21.4 ms", "AS3320", "AS3320", nil},
+		{"neither knows", " 4  62.115.42.1 [*]  21.4 ms", "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hop := parseUnixHopLine(tt.input, "8.8.8.8", lookup(tt.geoASN))
+			if hop.ASN != tt.asn || !reflect.DeepEqual(hop.ASNConflicts, tt.conflicts) {
+				t.Errorf("ASN = %q, conflicts = %+v; want %q, %+v", hop.ASN, hop.ASNConflicts, tt.asn, tt.conflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/icmp"
+	"packet-painter/internal/geo"
+)
+
+// Backend names a trace tool
+type Backend string
+
+const (
+	BackendAuto       Backend = "auto" // Pick the best usable backend
+	BackendTraceroute Backend = "traceroute"
+	BackendTracepath  Backend = "tracepath"
+	BackendMTR        Backend = "mtr"
+	BackendTracert    Backend = "tracert"
+)
+
+// ParseBackend validates a backend name; empty means auto
+func ParseBackend(name string) (Backend, error) {
+	switch b := Backend(strings.ToLower(strings.TrimSpace(name))); b {
+	case "", BackendAuto:
+		return BackendAuto, nil
+	case BackendTraceroute, BackendTracepath, BackendMTR, BackendTracert:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown trace backend %q: choose auto, traceroute, tracepath, mtr or tracert", name)
+	}
+}
+
+// candidateBackends are the tools looked for on each OS
+var candidateBackends = map[string][]Backend{
+	"linux":   {BackendTraceroute, BackendTracepath, BackendMTR},
+	"darwin":  {BackendTraceroute, BackendMTR},
+	"windows": {BackendTracert},
+}
+
+// platformBackend is a backend this build can drive
+type platformBackend struct {
+	name Backend
+	new  func(opts Options, path string) Runner
+}
+
+// BackendInfo describes one trace tool on this machine
+type BackendInfo struct {
+	Name      Backend `json:"name"`
+	Path      string  `json:"path,omitempty"` // Where the tool is installed; empty if it is not
+	Supported bool    `json:"supported"`      // packet-painter can drive it on this platform
+	Usable    bool    `json:"usable"`         // Installed, supported and has what it needs to run
+	Reason    string  `json:"reason,omitempty"`
+}
+
+// Capabilities describes which trace backends and probe types this machine supports
+type Capabilities struct {
+	OS               string        `json:"os"`
+	Backends         []BackendInfo `json:"backends"`
+	Selected         Backend       `json:"selected,omitempty"` // The backend auto picks; empty if none is usable
+	Root             bool          `json:"root"`
+	CapNetRaw        bool          `json:"capNetRaw"`        // The process holds CAP_NET_RAW
+	RawICMP          bool          `json:"rawIcmp"`          // A raw ICMP socket opened (needs root or CAP_NET_RAW)
+	UnprivilegedICMP bool          `json:"unprivilegedIcmp"` // An ICMP datagram socket opened, e.g. allowed by net.ipv4.ping_group_range
+	PingGroupRange   [2]int        `json:"pingGroupRange"`   // Linux only
+	Guidance         []string      `json:"guidance,omitempty"`
+}
+
+// Backend returns the info of a backend, if it was looked for
+func (c Capabilities) Backend(name Backend) (BackendInfo, bool) {
+	for _, info := range c.Backends {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return BackendInfo{}, false
+}
+
+// probeEnv is what a capability probe reads, replaced in tests
+type probeEnv struct {
+	goos      string
+	lookPath  func(file string) (string, error)
+	readFile  func(name string) ([]byte, error)
+	listen    func(network string) error // Opens and closes an ICMP socket
+	euid      int
+	supported []platformBackend
+}
+
+// systemEnv returns the environment of this process
+func systemEnv() probeEnv {
+	return probeEnv{
+		goos:      runtime.GOOS,
+		lookPath:  exec.LookPath,
+		readFile:  os.ReadFile,
+		listen:    listenICMP,
+		euid:      os.Geteuid(),
+		supported: platformBackends,
+	}
+}
+
+// listenICMP checks that an ICMP socket can be opened
+// "ip4:icmp" opens a raw socket, "udp4" an ICMP datagram (SOCK_DGRAM) socket
+func listenICMP(network string) error {
+	conn, err := icmp.ListenPacket(network, "0.0.0.0")
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// sbinDirs hold trace tools that desktop sessions often leave off PATH
+var sbinDirs = []string{"/usr/sbin", "/sbin", "/usr/local/sbin", "/usr/local/bin", "/opt/homebrew/sbin", "/opt/homebrew/bin"}
+
+var (
+	detectOnce   sync.Once
+	detectedCaps Capabilities
+)
+
+// DetectCapabilities probes this machine once and returns the cached result
+func DetectCapabilities() Capabilities {
+	detectOnce.Do(func() {
+		detectedCaps = ProbeCapabilities()
+	})
+	return detectedCaps
+}
+
+// ProbeCapabilities checks which trace backends are installed and usable, and
+// which kinds of probe the process may send
+func ProbeCapabilities() Capabilities {
+	return probe(systemEnv())
+}
+
+// probe builds the capabilities seen in env
+func probe(env probeEnv) Capabilities {
+	caps := Capabilities{OS: env.goos, Root: env.euid == 0}
+
+	// The trace tools open their own sockets; these checks tell the user
+	// which probe types will work and feed the guidance
+	switch env.goos {
+	case "linux":
+		caps.CapNetRaw = hasCapNetRaw(env)
+		caps.PingGroupRange = pingGroupRange(env)
+		caps.UnprivilegedICMP = env.listen("udp4") == nil
+	case "darwin":
+		caps.UnprivilegedICMP = env.listen("udp4") == nil
+	case "windows":
+		// tracert sends its probes through the ICMP API, which needs no privileges
+		caps.UnprivilegedICMP = true
+	}
+	caps.RawICMP = env.listen("ip4:icmp") == nil
+
+	for _, name := range candidateBackends[env.goos] {
+		info := BackendInfo{Name: name, Path: findTool(env, string(name))}
+		for _, b := range env.supported {
+			if b.name == name {
+				info.Supported = true
+			}
+		}
+		switch {
+		case info.Path == "":
+			info.Reason = string(name) + " is not installed"
+		case !info.Supported:
+			info.Reason = "packet-painter cannot drive " + string(name) + " on this platform yet"
+		case name == BackendMTR && findTool(env, "mtr-packet") == "":
+			info.Reason = "mtr-packet, which sends mtr's probes, is not installed"
+		default:
+			info.Usable = true
+		}
+		caps.Backends = append(caps.Backends, info)
+	}
+
+	// Best first, in the order the platform lists them
+	for _, b := range env.supported {
+		if info, ok := caps.Backend(b.name); ok && info.Usable {
+			caps.Selected = b.name
+			break
+		}
+	}
+
+	caps.Guidance = guidance(caps)
+	return caps
+}
+
+// findTool returns the path of a tool, also looking in sbin directories off PATH
+func findTool(env probeEnv, name string) string {
+	if path, err := env.lookPath(name); err == nil {
+		return path
+	}
+	if env.goos == "windows" {
+		return ""
+	}
+	for _, dir := range sbinDirs {
+		if path, err := env.lookPath(filepath.Join(dir, name)); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// hasCapNetRaw reports whether CAP_NET_RAW (bit 13) is in the effective set
+func hasCapNetRaw(env probeEnv) bool {
+	data, err := env.readFile("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "CapEff:"); ok {
+			mask, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+			return err == nil && mask&(1<<13) != 0
+		}
+	}
+	return false
+}
+
+// pingGroupRange reads net.ipv4.ping_group_range; the kernel default "1 0" allows no group
+func pingGroupRange(env probeEnv) [2]int {
+	disabled := [2]int{1, 0}
+	data, err := env.readFile("/proc/sys/net/ipv4/ping_group_range")
+	if err != nil {
+		return disabled
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return disabled
+	}
+	lo, err1 := strconv.Atoi(fields[0])
+	hi, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return disabled
+	}
+	return [2]int{lo, hi}
+}
+
+// installHints say how to get a usable backend on each OS
+var installHints = map[string]string{
+	"linux":   "Install traceroute (`apt install traceroute` or `dnf install traceroute`)",
+	"darwin":  "traceroute ships with macOS; check that /usr/sbin/traceroute exists",
+	"windows": "tracert ships with Windows; check that %SystemRoot%\\System32 is on PATH",
+}
+
+// guidance explains what is missing and how to fix it
+func guidance(caps Capabilities) []string {
+	var notes []string
+	if caps.Selected == "" {
+		hint := installHints[caps.OS]
+		if hint == "" {
+			hint = "No trace backend is supported on " + caps.OS
+		}
+		notes = append(notes, "No usable trace backend was found. "+hint)
+	}
+	for _, info := range caps.Backends {
+		if info.Path != "" && !info.Usable {
+			notes = append(notes, info.Reason)
+		}
+	}
+	if caps.OS == "linux" && !caps.RawICMP && !caps.UnprivilegedICMP {
+		notes = append(notes, "ICMP probes need root, CAP_NET_RAW (`sudo setcap cap_net_raw+ep <binary>`) or your group in net.ipv4.ping_group_range (`sudo sysctl -w net.ipv4.ping_group_range=\"0 2147483647\"`); UDP probes work without them")
+	}
+	return notes
+}
+
+// SelectBackend picks the backend for a trace: choice if it is usable, or the best usable one for auto
+func SelectBackend(choice Backend, caps Capabilities) (Backend, error) {
+	if choice == "" || choice == BackendAuto {
+		if caps.Selected == "" {
+			te := newTraceError(CodeBinaryNotFound, "", nil)
+			te.Message = "no usable trace backend"
+			if len(caps.Guidance) > 0 {
+				te.Remediation = caps.Guidance[0]
+			}
+			return "", te
+		}
+		return caps.Selected, nil
+	}
+
+	info, ok := caps.Backend(choice)
+	if !ok {
+		te := newTraceError(CodeBinaryNotFound, "", nil)
+		te.Message = fmt.Sprintf("%s is not a trace backend on %s", choice, caps.OS)
+		te.Remediation = "Choose auto, or one of the backends listed by the capability probe"
+		return "", te
+	}
+	if !info.Usable {
+		te := newTraceError(CodeBinaryNotFound, "", nil)
+		te.Message = info.Reason
+		if caps.Selected != "" {
+			te.Remediation = fmt.Sprintf("Install %s, or use %s, which is available", choice, caps.Selected)
+		}
+		return "", te
+	}
+	return choice, nil
+}
+
+// newPlatformRunner returns a runner for the backend chosen in opts, or the best one available
+// If none can run, the runner fails every trace with guidance on what to install
+func newPlatformRunner(opts Options) Runner {
+	caps := DetectCapabilities()
+	backend, err := SelectBackend(opts.Backend, caps)
+	if err != nil {
+		return failedRunner{err: err}
+	}
+
+	info, _ := caps.Backend(backend)
+	for _, b := range platformBackends {
+		if b.name == backend {
+			return b.new(opts, info.Path)
+		}
+	}
+	return failedRunner{err: newTraceError(CodeToolFailed, string(backend), nil)}
+}
+
+// failedRunner fails every trace with the same error
+type failedRunner struct {
+	err error
+}
+
+func (r failedRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) error {
+	return r.err
+}
@@ -0,0 +1,231 @@
+package trace
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeEnv builds a probe environment with the given tools installed and files readable
+func fakeEnv(goos string, tools []string, files map[string]string) probeEnv {
+	installed := make(map[string]bool)
+	for _, tool := range tools {
+		installed[tool] = true
+	}
+	return probeEnv{
+		goos: goos,
+		lookPath: func(file string) (string, error) {
+			if installed[file] {
+				return file, nil
+			}
+			return "", errors.New("not found")
+		},
+		readFile: func(name string) ([]byte, error) {
+			if data, ok := files[name]; ok {
+				return []byte(data), nil
+			}
+			return nil, os.ErrNotExist
+		},
+		listen: func(network string) error { return os.ErrPermission },
+		euid:   1000,
+		supported: []platformBackend{
+			{name: BackendTraceroute},
+			{name: BackendTracert},
+		},
+	}
+}
+
+// withICMP lets the fake environment open ICMP sockets on the given networks
+func withICMP(env probeEnv, networks ...string) probeEnv {
+	env.listen = func(network string) error {
+		for _, allowed := range networks {
+			if network == allowed {
+				return nil
+			}
+		}
+		return os.ErrPermission
+	}
+	return env
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	const (
+		pingRange = "/proc/sys/net/ipv4/ping_group_range"
+		status    = "/proc/self/status"
+	)
+
+	tests := []struct {
+		name         string
+		env          probeEnv
+		selected     Backend
+		path         string
+		rawICMP      bool
+		unprivileged bool
+		guidance     string
+	}{
+		{
+			name:     "traceroute on PATH",
+			env:      fakeEnv("linux", []string{"traceroute"}, nil),
+			selected: BackendTraceroute,
+			path:     "traceroute",
+			guidance: "ping_group_range",
+		},
+		{
+			name:     "traceroute only in sbin",
+			env:      fakeEnv("linux", []string{"/usr/sbin/traceroute"}, nil),
+			selected: BackendTraceroute,
+			path:     "/usr/sbin/traceroute",
+		},
+		{
+			name:     "installed but not supported",
+			env:      fakeEnv("linux", []string{"tracepath"}, nil),
+			guidance: "cannot drive tracepath",
+		},
+		{
+			name:     "nothing installed",
+			env:      fakeEnv("linux", nil, nil),
+			guidance: "apt install traceroute",
+		},
+		{
+			name:         "ping group covers the user",
+			env:          withICMP(fakeEnv("linux", []string{"traceroute"}, map[string]string{pingRange: "0\t2147483647\n"}), "udp4"),
+			selected:     BackendTraceroute,
+			path:         "traceroute",
+			unprivileged: true,
+		},
+		{
+			name:     "kernel default ping group",
+			env:      fakeEnv("linux", []string{"traceroute"}, map[string]string{pingRange: "1\t0\n"}),
+			selected: BackendTraceroute,
+			path:     "traceroute",
+		},
+		{
+			name:     "cap_net_raw",
+			env:      withICMP(fakeEnv("linux", []string{"traceroute"}, map[string]string{status: "Name:\tpacket-painter\nCapEff:\t0000000000002000\n"}), "ip4:icmp"),
+			selected: BackendTraceroute,
+			path:     "traceroute",
+			rawICMP:  true,
+		},
+		{
+			name:         "macos",
+			env:          withICMP(fakeEnv("darwin", []string{"/usr/sbin/traceroute"}, nil), "udp4"),
+			selected:     BackendTraceroute,
+			path:         "/usr/sbin/traceroute",
+			unprivileged: true,
+		},
+		{
+			name:         "windows does not search sbin",
+			env:          fakeEnv("windows", []string{"/usr/sbin/tracert"}, nil),
+			unprivileged: true,
+			guidance:     "System32",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := probe(tt.env)
+			if caps.Selected != tt.selected {
+				t.Errorf("Selected = %q, want %q", caps.Selected, tt.selected)
+			}
+			if tt.selected != "" {
+				if info, _ := caps.Backend(tt.selected); info.Path != tt.path || !info.Usable {
+					t.Errorf("%s = %+v, want usable at %s", tt.selected, info, tt.path)
+				}
+			}
+			if caps.RawICMP != tt.rawICMP || caps.UnprivilegedICMP != tt.unprivileged {
+				t.Errorf("RawICMP = %v, UnprivilegedICMP = %v", caps.RawICMP, caps.UnprivilegedICMP)
+			}
+			if tt.guidance != "" && !strings.Contains(strings.Join(caps.Guidance, "\n"), tt.guidance) {
+				t.Errorf("guidance %q does not mention %q", caps.Guidance, tt.guidance)
+			}
+		})
+	}
+}
+
+func TestProbeMTR(t *testing.T) {
+	tests := []struct {
+		name   string
+		tools  []string
+		raw    bool
+		root   bool
+		usable bool
+	}{
+		{"no mtr-packet", []string{"mtr"}, false, false, false},
+		{"root without mtr-packet", []string{"mtr"}, true, true, false},
+		{"raw ICMP without mtr-packet", []string{"mtr"}, true, false, false},
+		{"with mtr-packet", []string{"mtr", "mtr-packet"}, false, false, true},
+		{"root with mtr-packet", []string{"mtr", "mtr-packet"}, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := fakeEnv("linux", tt.tools, nil)
+			if tt.raw {
+				env = withICMP(env, "ip4:icmp")
+			}
+			env.supported = append(env.supported, platformBackend{name: BackendMTR})
+			if tt.root {
+				env.euid = 0
+			}
+			info, _ := probe(env).Backend(BackendMTR)
+			if info.Usable != tt.usable {
+				t.Errorf("mtr = %+v, want usable %v", info, tt.usable)
+			}
+			if !tt.usable && !strings.Contains(info.Reason, "mtr-packet") {
+				t.Errorf("Reason = %q, want it to name mtr-packet", info.Reason)
+			}
+		})
+	}
+}
+
+func TestSelectBackend(t *testing.T) {
+	env := fakeEnv("linux", []string{"traceroute", "tracepath"}, nil)
+	caps := probe(env)
+	none := probe(fakeEnv("linux", nil, nil))
+
+	tests := []struct {
+		name        string
+		choice      Backend
+		caps        Capabilities
+		want        Backend
+		remediation string
+	}{
+		{"auto", BackendAuto, caps, BackendTraceroute, ""},
+		{"empty is auto", "", caps, BackendTraceroute, ""},
+		{"usable choice", BackendTraceroute, caps, BackendTraceroute, ""},
+		{"unusable choice", BackendTracepath, caps, "", "use traceroute"},
+		{"not on this os", BackendTracert, caps, "", "Choose auto"},
+		{"nothing usable", BackendAuto, none, "", "apt install traceroute"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectBackend(tt.choice, tt.caps)
+			if got != tt.want {
+				t.Errorf("SelectBackend(%q) = %q, want %q", tt.choice, got, tt.want)
+			}
+			if tt.remediation == "" {
+				if err != nil {
+					t.Errorf("SelectBackend(%q) error = %v", tt.choice, err)
+				}
+				return
+			}
+			var te *TraceError
+			if !errors.As(err, &te) || te.Code != CodeBinaryNotFound || !strings.Contains(te.Remediation, tt.remediation) {
+				t.Errorf("SelectBackend(%q) error = %#v, want remediation mentioning %q", tt.choice, err, tt.remediation)
+			}
+		})
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	for input, want := range map[string]Backend{"": BackendAuto, "auto": BackendAuto, " MTR ": BackendMTR, "tracepath": BackendTracepath} {
+		if got, err := ParseBackend(input); err != nil || got != want {
+			t.Errorf("ParseBackend(%q) = %q, %v", input, got, err)
+		}
+	}
+	if _, err := ParseBackend("ping"); err == nil {
+		t.Error("ParseBackend(ping) succeeded")
+	}
+}
@@ -0,0 +1,112 @@
+package trace
+
+import (
+	"net/netip"
+)
+
+// AddressPath summarises the trace to one address of a host
+type AddressPath struct {
+	Address    string  `json:"address"`
+	SessionID  string  `json:"sessionId"`
+	Outcome    Outcome `json:"outcome"`
+	Reached    bool    `json:"reached"`
+	Hops       int     `json:"hops"`
+	EndToEndMs float64 `json:"endToEndMs"` // Destination RTT, or the last reply's if it was never reached
+	Error      string  `json:"error,omitempty"`
+}
+
+// PathComparison compares the traces to the addresses of one host within one address family
+type PathComparison struct {
+	Target     string        `json:"target"`
+	Family     int           `json:"family"` // 4 or 6
+	Paths      []AddressPath `json:"paths"`
+	SharedHops []*Hop        `json:"sharedHops"` // The path every trace took before they split
+	DivergeHop int           `json:"divergeHop"` // Hop number where the paths first differ; 0 if they never do
+}
+
+// ComparePaths compares traces of target, each to a different address
+// Each result's Target is the address it traced. Results are grouped by
+// address family, IPv4 first, as IPv4 and IPv6 paths never share hops
+func ComparePaths(target string, results []*Result) []*PathComparison {
+	var v4, v6 []*Result
+	for _, result := range results {
+		if addr, err := netip.ParseAddr(result.Target); err == nil && addr.Unmap().Is4() {
+			v4 = append(v4, result)
+		} else {
+			v6 = append(v6, result)
+		}
+	}
+
+	var comparisons []*PathComparison
+	for _, group := range []struct {
+		family  int
+		results []*Result
+	}{{4, v4}, {6, v6}} {
+		if len(group.results) > 0 {
+			comparisons = append(comparisons, comparePaths(target, group.family, group.results))
+		}
+	}
+	return comparisons
+}
+
+// comparePaths compares traces within one address family
+func comparePaths(target string, family int, results []*Result) *PathComparison {
+	c := &PathComparison{Target: target, Family: family, SharedHops: []*Hop{}}
+	// Traces that failed before their first hop say nothing about the path
+	var traced []*Result
+	for _, result := range results {
+		if len(result.Hops) > 0 {
+			traced = append(traced, result)
+		}
+		c.Paths = append(c.Paths, AddressPath{
+			Address:    result.Target,
+			SessionID:  result.SessionID,
+			Outcome:    result.Outcome,
+			Reached:    result.Reached(),
+			Hops:       len(result.Hops),
+			EndToEndMs: endToEndRTT(result.Hops),
+			Error:      result.Error,
+		})
+	}
+
+	if len(traced) == 0 {
+		return c
+	}
+	for i := 0; ; i++ {
+		hop, ok, ended := sharedHop(traced, i)
+		if ended {
+			return c
+		}
+		if !ok {
+			c.DivergeHop = i + 1
+			return c
+		}
+		c.SharedHops = append(c.SharedHops, hop)
+	}
+}
+
+// sharedHop returns the hop at position i if every trace agrees on it
+// A timed-out hop matches any address, as it may well be the same router.
+// ended reports that every trace stopped before position i
+func sharedHop(results []*Result, i int) (hop *Hop, ok, ended bool) {
+	present := 0
+	for _, result := range results {
+		if i >= len(result.Hops) {
+			continue
+		}
+		present++
+		candidate := result.Hops[i]
+		switch {
+		case hop == nil || hop.IsTimeout:
+			// Prefer a hop that answered as the representative
+			hop = candidate
+		case !candidate.IsTimeout && candidate.IPAddress != hop.IPAddress:
+			return nil, false, false
+		}
+	}
+	if present == 0 {
+		return nil, false, true
+	}
+	// Some traces ended here while others went on
+	return hop, present == len(results), false
+}
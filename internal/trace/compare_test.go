@@ -0,0 +1,130 @@
+package trace
+
+import (
+	"testing"
+)
+
+// path builds a result tracing address through the given hop addresses, "*" for a timeout
+// The last hop is the destination when it equals address
+func path(address string, ips ...string) *Result {
+	result := &Result{Target: address, Outcome: OutcomeCompleted}
+	for i, ip := range ips {
+		hop := &Hop{HopNumber: i + 1, IPAddress: ip, AvgRTT: float64(10 * (i + 1)), IsTimeout: ip == "*"}
+		hop.IsDestination = ip == address
+		result.Hops = append(result.Hops, hop)
+	}
+	return result
+}
+
+func TestComparePaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		results    []*Result
+		families   []int
+		shared     []string
+		divergeHop int
+	}{
+		{
+			name: "split after shared prefix",
+			results: []*Result{
+				path("192.0.2.1", "10.0.0.1", "198.51.100.1", "198.51.100.9", "192.0.2.1"),
+				path("192.0.2.2", "10.0.0.1", "198.51.100.1", "203.0.113.5", "192.0.2.2"),
+			},
+			families:   []int{4},
+			shared:     []string{"10.0.0.1", "198.51.100.1"},
+			divergeHop: 3,
+		},
+		{
+			name: "timeouts match any router",
+			results: []*Result{
+				path("192.0.2.1", "10.0.0.1", "*", "198.51.100.9", "192.0.2.1"),
+				path("192.0.2.2", "10.0.0.1", "198.51.100.1", "198.51.100.9", "192.0.2.2"),
+			},
+			families:   []int{4},
+			shared:     []string{"10.0.0.1", "198.51.100.1", "198.51.100.9"},
+			divergeHop: 4,
+		},
+		{
+			name: "one path is longer",
+			results: []*Result{
+				path("192.0.2.1", "10.0.0.1", "*"),
+				path("192.0.2.2", "10.0.0.1", "*", "192.0.2.2"),
+			},
+			families:   []int{4},
+			shared:     []string{"10.0.0.1", "*"},
+			divergeHop: 3,
+		},
+		{
+			name:     "single path never diverges",
+			results:  []*Result{path("192.0.2.1", "10.0.0.1", "192.0.2.1")},
+			families: []int{4},
+			shared:   []string{"10.0.0.1", "192.0.2.1"},
+		},
+		{
+			name: "failed trace is ignored",
+			results: []*Result{
+				path("192.0.2.1", "10.0.0.1", "192.0.2.1"),
+				{Target: "192.0.2.2", Outcome: OutcomeError, Error: "boom"},
+			},
+			families: []int{4},
+			shared:   []string{"10.0.0.1", "192.0.2.1"},
+		},
+		{
+			name: "families compared apart",
+			results: []*Result{
+				path("2001:db8::1", "fe80::1", "2001:db8::1"),
+				path("192.0.2.1", "10.0.0.1", "192.0.2.1"),
+			},
+			families: []int{4, 6},
+			shared:   []string{"10.0.0.1", "192.0.2.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparisons := ComparePaths("example.com", tt.results)
+			if len(comparisons) != len(tt.families) {
+				t.Fatalf("got %d comparisons, want %d", len(comparisons), len(tt.families))
+			}
+			for i, family := range tt.families {
+				if comparisons[i].Family != family || comparisons[i].Target != "example.com" {
+					t.Errorf("comparison %d = family %d target %s", i, comparisons[i].Family, comparisons[i].Target)
+				}
+			}
+
+			first := comparisons[0]
+			var shared []string
+			for _, hop := range first.SharedHops {
+				shared = append(shared, hop.IPAddress)
+			}
+			if len(shared) != len(tt.shared) {
+				t.Fatalf("shared = %v, want %v", shared, tt.shared)
+			}
+			for i := range shared {
+				if shared[i] != tt.shared[i] {
+					t.Errorf("shared = %v, want %v", shared, tt.shared)
+					break
+				}
+			}
+			if first.DivergeHop != tt.divergeHop {
+				t.Errorf("DivergeHop = %d, want %d", first.DivergeHop, tt.divergeHop)
+			}
+		})
+	}
+}
+
+func TestComparePathsEndToEnd(t *testing.T) {
+	comparisons := ComparePaths("example.com", []*Result{
+		path("192.0.2.1", "10.0.0.1", "192.0.2.1"),
+		path("192.0.2.2", "10.0.0.1", "198.51.100.1", "*"),
+	})
+
+	paths := comparisons[0].Paths
+	if !paths[0].Reached || paths[0].EndToEndMs != 20 {
+		t.Errorf("reached path = %+v", paths[0])
+	}
+	// Not reached: the last reply stands in for the destination
+	if paths[1].Reached || paths[1].EndToEndMs != 20 || paths[1].Hops != 3 {
+		t.Errorf("unreached path = %+v", paths[1])
+	}
+}
@@ -0,0 +1,136 @@
+package trace
+
+import (
+	"sync"
+	"time"
+)
+
+// PathOutcome describes how far a completed trace got
+type PathOutcome string
+
+const (
+	PathReached     PathOutcome = "reached"     // The destination replied
+	PathPartial     PathOutcome = "partial"     // Stopped while routers were still replying
+	PathUnreachable PathOutcome = "unreachable" // The path went silent, or a router reported the destination unreachable
+	PathProhibited  PathOutcome = "prohibited"  // A router reported the destination administratively prohibited
+)
+
+// StopReason says why a completed trace stopped
+type StopReason string
+
+const (
+	ReasonDestination      StopReason = "destination"       // The destination replied
+	ReasonMaxHops          StopReason = "max_hops"          // The tool probed every hop up to MaxHops
+	ReasonTrailingTimeouts StopReason = "trailing_timeouts" // Too many hops in a row timed out
+	ReasonDeadline         StopReason = "deadline"          // The time budget ran out
+	ReasonUnreachable      StopReason = "unreachable"       // A router reported the destination unreachable
+)
+
+// DeadlinePolicy bounds how long a trace runs
+// The budget starts at Initial and is pushed out to PerHop past every hop
+// that arrives, but never beyond Max from the start. A trace also stops once
+// TrailingTimeouts hops in a row have timed out, as the rest of the path is
+// most likely silent too
+type DeadlinePolicy struct {
+	Initial          time.Duration // Budget before the first hop
+	PerHop           time.Duration // Budget left after each hop
+	Max              time.Duration // Hard limit on the whole trace; 0 for none
+	TrailingTimeouts int           // Timed-out hops in a row that end the trace; 0 to never stop early
+}
+
+// DefaultDeadlinePolicy returns the policy for a trace run with opts
+// A silent hop costs ProbesPerHop × WaitSeconds, so the per-hop budget allows for it
+func DefaultDeadlinePolicy(opts Options) DeadlinePolicy {
+	opts = opts.WithDefaults()
+	silentHop := time.Duration(opts.ProbesPerHop*opts.WaitSeconds) * time.Second
+	return DeadlinePolicy{
+		Initial:          10*time.Second + silentHop,
+		PerHop:           5*time.Second + 2*silentHop,
+		Max:              2 * time.Minute,
+		TrailingTimeouts: 5,
+	}
+}
+
+// budget enforces a deadline policy on a running session
+type budget struct {
+	policy   DeadlinePolicy
+	stop     func(StopReason)
+	mu       sync.Mutex
+	start    time.Time
+	deadline time.Time
+	timer    *time.Timer
+	trailing int
+}
+
+// startBudget starts the clock on a policy; stop is called at most once when it runs out
+func startBudget(policy DeadlinePolicy, stop func(StopReason)) *budget {
+	b := &budget{policy: policy, stop: stop, start: time.Now()}
+	b.deadline = b.cap(b.start.Add(policy.Initial))
+	b.timer = time.AfterFunc(time.Until(b.deadline), func() { b.stop(ReasonDeadline) })
+	return b
+}
+
+// cap limits a deadline to the policy's Max
+func (b *budget) cap(deadline time.Time) time.Time {
+	if b.policy.Max > 0 && deadline.After(b.start.Add(b.policy.Max)) {
+		return b.start.Add(b.policy.Max)
+	}
+	return deadline
+}
+
+// hop extends the budget for an arriving hop and counts trailing timeouts
+func (b *budget) hop(hop *Hop) {
+	b.mu.Lock()
+	if hop.IsTimeout {
+		b.trailing++
+	} else {
+		b.trailing = 0
+	}
+	stopEarly := b.policy.TrailingTimeouts > 0 && b.trailing >= b.policy.TrailingTimeouts
+
+	if extended := b.cap(time.Now().Add(b.policy.PerHop)); extended.After(b.deadline) {
+		b.deadline = extended
+		b.timer.Reset(time.Until(extended))
+	}
+	b.mu.Unlock()
+
+	if stopEarly {
+		b.stop(ReasonTrailingTimeouts)
+	}
+}
+
+// close stops the clock
+func (b *budget) close() {
+	b.timer.Stop()
+}
+
+// pathOutcome classifies how far a trace that stopped for reason got
+func pathOutcome(reason StopReason, hops []*Hop) PathOutcome {
+	for _, hop := range hops {
+		if hop.IsDestination {
+			return PathReached
+		}
+	}
+	if last := lastUnreachable(hops); last != "" {
+		if last.Prohibited() {
+			return PathProhibited
+		}
+		return PathUnreachable
+	}
+	switch {
+	case reason == ReasonTrailingTimeouts:
+		return PathUnreachable
+	case reason == ReasonMaxHops && (len(hops) == 0 || hops[len(hops)-1].IsTimeout):
+		return PathUnreachable
+	default:
+		return PathPartial
+	}
+}
+
+// lastUnreachable returns why the last hop reported the destination unreachable, if it did
+func lastUnreachable(hops []*Hop) Unreachable {
+	if len(hops) == 0 {
+		return ""
+	}
+	return hops[len(hops)-1].Unreachable
+}
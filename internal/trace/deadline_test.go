@@ -0,0 +1,189 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"packet-painter/internal/geo"
+)
+
+// pacedRunner emits hops at a steady pace, then repeats repeat until
+// cancelled, hangs until cancelled, or completes
+type pacedRunner struct {
+	hops   []*Hop
+	every  time.Duration
+	repeat *Hop
+	hang   bool
+}
+
+func (r *pacedRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) error {
+	emit := func(hop *Hop) error {
+		select {
+		case <-time.After(r.every):
+			onHop(hop)
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for _, hop := range r.hops {
+		if err := emit(hop); err != nil {
+			return err
+		}
+	}
+	for r.repeat != nil {
+		if err := emit(r.repeat); err != nil {
+			return err
+		}
+	}
+	if r.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	onComplete(len(r.hops))
+	return nil
+}
+
+func TestDeadlinePolicy(t *testing.T) {
+	router := &Hop{HopNumber: 1, IPAddress: "192.168.1.1"}
+	silent := &Hop{HopNumber: 2, IsTimeout: true}
+	destination := &Hop{HopNumber: 3, IPAddress: "8.8.8.8", IsDestination: true}
+	const tick = 10 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		runner  *pacedRunner
+		policy  DeadlinePolicy
+		timeout time.Duration // Caller's own deadline; 0 for none
+		status  Status
+		outcome PathOutcome
+		reason  StopReason
+		hops    int
+	}{
+		{
+			name:    "budget extends while hops arrive",
+			runner:  &pacedRunner{hops: []*Hop{router, router, router, router, router, router, destination}, every: tick},
+			policy:  DeadlinePolicy{Initial: 3 * tick, PerHop: 5 * tick},
+			status:  StatusCompleted,
+			outcome: PathReached,
+			reason:  ReasonDestination,
+			hops:    7,
+		},
+		{
+			name:    "tool gives up in silence",
+			runner:  &pacedRunner{hops: []*Hop{router, silent}, every: tick},
+			policy:  DeadlinePolicy{Initial: time.Second, PerHop: time.Second},
+			status:  StatusCompleted,
+			outcome: PathUnreachable,
+			reason:  ReasonMaxHops,
+			hops:    2,
+		},
+		{
+			name:    "trailing timeouts",
+			runner:  &pacedRunner{hops: []*Hop{router, silent, router}, every: tick, repeat: silent},
+			policy:  DeadlinePolicy{Initial: time.Second, PerHop: time.Second, TrailingTimeouts: 3},
+			status:  StatusCompleted,
+			outcome: PathUnreachable,
+			reason:  ReasonTrailingTimeouts,
+			hops:    6,
+		},
+		{
+			name:    "no hop within the budget",
+			runner:  &pacedRunner{hops: []*Hop{router, router}, every: tick, hang: true},
+			policy:  DeadlinePolicy{Initial: time.Second, PerHop: 3 * tick},
+			status:  StatusCompleted,
+			outcome: PathPartial,
+			reason:  ReasonDeadline,
+			hops:    2,
+		},
+		{
+			name:    "hard limit",
+			runner:  &pacedRunner{every: tick, repeat: router},
+			policy:  DeadlinePolicy{Initial: time.Second, PerHop: time.Second, Max: 8 * tick},
+			status:  StatusCompleted,
+			outcome: PathPartial,
+			reason:  ReasonDeadline,
+		},
+		{
+			name:    "caller deadline keeps hops",
+			runner:  &pacedRunner{hops: []*Hop{router}, every: tick, hang: true},
+			policy:  DeadlinePolicy{Initial: time.Second, PerHop: time.Second},
+			timeout: 5 * tick,
+			status:  StatusCompleted,
+			outcome: PathPartial,
+			reason:  ReasonDeadline,
+			hops:    1,
+		},
+		{
+			name:   "nothing before the deadline",
+			runner: &pacedRunner{hang: true},
+			policy: DeadlinePolicy{Initial: 3 * tick},
+			status: StatusError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+			defer cancel()
+
+			session := NewSessionWithRunner("8.8.8.8", DefaultOptions(), tt.runner)
+			session.SetDeadlinePolicy(tt.policy)
+
+			var completed int
+			session.Start(ctx, nil, func(totalHops int) { completed++ }, nil)
+			<-session.Done()
+			result := session.Snapshot()
+
+			if session.Status() != tt.status {
+				t.Fatalf("Status() = %s, want %s (error %q)", session.Status(), tt.status, result.Error)
+			}
+			if tt.status == StatusError {
+				if result.ErrorCode != CodeDeadlineExceeded {
+					t.Errorf("ErrorCode = %q, want %s", result.ErrorCode, CodeDeadlineExceeded)
+				}
+				return
+			}
+			if result.PathOutcome != tt.outcome || result.StopReason != tt.reason {
+				t.Errorf("path = %s/%s, want %s/%s", result.PathOutcome, result.StopReason, tt.outcome, tt.reason)
+			}
+			if tt.hops > 0 && len(result.Hops) != tt.hops {
+				t.Errorf("hops = %d, want %d", len(result.Hops), tt.hops)
+			}
+			if completed != 1 {
+				t.Errorf("onComplete called %d times, want 1", completed)
+			}
+		})
+	}
+}
+
+func TestResultPath(t *testing.T) {
+	router := &Hop{HopNumber: 1, IPAddress: "192.168.1.1"}
+	silent := &Hop{HopNumber: 2, IsTimeout: true}
+	destination := &Hop{HopNumber: 2, IPAddress: "8.8.8.8", IsDestination: true}
+
+	tests := []struct {
+		name    string
+		result  Result
+		outcome PathOutcome
+		reason  StopReason
+	}{
+		{"imported, reached", Result{Hops: []*Hop{router, destination}}, PathReached, ReasonDestination},
+		{"imported, silent", Result{Hops: []*Hop{router, silent}}, PathUnreachable, ReasonMaxHops},
+		{"imported, still answering", Result{Hops: []*Hop{router}}, PathPartial, ReasonMaxHops},
+		{"recorded", Result{Hops: []*Hop{router}, StopReason: ReasonDeadline}, PathPartial, ReasonDeadline},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome, reason := tt.result.Path()
+			if outcome != tt.outcome || reason != tt.reason {
+				t.Errorf("Path() = %s, %s; want %s, %s", outcome, reason, tt.outcome, tt.reason)
+			}
+		})
+	}
+}
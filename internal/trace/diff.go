@@ -0,0 +1,216 @@
+package trace
+
+// DiffKind classifies an aligned hop position between two traces
+type DiffKind string
+
+const (
+	DiffUnchanged      DiffKind = "unchanged"       // Same address at this position
+	DiffChangedIP      DiffKind = "changed-ip"      // Different address in the same network and city
+	DiffChangedNetwork DiffKind = "changed-network" // Different ASN or city
+	DiffAdded          DiffKind = "added"           // Hop only present in trace B
+	DiffRemoved        DiffKind = "removed"         // Hop only present in trace A
+	DiffUnknown        DiffKind = "unknown"         // One side timed out, so no comparison is possible
+)
+
+// Alignment costs used by Diff
+// A substitution is always cheaper than a removal plus an addition, and a
+// timeout matches anything cheaply so missing replies don't shift the path
+const (
+	costGap            = 1.0
+	costChangedNetwork = 1.0
+	costChangedIP      = 0.75
+	costTimeout        = 0.25
+)
+
+// HopDiff describes one aligned position in a trace diff
+type HopDiff struct {
+	Kind            DiffKind `json:"kind"`
+	A               *Hop     `json:"a"`                 // nil when added
+	B               *Hop     `json:"b"`                 // nil when removed
+	Changes         []string `json:"changes,omitempty"` // Which details differ: "ip", "asn", "city"
+	LatencyDeltaMs  float64  `json:"latencyDeltaMs"`    // B minus A average RTT
+	HasLatencyDelta bool     `json:"hasLatencyDelta"`   // False unless both hops answered
+}
+
+// TraceDiff is the result of comparing two traces
+type TraceDiff struct {
+	SessionA        string    `json:"sessionA,omitempty"`
+	SessionB        string    `json:"sessionB,omitempty"`
+	TargetA         string    `json:"targetA,omitempty"`
+	TargetB         string    `json:"targetB,omitempty"`
+	Entries         []HopDiff `json:"entries"`
+	Unchanged       int       `json:"unchanged"`
+	Changed         int       `json:"changed"`
+	Added           int       `json:"added"`
+	Removed         int       `json:"removed"`
+	RouteChanged    bool      `json:"routeChanged"`
+	FirstDivergence int       `json:"firstDivergence"` // Index into Entries, or -1
+	EndToEndA       float64   `json:"endToEndA"`       // Destination (or last reply) RTT of A
+	EndToEndB       float64   `json:"endToEndB"`
+	EndToEndDeltaMs float64   `json:"endToEndDeltaMs"`
+}
+
+// DiffResults compares two recorded traces
+func DiffResults(a, b *Result) *TraceDiff {
+	diff := Diff(a.Hops, b.Hops)
+	diff.SessionA = a.SessionID
+	diff.SessionB = b.SessionID
+	diff.TargetA = a.Target
+	diff.TargetB = b.Target
+	return diff
+}
+
+// Diff aligns two hop sequences and classifies every position
+// Alignment is a global edit-distance alignment, so a single extra hop shows
+// up as one addition instead of shifting every later hop
+func Diff(a, b []*Hop) *TraceDiff {
+	n, m := len(a), len(b)
+
+	// cost[i][j] is the cheapest alignment of a[i:] with b[j:]
+	cost := make([][]float64, n+1)
+	for i := range cost {
+		cost[i] = make([]float64, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		cost[i][m] = cost[i+1][m] + costGap
+	}
+	for j := m - 1; j >= 0; j-- {
+		cost[n][j] = cost[n][j+1] + costGap
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			best := cost[i+1][j+1] + substitutionCost(a[i], b[j])
+			if c := cost[i+1][j] + costGap; c < best {
+				best = c
+			}
+			if c := cost[i][j+1] + costGap; c < best {
+				best = c
+			}
+			cost[i][j] = best
+		}
+	}
+
+	diff := &TraceDiff{FirstDivergence: -1}
+
+	// Walk the cheapest path, preferring to pair hops on ties
+	i, j := 0, 0
+	for i < n || j < m {
+		var entry HopDiff
+		switch {
+		case i < n && j < m && cost[i][j] == cost[i+1][j+1]+substitutionCost(a[i], b[j]):
+			entry = compareHops(a[i], b[j])
+			i++
+			j++
+		case i < n && (j == m || cost[i][j] == cost[i+1][j]+costGap):
+			entry = HopDiff{Kind: DiffRemoved, A: a[i]}
+			i++
+		default:
+			entry = HopDiff{Kind: DiffAdded, B: b[j]}
+			j++
+		}
+		diff.add(entry)
+	}
+
+	diff.EndToEndA = endToEndRTT(a)
+	diff.EndToEndB = endToEndRTT(b)
+	diff.EndToEndDeltaMs = diff.EndToEndB - diff.EndToEndA
+
+	return diff
+}
+
+// add appends an entry and updates the summary counts
+func (d *TraceDiff) add(entry HopDiff) {
+	switch entry.Kind {
+	case DiffUnchanged, DiffUnknown:
+		d.Unchanged++
+	case DiffChangedIP, DiffChangedNetwork:
+		d.Changed++
+	case DiffAdded:
+		d.Added++
+	case DiffRemoved:
+		d.Removed++
+	}
+
+	if entry.Kind != DiffUnchanged && entry.Kind != DiffUnknown {
+		d.RouteChanged = true
+		if d.FirstDivergence < 0 {
+			d.FirstDivergence = len(d.Entries)
+		}
+	}
+
+	d.Entries = append(d.Entries, entry)
+}
+
+// compareHops classifies two aligned hops
+func compareHops(a, b *Hop) HopDiff {
+	entry := HopDiff{A: a, B: b}
+
+	switch {
+	case a.IsTimeout && b.IsTimeout:
+		entry.Kind = DiffUnchanged
+		return entry
+	case a.IsTimeout || b.IsTimeout:
+		entry.Kind = DiffUnknown
+		return entry
+	}
+
+	entry.LatencyDeltaMs = b.AvgRTT - a.AvgRTT
+	entry.HasLatencyDelta = true
+
+	if a.IPAddress == b.IPAddress {
+		entry.Kind = DiffUnchanged
+		return entry
+	}
+
+	entry.Changes = append(entry.Changes, "ip")
+	entry.Kind = DiffChangedIP
+	for _, change := range networkChanges(a, b) {
+		entry.Changes = append(entry.Changes, change)
+		entry.Kind = DiffChangedNetwork
+	}
+	return entry
+}
+
+// substitutionCost returns the cost of aligning two hops at the same position
+func substitutionCost(a, b *Hop) float64 {
+	switch {
+	case a.IsTimeout && b.IsTimeout:
+		return 0
+	case a.IsTimeout || b.IsTimeout:
+		return costTimeout
+	case a.IPAddress == b.IPAddress:
+		return 0
+	case len(networkChanges(a, b)) == 0:
+		return costChangedIP
+	default:
+		return costChangedNetwork
+	}
+}
+
+// networkChanges lists the network details that differ between two hops
+// Details that are unknown on either side are not counted as changes
+func networkChanges(a, b *Hop) []string {
+	var changes []string
+	if a.ASN != "" && b.ASN != "" && a.ASN != b.ASN {
+		changes = append(changes, "asn")
+	}
+	if a.Location != nil && b.Location != nil && a.Location.City != "" && b.Location.City != "" && a.Location.City != b.Location.City {
+		changes = append(changes, "city")
+	}
+	return changes
+}
+
+// endToEndRTT returns the destination RTT, or the last reply if it was never reached
+func endToEndRTT(hops []*Hop) float64 {
+	for i := len(hops) - 1; i >= 0; i-- {
+		if hops[i].IsDestination {
+			return hops[i].AvgRTT
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !hops[i].IsTimeout {
+			return hops[i].AvgRTT
+		}
+	}
+	return 0
+}
@@ -0,0 +1,122 @@
+package trace
+
+import (
+	"testing"
+
+	"packet-painter/internal/geo"
+)
+
+// diffHop builds a responding hop for diff tests
+func diffHop(ip string, rtt float64, asn, city string) *Hop {
+	return &Hop{
+		IPAddress: ip,
+		AvgRTT:    rtt,
+		ASN:       asn,
+		Location:  &geo.Location{City: city},
+	}
+}
+
+// timeoutHop builds a timed-out hop for diff tests
+func timeoutHop() *Hop {
+	return &Hop{IPAddress: "*", IsTimeout: true}
+}
+
+func TestDiff(t *testing.T) {
+	a := []*Hop{
+		diffHop("192.168.1.1", 1, "", ""),
+		diffHop("10.0.0.1", 5, "AS7922", "New York"),
+		timeoutHop(),
+		diffHop("154.54.1.1", 20, "AS174", "New York"),
+		diffHop("8.8.8.8", 30, "AS15169", "Mountain View"),
+	}
+	b := []*Hop{
+		diffHop("192.168.1.1", 1, "", ""),
+		diffHop("10.0.0.2", 6, "AS7922", "New York"),
+		diffHop("68.86.1.1", 9, "AS7922", "Newark"),
+		diffHop("129.250.1.1", 25, "AS2914", "New York"),
+		diffHop("8.8.8.8", 45, "AS15169", "Mountain View"),
+	}
+	b[4].IsDestination = true
+	a[4].IsDestination = true
+
+	diff := Diff(a, b)
+
+	expected := []DiffKind{DiffUnchanged, DiffChangedIP, DiffUnknown, DiffChangedNetwork, DiffUnchanged}
+	if len(diff.Entries) != len(expected) {
+		t.Fatalf("got %d entries, want %d", len(diff.Entries), len(expected))
+	}
+	for i, entry := range diff.Entries {
+		if entry.Kind != expected[i] {
+			t.Errorf("entry %d kind = %s, want %s", i, entry.Kind, expected[i])
+		}
+	}
+
+	if !diff.RouteChanged || diff.FirstDivergence != 1 {
+		t.Errorf("RouteChanged = %v, FirstDivergence = %d, want true, 1", diff.RouteChanged, diff.FirstDivergence)
+	}
+	if diff.EndToEndDeltaMs != 15 {
+		t.Errorf("EndToEndDeltaMs = %f, want 15", diff.EndToEndDeltaMs)
+	}
+	if last := diff.Entries[4]; !last.HasLatencyDelta || last.LatencyDeltaMs != 15 {
+		t.Errorf("last entry latency delta = %v %f, want 15", last.HasLatencyDelta, last.LatencyDeltaMs)
+	}
+}
+
+func TestDiffAlignsExtraHops(t *testing.T) {
+	a := []*Hop{
+		diffHop("192.168.1.1", 1, "", ""),
+		diffHop("10.0.0.1", 5, "", ""),
+		diffHop("8.8.8.8", 30, "", ""),
+	}
+	b := []*Hop{
+		diffHop("192.168.1.1", 1, "", ""),
+		diffHop("10.0.0.1", 5, "", ""),
+		diffHop("10.9.9.9", 12, "", ""),
+		diffHop("8.8.8.8", 30, "", ""),
+	}
+
+	diff := Diff(a, b)
+
+	expected := []DiffKind{DiffUnchanged, DiffUnchanged, DiffAdded, DiffUnchanged}
+	if len(diff.Entries) != len(expected) {
+		t.Fatalf("got %d entries, want %d", len(diff.Entries), len(expected))
+	}
+	for i, entry := range diff.Entries {
+		if entry.Kind != expected[i] {
+			t.Errorf("entry %d kind = %s, want %s", i, entry.Kind, expected[i])
+		}
+	}
+	if diff.Added != 1 || diff.Removed != 0 || diff.Changed != 0 {
+		t.Errorf("counts = +%d -%d ~%d, want +1 -0 ~0", diff.Added, diff.Removed, diff.Changed)
+	}
+
+	// Reversing the traces turns the addition into a removal
+	reversed := Diff(b, a)
+	if reversed.Removed != 1 || reversed.Entries[2].Kind != DiffRemoved {
+		t.Errorf("reversed diff = %+v, want hop 3 removed", reversed.Entries)
+	}
+}
+
+func TestDiffASNWithoutLocation(t *testing.T) {
+	// AS numbers from traceroute -A arrive without any geolocation
+	a := []*Hop{{IPAddress: "154.54.1.1", AvgRTT: 20, ASN: "AS174"}}
+	b := []*Hop{{IPAddress: "129.250.1.1", AvgRTT: 25, ASN: "AS2914"}}
+
+	diff := Diff(a, b)
+	if len(diff.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(diff.Entries))
+	}
+	entry := diff.Entries[0]
+	if entry.Kind != DiffChangedNetwork || len(entry.Changes) != 2 || entry.Changes[1] != "asn" {
+		t.Errorf("entry = %+v, want changed-network with ip and asn changes", entry)
+	}
+}
+
+func TestDiffIdenticalTraces(t *testing.T) {
+	hops := []*Hop{diffHop("192.168.1.1", 1, "", ""), timeoutHop(), diffHop("8.8.8.8", 30, "", "")}
+
+	diff := Diff(hops, hops)
+	if diff.RouteChanged || diff.FirstDivergence != -1 || diff.Unchanged != 3 {
+		t.Errorf("diff = %+v, want no route change", diff)
+	}
+}
@@ -0,0 +1,183 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrorCode identifies a class of trace failure
+// Codes are stable so the UI, CLI and API clients can react to them
+type ErrorCode string
+
+const (
+	CodeBinaryNotFound     ErrorCode = "binary_not_found"
+	CodePermissionDenied   ErrorCode = "permission_denied"
+	CodeUnknownHost        ErrorCode = "unknown_host"
+	CodeInvalidTarget      ErrorCode = "invalid_target"
+	CodeNetworkUnreachable ErrorCode = "network_unreachable"
+	CodeDeadlineExceeded   ErrorCode = "deadline_exceeded"
+	CodeToolFailed         ErrorCode = "tool_failed" // The tool failed for a reason we don't recognise
+)
+
+// TraceError is a classified trace failure
+type TraceError struct {
+	Code        ErrorCode `json:"code"`
+	Message     string    `json:"message"`          // What went wrong, for people
+	Remediation string    `json:"remediation"`      // Suggested fix
+	Detail      string    `json:"detail,omitempty"` // Raw tool output or error
+	Err         error     `json:"-"`
+}
+
+// Error returns the message with the tool's own detail appended
+func (e *TraceError) Error() string {
+	if e.Detail == "" {
+		return e.Message
+	}
+	return e.Message + ": " + e.Detail
+}
+
+// Unwrap returns the underlying error
+func (e *TraceError) Unwrap() error {
+	return e.Err
+}
+
+// AsTraceError returns err as a TraceError, classifying deadlines and unknown errors
+// Returns nil for a nil error and for cancellation, which is not a failure
+func AsTraceError(err error) *TraceError {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	var te *TraceError
+	if errors.As(err, &te) {
+		return te
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return newTraceError(CodeDeadlineExceeded, "", err)
+	}
+	return newTraceError(CodeToolFailed, err.Error(), err)
+}
+
+// ErrorCodeOf returns the code of a trace failure, or "" if err is nil or a cancellation
+func ErrorCodeOf(err error) ErrorCode {
+	if te := AsTraceError(err); te != nil {
+		return te.Code
+	}
+	return ""
+}
+
+// errorText holds the default message and remediation of each code
+var errorText = map[ErrorCode][2]string{
+	CodeBinaryNotFound:     {"trace tool is not installed", "Install traceroute (e.g. `apt install traceroute`, `dnf install traceroute`), or choose another backend"},
+	CodePermissionDenied:   {"not permitted to send trace probes", "Run with the privileges the tool needs (e.g. `setcap cap_net_raw+ep` on the binary), or use an unprivileged backend such as tracepath"},
+	CodeUnknownHost:        {"target could not be resolved", "Check the hostname for typos and that DNS is reachable, or trace an IP address instead"},
+	CodeInvalidTarget:      {"target is not a hostname or IP address", "Enter a hostname, IP address or URL"},
+	CodeNetworkUnreachable: {"network is unreachable", "Check that this machine has a network connection and a route to the target (VPN, firewall, default gateway)"},
+	CodeDeadlineExceeded:   {"trace timed out", "Allow a longer timeout, or lower the max hops or wait per probe"},
+	CodeToolFailed:         {"trace failed", "Check the error details; try another backend if the problem persists"},
+}
+
+// newTraceError builds a TraceError with the code's default message and remediation
+func newTraceError(code ErrorCode, detail string, err error) *TraceError {
+	text := errorText[code]
+	return &TraceError{Code: code, Message: text[0], Remediation: text[1], Detail: detail, Err: err}
+}
+
+// failurePatterns map lowercased tool output to a code, across traceroute
+// (Linux, macOS and BSD), tracert, tracepath and mtr. Order matters: the
+// first match wins
+var failurePatterns = []struct {
+	code     ErrorCode
+	patterns []string
+}{
+	{CodeBinaryNotFound, []string{
+		"executable file not found",
+		"command not found",
+		"is not recognized as an internal or external command",
+	}},
+	{CodeUnknownHost, []string{
+		"name or service not known",
+		"unknown host",
+		"cannot resolve",
+		"temporary failure in name resolution",
+		"no address associated with hostname",
+		"nodename nor servname provided",
+		"unable to resolve target system name",
+		"cannot handle \"host\" cmdline arg",
+		"failed to resolve host",
+	}},
+	{CodePermissionDenied, []string{
+		"operation not permitted",
+		"permission denied",
+		"access is denied",
+		"you do not have enough privileges",
+		"must be root",
+		"requires root",
+	}},
+	{CodeNetworkUnreachable, []string{
+		"network is unreachable",
+		"no route to host",
+		"transmit failed. general failure",
+		"unable to contact ip driver",
+		"can't assign requested address",
+	}},
+}
+
+// classifyOutput returns the code matching a tool's output, if any
+func classifyOutput(output string) (ErrorCode, bool) {
+	lower := strings.ToLower(output)
+	for _, class := range failurePatterns {
+		for _, pattern := range class.patterns {
+			if strings.Contains(lower, pattern) {
+				return class.code, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkTarget refuses targets a trace tool would parse as an option
+// Callers validate targets up front; this keeps a bad one away from the command line regardless
+func checkTarget(target string) error {
+	if target == "" || strings.HasPrefix(target, "-") || strings.ContainsAny(target, " \t\r\n") {
+		return newTraceError(CodeInvalidTarget, target, nil)
+	}
+	return nil
+}
+
+// startFailure classifies an error from starting a trace tool
+func startFailure(tool string, err error) *TraceError {
+	var te *TraceError
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, os.ErrNotExist):
+		te = newTraceError(CodeBinaryNotFound, err.Error(), err)
+		te.Message = tool + " is not installed"
+		te.Remediation = "Install " + tool + " with your package manager, or choose another backend"
+	case errors.Is(err, os.ErrPermission):
+		te = newTraceError(CodePermissionDenied, err.Error(), err)
+	default:
+		te = newTraceError(CodeToolFailed, err.Error(), err)
+		te.Message = "failed to start " + tool
+	}
+	return te
+}
+
+// toolFailure classifies a trace tool that exited with an error
+// output is the tool's stderr, plus any stdout lines that were not hops
+func toolFailure(tool string, err error, output string) *TraceError {
+	output = strings.TrimSpace(output)
+	detail := err.Error()
+	if output != "" {
+		detail += ": " + output
+	}
+
+	code, ok := classifyOutput(output)
+	if !ok {
+		te := newTraceError(CodeToolFailed, detail, err)
+		te.Message = tool + " failed"
+		return te
+	}
+	return newTraceError(code, detail, err)
+}
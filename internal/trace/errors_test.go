@@ -0,0 +1,118 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestToolFailure(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+
+	tests := []struct {
+		name     string
+		tool     string
+		output   string
+		expected ErrorCode
+	}{
+		{"linux unknown host", "traceroute", "traceroute: nosuch.invalid: Name or service not known\nCannot handle \"host\" cmdline arg `nosuch.invalid' on position 1 (argc 6)", CodeUnknownHost},
+		{"linux resolver down", "traceroute", "nosuch.example: Temporary failure in name resolution", CodeUnknownHost},
+		{"macos unknown host", "traceroute", "traceroute: unknown host nosuch.invalid", CodeUnknownHost},
+		{"macos resolver", "traceroute", "traceroute: getaddrinfo: nodename nor servname provided, or not known", CodeUnknownHost},
+		{"windows unknown host", "tracert", "Unable to resolve target system name nosuch.invalid.", CodeUnknownHost},
+		{"mtr unknown host", "mtr", "mtr: Failed to resolve host: nosuch.invalid: Name or service not known", CodeUnknownHost},
+		{"linux raw socket", "traceroute", "socket: Operation not permitted", CodePermissionDenied},
+		{"icmp needs root", "traceroute", "You do not have enough privileges to use this traceroute method.", CodePermissionDenied},
+		{"mtr permission", "mtr", "mtr-packet: Permission denied", CodePermissionDenied},
+		{"linux no network", "traceroute", "connect: Network is unreachable", CodeNetworkUnreachable},
+		{"macos no route", "traceroute", "traceroute: sendto: No route to host", CodeNetworkUnreachable},
+		{"windows no network", "tracert", "Transmit failed. General failure.", CodeNetworkUnreachable},
+		{"shell missing binary", "traceroute", "sh: traceroute: command not found", CodeBinaryNotFound},
+		{"unrecognised", "traceroute", "something odd happened", CodeToolFailed},
+		{"no output", "traceroute", "", CodeToolFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := toolFailure(tt.tool, exitErr, tt.output)
+			if err.Code != tt.expected {
+				t.Errorf("Code = %s, want %s", err.Code, tt.expected)
+			}
+			if err.Message == "" || err.Remediation == "" {
+				t.Errorf("missing message or remediation: %+v", err)
+			}
+			if !errors.Is(err, exitErr) {
+				t.Error("TraceError should wrap the exit error")
+			}
+		})
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorCode
+	}{
+		{"not installed", &exec.Error{Name: "traceroute", Err: exec.ErrNotFound}, CodeBinaryNotFound},
+		{"not executable", &os.PathError{Op: "fork/exec", Path: "/usr/sbin/traceroute", Err: os.ErrPermission}, CodePermissionDenied},
+		{"other", errors.New("too many open files"), CodeToolFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := startFailure("traceroute", tt.err).Code; got != tt.expected {
+				t.Errorf("Code = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		ok     bool
+	}{
+		{"example.com", true},
+		{"2001:db8::1", true},
+		{"", false},
+		{"-w", false},
+		{"--help", false},
+		{"8.8.8.8 -m 255", false},
+	}
+
+	for _, tt := range tests {
+		err := checkTarget(tt.target)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkTarget(%q) = %v, want ok %v", tt.target, err, tt.ok)
+		}
+		if err != nil && ErrorCodeOf(err) != CodeInvalidTarget {
+			t.Errorf("checkTarget(%q) code = %s", tt.target, ErrorCodeOf(err))
+		}
+	}
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorCode
+	}{
+		{"nil", nil, ""},
+		{"cancelled", context.Canceled, ""},
+		{"deadline", context.DeadlineExceeded, CodeDeadlineExceeded},
+		{"wrapped trace error", fmt.Errorf("run: %w", newTraceError(CodeUnknownHost, "", nil)), CodeUnknownHost},
+		{"plain", errors.New("failed to create stdout pipe"), CodeToolFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCodeOf(tt.err); got != tt.expected {
+				t.Errorf("ErrorCodeOf() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
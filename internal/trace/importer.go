@@ -0,0 +1,347 @@
+package trace
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportFormat identifies the tool that produced pasted trace output
+type ImportFormat string
+
+const (
+	ImportTraceroute ImportFormat = "traceroute" // Linux/BSD/macOS traceroute
+	ImportTracert    ImportFormat = "tracert"    // Windows tracert
+	ImportPathping   ImportFormat = "pathping"   // Windows pathping
+	ImportMtrReport  ImportFormat = "mtr-report" // mtr --report
+	ImportMtrJSON    ImportFormat = "mtr-json"   // mtr --json
+)
+
+var (
+	// tracertHopPattern matches a tracert row, which starts with RTT columns
+	tracertHopPattern = regexp.MustCompile(`^\s*\d+\s+(\*\s+)*<?\d+\s+ms\s`)
+
+	// mtrReportHopPattern matches the "  1.|-- " prefix of an mtr report row
+	mtrReportHopPattern = regexp.MustCompile(`^\s*\d+\.\s*\|--`)
+
+	// pathpingStatsPattern matches a pathping statistics row
+	// Example: "  2    5ms     0/ 100 =  0%     0/ 100 =  0%  10.0.0.1"
+	pathpingStatsPattern = regexp.MustCompile(
+		`^\s*(\d+)\s+(\d+|---)(?:ms)?\s+(\d+)/\s*(\d+)\s*=\s*(\d+)%\s+\d+/\s*\d+\s*=\s*\d+%\s+(.+?)\s*$`)
+
+	// unixHeaderPattern captures the target from a traceroute header
+	unixHeaderPattern = regexp.MustCompile(`^traceroute6?\s+to\s+(\S+)`)
+
+	// windowsHeaderPattern captures the target from a tracert or pathping header
+	windowsHeaderPattern = regexp.MustCompile(`^Tracing route to\s+(\S+)`)
+)
+
+// DetectImportFormat guesses which tool produced the given output
+func DetectImportFormat(text string) (ImportFormat, error) {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return "", fmt.Errorf("nothing to import")
+	}
+
+	if strings.HasPrefix(trimmed, "{") && strings.Contains(trimmed, `"hubs"`) {
+		return ImportMtrJSON, nil
+	}
+
+	lines := strings.Split(trimmed, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+
+	for _, line := range lines {
+		if mtrReportHopPattern.MatchString(line) {
+			return ImportMtrReport, nil
+		}
+	}
+
+	if strings.Contains(trimmed, "Computing statistics") || strings.Contains(trimmed, "Lost/Sent") {
+		return ImportPathping, nil
+	}
+
+	// Headers are the most reliable signal
+	for _, line := range lines {
+		if unixHeaderPattern.MatchString(line) {
+			return ImportTraceroute, nil
+		}
+		if windowsHeaderPattern.MatchString(line) {
+			return ImportTracert, nil
+		}
+	}
+
+	// Without a header, tracert rows put RTT columns before the address
+	for _, line := range lines {
+		if tracertHopPattern.MatchString(line) {
+			return ImportTracert, nil
+		}
+	}
+
+	// Any "N word" line parses as a timed-out hop, so at least one hop must
+	// have answered before unlabelled text is taken for traceroute
+	for _, line := range lines {
+		if hop := parseUnixHopLine(line, "", nil); hop != nil && (!hop.IsTimeout || len(hop.RTT) > 0) {
+			return ImportTraceroute, nil
+		}
+	}
+
+	return "", fmt.Errorf("unrecognized trace output")
+}
+
+// Import parses pasted traceroute, tracert, pathping or mtr output into a result
+// Hops are enriched with geolocation and datacenter details like a live trace
+func Import(text string, geoLookup GeoLookupFunc) (*Result, ImportFormat, error) {
+	format, err := DetectImportFormat(text)
+	if err != nil {
+		return nil, "", err
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var target string
+	var hops []*Hop
+
+	switch format {
+	case ImportMtrJSON:
+		target, hops, err = parseMtrJSON([]byte(strings.TrimSpace(text)), geoLookup)
+	case ImportMtrReport:
+		hops = importMtrReport(text, geoLookup)
+	case ImportPathping:
+		target, hops = importPathping(text, geoLookup)
+	case ImportTracert:
+		target, hops = importTracert(text, geoLookup)
+	default:
+		target, hops = importTraceroute(text, geoLookup)
+	}
+	if err != nil {
+		return nil, format, err
+	}
+	if len(hops) == 0 {
+		return nil, format, fmt.Errorf("no hops found in %s output", format)
+	}
+
+	if target == "" {
+		target = lastResponder(hops)
+	}
+
+	now := time.Now().UnixMilli()
+	return &Result{
+		SessionID: uuid.New().String(),
+		Target:    target,
+		Options:   importedOptions(hops),
+		Hops:      hops,
+		StartedAt: now,
+		EndedAt:   now,
+		Outcome:   OutcomeCompleted,
+	}, format, nil
+}
+
+// importTraceroute parses Linux/BSD/macOS traceroute output
+func importTraceroute(text string, geoLookup GeoLookupFunc) (string, []*Hop) {
+	var target, destinationIP string
+	var hops []*Hop
+
+	for _, line := range strings.Split(text, "\n") {
+		if matches := unixHeaderPattern.FindStringSubmatch(line); matches != nil {
+			target = matches[1]
+			destinationIP = parseDestinationIP(line)
+			continue
+		}
+		if hop := parseUnixHopLine(line, destinationIP, geoLookup); hop != nil {
+			hops = append(hops, hop)
+		}
+	}
+	return target, hops
+}
+
+// importTracert parses Windows tracert output
+func importTracert(text string, geoLookup GeoLookupFunc) (string, []*Hop) {
+	var target, destinationIP string
+	var hops []*Hop
+
+	for _, line := range strings.Split(text, "\n") {
+		if matches := windowsHeaderPattern.FindStringSubmatch(line); matches != nil {
+			target = matches[1]
+			destinationIP = parseWindowsDestinationIP(line)
+			continue
+		}
+		if hop := parseWindowsHopLine(line, destinationIP, geoLookup); hop != nil {
+			hops = append(hops, hop)
+		}
+	}
+
+	// tracert without a header still ends at the destination
+	if destinationIP == "" {
+		markMtrDestination(hops, "")
+	}
+	return target, hops
+}
+
+// importMtrReport parses `mtr --report` output
+func importMtrReport(text string, geoLookup GeoLookupFunc) []*Hop {
+	var hops []*Hop
+	for _, line := range strings.Split(text, "\n") {
+		if hop := parseMtrReportLine(line, geoLookup); hop != nil {
+			hops = append(hops, hop)
+		}
+	}
+	markMtrDestination(hops, "")
+	return hops
+}
+
+// importPathping parses Windows pathping output
+// The statistics section is preferred; the initial route listing is used
+// when the paste was cut short before statistics were computed
+func importPathping(text string, geoLookup GeoLookupFunc) (string, []*Hop) {
+	var target, destinationIP string
+	var listed, measured []*Hop
+	inStats := false
+
+	for _, line := range strings.Split(text, "\n") {
+		if matches := windowsHeaderPattern.FindStringSubmatch(line); matches != nil {
+			target = matches[1]
+			destinationIP = parseWindowsDestinationIP(line)
+			continue
+		}
+		if strings.Contains(line, "Computing statistics") {
+			inStats = true
+			continue
+		}
+
+		if inStats {
+			if hop := parsePathpingStatsLine(line, destinationIP, geoLookup); hop != nil {
+				measured = append(measured, hop)
+			}
+			continue
+		}
+
+		if hop := parsePathpingRouteLine(line, destinationIP, geoLookup); hop != nil {
+			listed = append(listed, hop)
+		}
+	}
+
+	hops := measured
+	if len(hops) == 0 {
+		hops = listed
+	}
+	return target, hops
+}
+
+// parsePathpingRouteLine parses a row of pathping's initial route listing
+// Examples:
+//
+//	"  1  192.168.1.1"
+//	"  2  router.example.com [10.0.0.1]"
+//	"  3     *        *        *"
+func parsePathpingRouteLine(line, destinationIP string, geoLookup GeoLookupFunc) *Hop {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil
+	}
+	hopNum, err := strconv.Atoi(fields[0])
+	if err != nil || hopNum == 0 {
+		// Hop 0 is the local machine
+		return nil
+	}
+	return pathpingHop(hopNum, strings.Join(fields[1:], " "), nil, destinationIP, geoLookup)
+}
+
+// parsePathpingStatsLine parses a row of pathping's statistics section
+func parsePathpingStatsLine(line, destinationIP string, geoLookup GeoLookupFunc) *Hop {
+	matches := pathpingStatsPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return nil
+	}
+	hopNum, _ := strconv.Atoi(matches[1])
+	if hopNum == 0 {
+		return nil
+	}
+
+	lost, _ := strconv.Atoi(matches[3])
+	sent, _ := strconv.Atoi(matches[4])
+	loss, _ := strconv.ParseFloat(matches[5], 64)
+
+	stats := &ProbeStats{
+		Sent:        sent,
+		Received:    sent - lost,
+		LossPercent: loss,
+	}
+	if rtt, err := strconv.ParseFloat(matches[2], 64); err == nil {
+		stats.Last, stats.Avg, stats.Best, stats.Worst = rtt, rtt, rtt, rtt
+	}
+
+	return pathpingHop(hopNum, matches[6], stats, destinationIP, geoLookup)
+}
+
+// pathpingHop creates a hop from a pathping address column
+func pathpingHop(hopNum int, address string, stats *ProbeStats, destinationIP string, geoLookup GeoLookupFunc) *Hop {
+	fields := strings.Fields(address)
+	var ip, hostname string
+	for _, field := range fields {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			ip = strings.Trim(field, "[]")
+		} else if hostname == "" && field != "*" {
+			hostname = field
+		}
+	}
+	if ip == "" {
+		ip, hostname = hostname, ""
+	}
+
+	if ip == "" || (stats != nil && stats.Received == 0 && stats.Avg == 0) {
+		return &Hop{
+			HopNumber: hopNum,
+			IPAddress: "*",
+			IsTimeout: true,
+			Timestamp: time.Now().UnixMilli(),
+			Stats:     stats,
+		}
+	}
+
+	hop := &Hop{
+		HopNumber:     hopNum,
+		IPAddress:     ip,
+		Hostname:      hostname,
+		IsDestination: ip == destinationIP,
+		Timestamp:     time.Now().UnixMilli(),
+		Stats:         stats,
+	}
+	if stats != nil && stats.Received > 0 {
+		hop.RTT = []float64{stats.Avg}
+		hop.AvgRTT = stats.Avg
+	}
+	enrichHop(hop, geoLookup)
+	return hop
+}
+
+// lastResponder returns the address of the last hop that answered
+func lastResponder(hops []*Hop) string {
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !hops[i].IsTimeout {
+			return hops[i].IPAddress
+		}
+	}
+	return ""
+}
+
+// importedOptions infers the options used to produce imported output
+func importedOptions(hops []*Hop) Options {
+	opts := DefaultOptions()
+	opts.MaxHops = len(hops)
+	for _, hop := range hops {
+		probes := len(hop.RTT)
+		if hop.Stats != nil {
+			probes = hop.Stats.Sent
+		}
+		if probes > opts.ProbesPerHop {
+			opts.ProbesPerHop = probes
+		}
+	}
+	return opts
+}
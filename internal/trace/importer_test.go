@@ -0,0 +1,180 @@
+package trace
+
+import (
+	"testing"
+)
+
+const linuxTracerouteOutput = `traceroute to google.com (142.250.80.46), 30 hops max, 60 byte packets
+ 1  _gateway (192.168.1.1)  0.456 ms  0.402 ms  0.398 ms
+ 2  10.0.0.1 (10.0.0.1)  5.234 ms  5.100 ms  5.321 ms
+ 3  * * *
+ 4  lga34s34-in-f14.1e100.net (142.250.80.46)  15.678 ms  15.512 ms  15.601 ms`
+
+const windowsTracertOutput = "\r\nTracing route to google.com [142.250.80.46]\r\n" +
+	"over a maximum of 30 hops:\r\n\r\n" +
+	"  1    <1 ms    <1 ms    <1 ms  192.168.1.1\r\n" +
+	"  2     5 ms     4 ms     5 ms  10.0.0.1\r\n" +
+	"  3     *        *        *     Request timed out.\r\n" +
+	"  4    15 ms    14 ms    16 ms  lga34s34-in-f14.1e100.net [142.250.80.46]\r\n\r\n" +
+	"Trace complete.\r\n"
+
+const pathpingOutput = `
+Tracing route to google.com [142.250.80.46]
+over a maximum of 30 hops:
+  0  DESKTOP-1234 [192.168.1.10]
+  1  192.168.1.1
+  2  10.0.0.1
+  3  142.250.80.46
+
+Computing statistics for 75 seconds...
+            Source to Here   This Node/Link
+Hop  RTT    Lost/Sent = Pct  Lost/Sent = Pct  Address
+  0                                           DESKTOP-1234 [192.168.1.10]
+                                0/ 100 =  0%   |
+  1    1ms     0/ 100 =  0%     0/ 100 =  0%  192.168.1.1
+                                0/ 100 =  0%   |
+  2    5ms     2/ 100 =  2%     2/ 100 =  2%  10.0.0.1
+                                0/ 100 =  0%   |
+  3   15ms     0/ 100 =  0%     0/ 100 =  0%  142.250.80.46
+
+Trace complete.`
+
+const mtrReportOutput = `Start: 2024-01-02T15:04:05+0000
+HOST: laptop                      Loss%   Snt   Last   Avg  Best  Wrst StDev
+  1.|-- 192.168.1.1                0.0%    10    0.5   0.6   0.4   0.8   0.1
+  2.|-- 10.0.0.1                   0.0%    10    5.2   5.3   5.0   5.9   0.3
+  3.|-- ???                       100.0    10    0.0   0.0   0.0   0.0   0.0
+  4.|-- 142.250.80.46             10.0%    10   15.6  15.8  15.2  16.9   0.5
+    |  ` + "`" + `|-- 142.250.80.47`
+
+const mtrJSONOutput = `{
+  "report": {
+    "mtr": {"src": "laptop", "dst": "google.com", "tos": 0, "tests": 10, "psize": "64", "bitpattern": "0x00"},
+    "hubs": [
+      {"count": 1, "host": "192.168.1.1", "Loss%": 0.0, "Snt": 10, "Last": 0.5, "Avg": 0.6, "Best": 0.4, "Wrst": 0.8, "StDev": 0.1},
+      {"count": "2", "host": "???", "Loss%": 100.0, "Snt": 10, "Last": 0.0, "Avg": 0.0, "Best": 0.0, "Wrst": 0.0, "StDev": 0.0},
+      {"count": 3, "host": "142.250.80.46", "Loss%": 0.0, "Snt": 10, "Last": 15.6, "Avg": 15.8, "Best": 15.2, "Wrst": 16.9, "StDev": 0.5}
+    ]
+  }
+}`
+
+func TestDetectImportFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ImportFormat
+	}{
+		{"linux traceroute", linuxTracerouteOutput, ImportTraceroute},
+		{"traceroute without header", " 1  192.168.1.1  0.456 ms\n 2  * * *", ImportTraceroute},
+		{"windows tracert", windowsTracertOutput, ImportTracert},
+		{"tracert without header", "  1    <1 ms    <1 ms    <1 ms  192.168.1.1", ImportTracert},
+		{"pathping", pathpingOutput, ImportPathping},
+		{"mtr report", mtrReportOutput, ImportMtrReport},
+		{"mtr json", mtrJSONOutput, ImportMtrJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := DetectImportFormat(tt.input)
+			if err != nil {
+				t.Fatalf("DetectImportFormat() error = %v", err)
+			}
+			if format != tt.expected {
+				t.Errorf("DetectImportFormat() = %s, want %s", format, tt.expected)
+			}
+		})
+	}
+
+	for _, garbage := range []string{"hello world", "1 hello\n2 world", " 1  * * *"} {
+		if _, err := DetectImportFormat(garbage); err == nil {
+			t.Errorf("DetectImportFormat(%q) expected error", garbage)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedTarget string
+		expectedIPs    []string
+		withStats      bool
+	}{
+		{
+			name:           "linux traceroute",
+			input:          linuxTracerouteOutput,
+			expectedTarget: "google.com",
+			expectedIPs:    []string{"192.168.1.1", "10.0.0.1", "*", "142.250.80.46"},
+		},
+		{
+			name:           "windows tracert",
+			input:          windowsTracertOutput,
+			expectedTarget: "google.com",
+			expectedIPs:    []string{"192.168.1.1", "10.0.0.1", "*", "142.250.80.46"},
+		},
+		{
+			name:           "pathping",
+			input:          pathpingOutput,
+			expectedTarget: "google.com",
+			expectedIPs:    []string{"192.168.1.1", "10.0.0.1", "142.250.80.46"},
+			withStats:      true,
+		},
+		{
+			name:           "mtr report",
+			input:          mtrReportOutput,
+			expectedTarget: "142.250.80.46",
+			expectedIPs:    []string{"192.168.1.1", "10.0.0.1", "*", "142.250.80.46"},
+			withStats:      true,
+		},
+		{
+			name:           "mtr json",
+			input:          mtrJSONOutput,
+			expectedTarget: "google.com",
+			expectedIPs:    []string{"192.168.1.1", "*", "142.250.80.46"},
+			withStats:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, err := Import(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if result.Target != tt.expectedTarget {
+				t.Errorf("Target = %s, want %s", result.Target, tt.expectedTarget)
+			}
+			if len(result.Hops) != len(tt.expectedIPs) {
+				t.Fatalf("got %d hops, want %d", len(result.Hops), len(tt.expectedIPs))
+			}
+			for i, hop := range result.Hops {
+				if hop.IPAddress != tt.expectedIPs[i] {
+					t.Errorf("hop %d IPAddress = %s, want %s", i, hop.IPAddress, tt.expectedIPs[i])
+				}
+				if tt.withStats && hop.Stats == nil {
+					t.Errorf("hop %d has no probe stats", i)
+				}
+			}
+			if !result.Reached() {
+				t.Error("Reached() = false, want true")
+			}
+			if result.Outcome != OutcomeCompleted {
+				t.Errorf("Outcome = %s, want %s", result.Outcome, OutcomeCompleted)
+			}
+		})
+	}
+}
+
+func TestParseMtrReportLineStats(t *testing.T) {
+	hop := parseMtrReportLine("  4.|-- 142.250.80.46             10.0%    10   15.6  15.8  15.2  16.9   0.5", nil)
+	if hop == nil {
+		t.Fatal("parseMtrReportLine() = nil")
+	}
+	expected := ProbeStats{Sent: 10, Received: 9, LossPercent: 10, Last: 15.6, Avg: 15.8, Best: 15.2, Worst: 16.9, StdDev: 0.5}
+	if *hop.Stats != expected {
+		t.Errorf("Stats = %+v, want %+v", *hop.Stats, expected)
+	}
+	if hop.AvgRTT != 15.8 {
+		t.Errorf("AvgRTT = %f, want 15.8", hop.AvgRTT)
+	}
+}
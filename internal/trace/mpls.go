@@ -0,0 +1,147 @@
+package trace
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// MPLSLabel is one entry of the label stack a router quoted in its ICMP reply (RFC 4950)
+type MPLSLabel struct {
+	Label         int  `json:"label"`
+	TrafficClass  int  `json:"trafficClass"` // The EXP bits
+	BottomOfStack bool `json:"bottomOfStack"`
+	TTL           int  `json:"ttl"`
+}
+
+// parseMPLSExtension decodes a label stack printed by `traceroute -e`
+// Labels are separated by '/', top of the stack first
+// Example: "<MPLS:L=24001,E=0,S=0,T=1/L=300,E=0,S=1,T=1>"
+func parseMPLSExtension(field string) ([]MPLSLabel, bool) {
+	if !strings.HasPrefix(field, "<MPLS:") || !strings.HasSuffix(field, ">") {
+		return nil, false
+	}
+	var stack []MPLSLabel
+	for _, entry := range strings.Split(field[len("<MPLS:"):len(field)-1], "/") {
+		var label MPLSLabel
+		var sawLabel bool
+		for _, pair := range strings.Split(entry, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			n, err := strconv.Atoi(value)
+			if !ok || err != nil {
+				return nil, false
+			}
+			switch key {
+			case "L":
+				label.Label = n
+				sawLabel = true
+			case "E":
+				label.TrafficClass = n
+			case "S":
+				label.BottomOfStack = n == 1
+			case "T":
+				label.TTL = n
+			}
+		}
+		if !sawLabel {
+			return nil, false
+		}
+		stack = append(stack, label)
+	}
+	return stack, len(stack) > 0
+}
+
+// TunnelKind is how an MPLS tunnel was detected
+type TunnelKind string
+
+const (
+	TunnelVisible TunnelKind = "visible" // Routers quoted their label stacks
+	TunnelHidden  TunnelKind = "hidden"  // No labels, inferred from a run of hops with near-identical RTTs
+)
+
+// MPLSTunnel is a run of hops believed to be inside one MPLS tunnel
+type MPLSTunnel struct {
+	Kind     TunnelKind `json:"kind"`
+	FirstHop int        `json:"firstHop"`
+	LastHop  int        `json:"lastHop"`
+	Labels   []int      `json:"labels,omitempty"` // Top label at each labelled hop, for visible tunnels
+}
+
+const (
+	// minHiddenTunnelHops is the shortest run of matching RTTs taken as a tunnel
+	minHiddenTunnelHops = 3
+	// hiddenTunnelSpreadMs and hiddenTunnelSpreadRatio bound how far RTTs in a
+	// hidden tunnel may differ. Routers inside a tunnel send their replies on to
+	// its far end before they come back, so every hop sees about the same RTT
+	hiddenTunnelSpreadMs    = 0.5
+	hiddenTunnelSpreadRatio = 0.02
+)
+
+// DetectMPLSTunnels groups hops into MPLS tunnels
+// Consecutive labelled hops form a visible tunnel; silent hops between them
+// do not end it. Runs of public hops without labels whose RTTs barely differ
+// form hidden tunnels. Hop numbers follow the trace's, in order
+func DetectMPLSTunnels(hops []*Hop) []MPLSTunnel {
+	var tunnels []MPLSTunnel
+	var visible *MPLSTunnel
+	var run []*Hop
+
+	endHidden := func() {
+		if len(run) >= minHiddenTunnelHops {
+			tunnels = append(tunnels, MPLSTunnel{Kind: TunnelHidden, FirstHop: run[0].HopNumber, LastHop: run[len(run)-1].HopNumber})
+		}
+		run = nil
+	}
+	endVisible := func() {
+		if visible != nil {
+			tunnels = append(tunnels, *visible)
+			visible = nil
+		}
+	}
+
+	for _, hop := range hops {
+		switch {
+		case hop.IsTimeout:
+			// A silent hop ends a run of matching RTTs, but may sit inside a labelled tunnel
+			endHidden()
+		case len(hop.MPLS) > 0:
+			endHidden()
+			if visible == nil {
+				visible = &MPLSTunnel{Kind: TunnelVisible, FirstHop: hop.HopNumber}
+			}
+			visible.LastHop = hop.HopNumber
+			visible.Labels = append(visible.Labels, hop.MPLS[0].Label)
+		default:
+			endVisible()
+			if !publicHop(hop) || hop.AvgRTT <= 0 {
+				endHidden()
+				continue
+			}
+			if len(run) > 0 && !similarRTT(run, hop.AvgRTT) {
+				endHidden()
+			}
+			run = append(run, hop)
+		}
+	}
+	endVisible()
+	endHidden()
+	return tunnels
+}
+
+// similarRTT reports whether rtt stays within the spread allowed across a run of hops
+func similarRTT(run []*Hop, rtt float64) bool {
+	low, high := rtt, rtt
+	for _, hop := range run {
+		low = math.Min(low, hop.AvgRTT)
+		high = math.Max(high, hop.AvgRTT)
+	}
+	return high-low <= hiddenTunnelSpreadMs+hiddenTunnelSpreadRatio*low
+}
+
+// publicHop reports whether a hop answered from a globally routable address
+// Home and office networks answer quickly and evenly without any tunnel
+func publicHop(hop *Hop) bool {
+	ip := net.ParseIP(hop.IPAddress)
+	return ip != nil && ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
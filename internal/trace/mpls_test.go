@@ -0,0 +1,114 @@
+package trace
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tracerouteMPLSCapture is `traceroute -n -e 8.8.8.8` output crossing a
+// carrier backbone whose routers quote their label stacks at hops 3 to 5
+const tracerouteMPLSCapture = `traceroute to 8.8.8.8 (8.8.8.8), 30 hops max, 60 byte packets
+ 1  192.168.1.1  0.512 ms  0.498 ms  0.501 ms
+ 2  100.64.0.1  8.120 ms  8.301 ms  8.244 ms
+ 3  62.115.42.1  21.402 ms <MPLS:L=24001,E=0,S=0,T=1/L=300,E=0,S=1,T=1>  21.388 ms  21.417 ms
+ 4  62.115.42.9  21.511 ms <MPLS:L=24017,E=0,S=1,T=1>  21.490 ms  21.502 ms
+ 5  62.115.43.2  21.620 ms <MPLS:L=17,E=0,S=1,T=1>  21.634 ms  21.601 ms
+ 6  8.8.8.8  22.015 ms  21.998 ms  22.043 ms`
+
+func TestParseMPLSExtension(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []MPLSLabel
+	}{
+		{"<MPLS:L=24001,E=0,S=1,T=1>", []MPLSLabel{{Label: 24001, BottomOfStack: true, TTL: 1}}},
+		{"<MPLS:L=16,E=5,S=0,T=254/L=300,E=0,S=1,T=1>", []MPLSLabel{{Label: 16, TrafficClass: 5, TTL: 254}, {Label: 300, BottomOfStack: true, TTL: 1}}},
+		{"<MPLS:E=0,S=1,T=1>", nil},
+		{"<MPLS:L=x,E=0,S=1,T=1>", nil},
+		{"<INC:ifIndex=3>", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := parseMPLSExtension(tt.input)
+			if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMPLSExtension(%q) = %+v, %v; want %+v", tt.input, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnixHopLineMPLS(t *testing.T) {
+	var hops []*Hop
+	for _, line := range strings.Split(tracerouteMPLSCapture, "\n")[1:] {
+		hop := parseUnixHopLine(line, "8.8.8.8", nil)
+		if hop == nil {
+			t.Fatalf("%q not parsed", line)
+		}
+		hops = append(hops, hop)
+	}
+
+	if hop := hops[2]; hop.IPAddress != "62.115.42.1" || len(hop.RTT) != 3 || len(hop.MPLS) != 2 || hop.MPLS[0].Label != 24001 {
+		t.Errorf("hop 3 = %+v", hop)
+	}
+	if hops[0].MPLS != nil || hops[5].MPLS != nil {
+		t.Error("hops without extensions carry labels")
+	}
+	if !hops[5].IsDestination {
+		t.Error("hop 6 is not the destination")
+	}
+}
+
+func TestDetectMPLSTunnels(t *testing.T) {
+	labelled := func(n int, label int) *Hop {
+		return &Hop{HopNumber: n, IPAddress: "62.115.42.1", AvgRTT: 20, MPLS: []MPLSLabel{{Label: label, BottomOfStack: true, TTL: 1}}}
+	}
+	plain := func(n int, ip string, rtt float64) *Hop {
+		return &Hop{HopNumber: n, IPAddress: ip, AvgRTT: rtt}
+	}
+	silent := func(n int) *Hop {
+		return &Hop{HopNumber: n, IPAddress: "*", IsTimeout: true}
+	}
+
+	tests := []struct {
+		name string
+		hops []*Hop
+		want []MPLSTunnel
+	}{
+		{
+			name: "visible tunnel",
+			hops: []*Hop{plain(1, "192.168.1.1", 0.5), labelled(2, 100), labelled(3, 200), plain(4, "8.8.8.8", 30)},
+			want: []MPLSTunnel{{Kind: TunnelVisible, FirstHop: 2, LastHop: 3, Labels: []int{100, 200}}},
+		},
+		{
+			name: "silent hop inside a visible tunnel",
+			hops: []*Hop{labelled(1, 100), silent(2), labelled(3, 200)},
+			want: []MPLSTunnel{{Kind: TunnelVisible, FirstHop: 1, LastHop: 3, Labels: []int{100, 200}}},
+		},
+		{
+			name: "hidden tunnel",
+			hops: []*Hop{plain(1, "192.168.1.1", 0.5), plain(2, "80.81.192.1", 9), plain(3, "80.81.192.5", 41.2), plain(4, "80.81.192.9", 41.4), plain(5, "80.81.192.13", 41.1), plain(6, "8.8.8.8", 48)},
+			want: []MPLSTunnel{{Kind: TunnelHidden, FirstHop: 3, LastHop: 5}},
+		},
+		{
+			name: "even RTTs on the local network",
+			hops: []*Hop{plain(1, "192.168.1.1", 0.5), plain(2, "10.0.0.1", 0.6), plain(3, "10.0.1.1", 0.7)},
+		},
+		{
+			name: "too short to be hidden",
+			hops: []*Hop{plain(1, "80.81.192.1", 41.2), plain(2, "80.81.192.5", 41.3), silent(3), plain(4, "80.81.192.9", 41.2)},
+		},
+		{
+			name: "steadily rising RTTs",
+			hops: []*Hop{plain(1, "80.81.192.1", 10), plain(2, "80.81.192.5", 14), plain(3, "80.81.192.9", 19), plain(4, "80.81.192.13", 25)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectMPLSTunnels(tt.hops); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectMPLSTunnels() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
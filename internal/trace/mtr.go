@@ -0,0 +1,370 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mtrJSON mirrors the document printed by `mtr --json`
+type mtrJSON struct {
+	Report struct {
+		Mtr struct {
+			Src   string  `json:"src"`
+			Dst   string  `json:"dst"`
+			Tests flexInt `json:"tests"`
+		} `json:"mtr"`
+		Hubs []mtrHub `json:"hubs"`
+	} `json:"report"`
+}
+
+// mtrHub is a single hop in `mtr --json` output
+type mtrHub struct {
+	Count flexInt `json:"count"`
+	Host  string  `json:"host"`
+	ASN   string  `json:"ASN"`
+	Loss  float64 `json:"Loss%"`
+	Snt   flexInt `json:"Snt"`
+	Last  float64 `json:"Last"`
+	Avg   float64 `json:"Avg"`
+	Best  float64 `json:"Best"`
+	Wrst  float64 `json:"Wrst"`
+	StDev float64 `json:"StDev"`
+}
+
+// flexInt accepts both numbers and numeric strings
+// Older mtr versions print "count": "1" instead of "count": 1
+type flexInt int
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *flexInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*f = 0
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", data)
+	}
+	*f = flexInt(n)
+	return nil
+}
+
+// parseMtrJSON parses `mtr --json` output into hops
+// Returns the destination named in the report along with the hops
+func parseMtrJSON(data []byte, geoLookup GeoLookupFunc) (string, []*Hop, error) {
+	var doc mtrJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", nil, fmt.Errorf("invalid mtr JSON: %w", err)
+	}
+	if len(doc.Report.Hubs) == 0 {
+		return "", nil, fmt.Errorf("mtr JSON contains no hops")
+	}
+
+	var hops []*Hop
+	for i, hub := range doc.Report.Hubs {
+		hopNum := int(hub.Count)
+		if hopNum == 0 {
+			hopNum = i + 1
+		}
+		stats := mtrStats(int(hub.Snt), hub.Loss, hub.Last, hub.Avg, hub.Best, hub.Wrst, hub.StDev)
+		hops = append(hops, newMtrHop(hopNum, hub.Host, "", stats, geoLookup))
+	}
+
+	markMtrDestination(hops, doc.Report.Mtr.Dst)
+	return doc.Report.Mtr.Dst, hops, nil
+}
+
+// mtrReportLinePattern matches a hop row of `mtr --report`
+// Example: "  3.|-- AS15169  142.250.80.46   0.0%    10   15.6  15.8  15.2  16.9   0.5"
+var mtrReportLinePattern = regexp.MustCompile(
+	`^\s*(\d+)\.\s*\|[-` + "`" + `]+\s+(.*?)\s+([\d.]+)%?\s+(\d+)\s+([\d.]+)\s+([\d.]+)\s+([\d.]+)\s+([\d.]+)\s+([\d.]+)\s*$`)
+
+// parseMtrReportLine parses a single hop row of `mtr --report` output
+// Returns nil for headers and multipath continuation lines
+func parseMtrReportLine(line string, geoLookup GeoLookupFunc) *Hop {
+	matches := mtrReportLinePattern.FindStringSubmatch(line)
+	if matches == nil {
+		return nil
+	}
+
+	hopNum, _ := strconv.Atoi(matches[1])
+	values := make([]float64, 7)
+	for i := range values {
+		values[i], _ = strconv.ParseFloat(matches[i+3], 64)
+	}
+
+	// The host column may carry an AS tag and a hostname with its address
+	var host, ip string
+	for _, field := range strings.Fields(matches[2]) {
+		switch {
+		case strings.HasPrefix(field, "AS"):
+			continue
+		case isParenthesizedIP(field):
+			ip = strings.Trim(field, "()")
+		case host == "":
+			host = field
+		}
+	}
+	hostname := ""
+	if ip != "" {
+		hostname = host
+		host = ip
+	}
+
+	stats := mtrStats(int(values[1]), values[0], values[2], values[3], values[4], values[5], values[6])
+	return newMtrHop(hopNum, host, hostname, stats, geoLookup)
+}
+
+// mtrStats builds probe statistics from mtr's columns
+func mtrStats(sent int, loss, last, avg, best, worst, stdDev float64) *ProbeStats {
+	received := int(math.Round(float64(sent) * (100 - loss) / 100))
+	return &ProbeStats{
+		Sent:        sent,
+		Received:    received,
+		LossPercent: loss,
+		Last:        last,
+		Avg:         avg,
+		Best:        best,
+		Worst:       worst,
+		StdDev:      stdDev,
+	}
+}
+
+// newMtrHop creates a hop from an mtr host entry and its statistics
+// mtr prints "???" for hops that never answered
+func newMtrHop(hopNum int, host, hostname string, stats *ProbeStats, geoLookup GeoLookupFunc) *Hop {
+	if host == "" || host == "???" || stats.Received == 0 {
+		return &Hop{
+			HopNumber: hopNum,
+			IPAddress: "*",
+			IsTimeout: true,
+			Timestamp: time.Now().UnixMilli(),
+			Stats:     stats,
+		}
+	}
+
+	// Without -n, mtr prints hostnames instead of addresses
+	ipAddress := host
+	if hostname == "" && net.ParseIP(host) == nil {
+		hostname = host
+	}
+
+	hop := &Hop{
+		HopNumber: hopNum,
+		IPAddress: ipAddress,
+		Hostname:  hostname,
+		RTT:       []float64{stats.Last},
+		AvgRTT:    stats.Avg,
+		Timestamp: time.Now().UnixMilli(),
+		Stats:     stats,
+	}
+	enrichHop(hop, geoLookup)
+	return hop
+}
+
+// markMtrDestination flags the destination hop
+// mtr stops probing at the destination, so the final hop is it if it answered
+func markMtrDestination(hops []*Hop, destination string) {
+	if len(hops) == 0 {
+		return
+	}
+	for _, hop := range hops {
+		if destination != "" && (hop.IPAddress == destination || hop.Hostname == destination) {
+			hop.IsDestination = true
+			return
+		}
+	}
+	if last := hops[len(hops)-1]; !last.IsTimeout {
+		last.IsDestination = true
+	}
+}
+
+// mtrRawHop collects the `mtr --raw` records of one hop
+type mtrRawHop struct {
+	ip       string
+	hostname string
+	sent     int
+	rtts     []float64
+}
+
+// mtrRawParser turns `mtr --raw` records into hops as they settle
+// mtr probes every hop once per cycle, so a hop has settled once it has
+// answered every probe or a hop further out has. It is only emitted once a
+// later hop answers from another address, which rules it out as the
+// destination; the rest are emitted by finish when mtr exits
+type mtrRawParser struct {
+	probes  int // Cycles mtr was asked to run
+	hops    []*mtrRawHop
+	sawSent bool // mtr printed transmit records, so sent counts are known
+	emitted int
+}
+
+// newMtrRawParser returns a parser for mtr run with probes cycles
+func newMtrRawParser(probes int) *mtrRawParser {
+	return &mtrRawParser{probes: probes}
+}
+
+// parseLine applies one raw record and reports whether it was one
+// Records are "x <pos> <seq>" (sent), "h <pos> <ip>", "d <pos> <name>" and
+// "p <pos> <usec> [seq]" (reply), where pos is the TTL less one
+func (p *mtrRawParser) parseLine(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return false
+	}
+	pos, err := strconv.Atoi(fields[1])
+	if err != nil || pos < 0 || pos > 255 {
+		return false
+	}
+
+	switch fields[0] {
+	case "x":
+		p.at(pos).sent++
+		p.sawSent = true
+	case "h":
+		// Keep the first router that answered; load-balanced paths report several
+		if len(fields) > 2 && p.at(pos).ip == "" {
+			p.at(pos).ip = fields[2]
+		}
+	case "d":
+		if len(fields) > 2 {
+			p.at(pos).hostname = fields[2]
+		}
+	case "p":
+		if len(fields) < 3 {
+			return false
+		}
+		usec, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return false
+		}
+		hop := p.at(pos)
+		hop.rtts = append(hop.rtts, usec/1000)
+	default:
+		return false
+	}
+	return true
+}
+
+// at returns the hop at pos, adding any hops up to it
+func (p *mtrRawParser) at(pos int) *mtrRawHop {
+	for len(p.hops) <= pos {
+		p.hops = append(p.hops, &mtrRawHop{})
+	}
+	return p.hops[pos]
+}
+
+// settled returns the hops that have settled since the last call, in order
+func (p *mtrRawParser) settled(geoLookup GeoLookupFunc) []*Hop {
+	var hops []*Hop
+	for p.emitted < len(p.hops) && p.isSettled(p.emitted) {
+		hops = append(hops, p.hop(p.emitted, geoLookup))
+		p.emitted++
+	}
+	return hops
+}
+
+// isSettled reports whether the hop at pos can be emitted before mtr exits
+func (p *mtrRawParser) isSettled(pos int) bool {
+	hop := p.hops[pos]
+	complete := len(hop.rtts) >= p.probes
+	beyond := false
+	for _, later := range p.hops[pos+1:] {
+		if len(later.rtts) >= p.probes {
+			complete = true
+		}
+		if later.ip != "" && later.ip != hop.ip {
+			beyond = true
+		}
+	}
+	return complete && beyond
+}
+
+// finish returns the hops not yet emitted once mtr has exited, ending at
+// the destination, which is flagged if it was reached. target is the
+// address or hostname mtr traced; mtr stops early once it reaches it
+func (p *mtrRawParser) finish(target string, maxHops int, geoLookup GeoLookupFunc) []*Hop {
+	end := len(p.hops)
+	// The destination answers every TTL from its own onwards; keep the first
+	lastAnswered := -1
+	for i := len(p.hops) - 1; i >= 0 && lastAnswered < 0; i-- {
+		if p.hops[i].ip != "" {
+			lastAnswered = i
+		}
+	}
+	repeated := false
+	if lastAnswered >= 0 {
+		for i, hop := range p.hops {
+			if hop.ip == p.hops[lastAnswered].ip {
+				if repeated = i < lastAnswered; repeated {
+					end = i + 1
+				}
+				break
+			}
+		}
+	}
+	if end < p.emitted {
+		end = p.emitted
+	}
+
+	var hops []*Hop
+	for ; p.emitted < end; p.emitted++ {
+		hops = append(hops, p.hop(p.emitted, geoLookup))
+	}
+
+	if n := len(hops); n > 0 {
+		final := hops[n-1]
+		ip := p.hops[end-1].ip
+		if !final.IsTimeout && (ip == target || final.Hostname == target || repeated || end < maxHops) {
+			final.IsDestination = true
+		}
+	}
+	return hops
+}
+
+// hop builds the hop at pos with statistics over its replies
+func (p *mtrRawParser) hop(pos int, geoLookup GeoLookupFunc) *Hop {
+	raw := p.hops[pos]
+	received := len(raw.rtts)
+	sent := raw.sent
+	if !p.sawSent {
+		sent = p.probes
+	}
+	if sent < received {
+		sent = received
+	}
+
+	stats := &ProbeStats{Sent: sent, Received: received}
+	if sent > 0 {
+		stats.LossPercent = float64(sent-received) * 100 / float64(sent)
+	}
+	if received > 0 {
+		stats.Last = raw.rtts[received-1]
+		stats.Best, stats.Worst = raw.rtts[0], raw.rtts[0]
+		var sum float64
+		for _, rtt := range raw.rtts {
+			sum += rtt
+			stats.Best = math.Min(stats.Best, rtt)
+			stats.Worst = math.Max(stats.Worst, rtt)
+		}
+		stats.Avg = sum / float64(received)
+		var squares float64
+		for _, rtt := range raw.rtts {
+			squares += (rtt - stats.Avg) * (rtt - stats.Avg)
+		}
+		stats.StdDev = math.Sqrt(squares / float64(received))
+	}
+
+	hop := newMtrHop(pos+1, raw.ip, raw.hostname, stats, geoLookup)
+	if !hop.IsTimeout {
+		hop.RTT = append([]float64(nil), raw.rtts...)
+	}
+	return hop
+}
@@ -0,0 +1,180 @@
+package trace
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// mtrRawCapture is `mtr -l -n -c 2 -m 30 8.8.8.8` output: hop 3 misses its
+// first probe and the destination answers both TTL 4 and TTL 5
+const mtrRawCapture = `x 0 33000
+x 1 33001
+x 2 33002
+x 3 33003
+x 4 33004
+h 0 192.168.1.1
+p 0 512 33000
+h 1 10.0.0.1
+p 1 5234 33001
+h 3 8.8.8.8
+p 3 15120 33003
+h 4 8.8.8.8
+p 4 15300 33004
+x 0 33005
+x 1 33006
+x 2 33007
+x 3 33008
+p 0 498 33005
+p 1 5100 33006
+h 2 72.14.215.85
+p 2 9876 33007
+p 3 15500 33008`
+
+func TestMtrRawParser(t *testing.T) {
+	parser := newMtrRawParser(2)
+	var hops []*Hop
+	// Hop numbers emitted after each record, to check hops stream as they settle
+	emittedAt := make(map[string]int)
+	for _, line := range strings.Split(mtrRawCapture, "\n") {
+		if !parser.parseLine(line) {
+			t.Fatalf("parseLine(%q) = false", line)
+		}
+		for _, hop := range parser.settled(nil) {
+			emittedAt[line] = hop.HopNumber
+			hops = append(hops, hop)
+		}
+	}
+	if len(hops) != 3 {
+		t.Fatalf("streamed %d hops before mtr exited, want 3", len(hops))
+	}
+	for line, hopNum := range map[string]int{"p 0 498 33005": 1, "p 1 5100 33006": 2, "p 3 15500 33008": 3} {
+		if emittedAt[line] != hopNum {
+			t.Errorf("after %q emitted hop %d, want %d", line, emittedAt[line], hopNum)
+		}
+	}
+
+	hops = append(hops, parser.finish("8.8.8.8", 30, nil)...)
+	if len(hops) != 4 {
+		t.Fatalf("got %d hops, want 4", len(hops))
+	}
+
+	expected := []struct {
+		ip          string
+		received    int
+		loss        float64
+		avg         float64
+		destination bool
+	}{
+		{"192.168.1.1", 2, 0, 0.505, false},
+		{"10.0.0.1", 2, 0, 5.167, false},
+		{"72.14.215.85", 1, 50, 9.876, false},
+		{"8.8.8.8", 2, 0, 15.31, true},
+	}
+	for i, want := range expected {
+		hop := hops[i]
+		if hop.HopNumber != i+1 || hop.IPAddress != want.ip || hop.IsDestination != want.destination {
+			t.Errorf("hop %d = %d %s destination=%v", i+1, hop.HopNumber, hop.IPAddress, hop.IsDestination)
+		}
+		if hop.Stats == nil || hop.Stats.Sent != 2 || hop.Stats.Received != want.received || hop.Stats.LossPercent != want.loss {
+			t.Errorf("hop %d stats = %+v", i+1, hop.Stats)
+			continue
+		}
+		if math.Abs(hop.AvgRTT-want.avg) > 0.001 || len(hop.RTT) != want.received {
+			t.Errorf("hop %d AvgRTT = %f RTT = %v, want avg %f", i+1, hop.AvgRTT, hop.RTT, want.avg)
+		}
+	}
+	if stats := hops[0].Stats; stats.Best != 0.498 || stats.Worst != 0.512 || stats.Last != 0.498 || math.Abs(stats.StdDev-0.007) > 0.001 {
+		t.Errorf("hop 1 stats = %+v", stats)
+	}
+}
+
+func TestMtrRawParserFinish(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		probes      int
+		maxHops     int
+		target      string
+		hops        int
+		timeouts    []int
+		destination bool
+		lastSent    int // Probes the last hop was sent and answered, if checked
+		lastRecv    int
+	}{
+		{
+			name:     "max hops without destination",
+			output:   "h 0 192.168.1.1\np 0 500\nh 1 10.0.0.1\np 1 5000\nx 2 1\nx 3 2",
+			probes:   1,
+			maxHops:  4,
+			target:   "8.8.8.8",
+			hops:     4,
+			timeouts: []int{3, 4},
+		},
+		{
+			name:        "stopped early at an unnamed destination",
+			output:      "h 0 192.168.1.1\np 0 500\nh 1 142.250.80.46\np 1 15000",
+			probes:      1,
+			maxHops:     30,
+			target:      "google.com",
+			hops:        2,
+			destination: true,
+		},
+		{
+			name:        "no transmit records counts cycles as sent",
+			output:      "h 0 192.168.1.1\np 0 500\nh 1 8.8.8.8\np 1 15000\np 1 15100",
+			probes:      3,
+			maxHops:     2,
+			target:      "8.8.8.8",
+			hops:        2,
+			destination: true,
+			lastSent:    3,
+			lastRecv:    2,
+		},
+		{
+			name:     "nothing answered",
+			output:   "x 0 1\nx 1 2",
+			probes:   1,
+			maxHops:  2,
+			target:   "8.8.8.8",
+			hops:     2,
+			timeouts: []int{1, 2},
+		},
+		{
+			name:   "junk is ignored",
+			output: "mtr: unexpected output\nq 0 1\np x 12",
+			probes: 1,
+			target: "8.8.8.8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := newMtrRawParser(tt.probes)
+			for _, line := range strings.Split(tt.output, "\n") {
+				parser.parseLine(line)
+			}
+			hops := append(parser.settled(nil), parser.finish(tt.target, tt.maxHops, nil)...)
+			if len(hops) != tt.hops {
+				t.Fatalf("got %d hops, want %d", len(hops), tt.hops)
+			}
+			var timeouts []int
+			for _, hop := range hops {
+				if hop.IsTimeout {
+					timeouts = append(timeouts, hop.HopNumber)
+				}
+			}
+			if len(timeouts) != len(tt.timeouts) {
+				t.Errorf("timeouts = %v, want %v", timeouts, tt.timeouts)
+			}
+			if len(hops) > 0 && hops[len(hops)-1].IsDestination != tt.destination {
+				t.Errorf("IsDestination = %v, want %v", hops[len(hops)-1].IsDestination, tt.destination)
+			}
+			if tt.lastSent > 0 {
+				if stats := hops[len(hops)-1].Stats; stats.Sent != tt.lastSent || stats.Received != tt.lastRecv {
+					t.Errorf("stats = %+v, want %d of %d", stats, tt.lastRecv, tt.lastSent)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package trace
+
+// Options controls how a traceroute is run
+type Options struct {
+	MaxHops      int     `json:"maxHops"`            // Maximum number of hops to probe
+	ProbesPerHop int     `json:"probesPerHop"`       // Number of probes sent per hop
+	WaitSeconds  int     `json:"waitSeconds"`        // Seconds to wait for each probe reply
+	Backend      Backend `json:"backend,omitempty"`  // Trace tool to run; empty or auto picks the best available
+	ASLookup     bool    `json:"asLookup,omitempty"` // Have traceroute look up each hop's AS number itself
+	MPLS         bool    `json:"mpls,omitempty"`     // Have traceroute record MPLS label stacks (Linux traceroute -e)
+}
+
+// DefaultOptions returns the options used when none are specified
+func DefaultOptions() Options {
+	return Options{
+		MaxHops:      30,
+		ProbesPerHop: 1,
+		WaitSeconds:  1,
+	}
+}
+
+// WithDefaults fills in any unset option with its default value
+func (o Options) WithDefaults() Options {
+	defaults := DefaultOptions()
+	if o.MaxHops <= 0 {
+		o.MaxHops = defaults.MaxHops
+	}
+	if o.ProbesPerHop <= 0 {
+		o.ProbesPerHop = defaults.ProbesPerHop
+	}
+	if o.WaitSeconds <= 0 {
+		o.WaitSeconds = defaults.WaitSeconds
+	}
+	return o
+}
@@ -1,6 +1,7 @@
 package trace
 
 import (
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,9 +14,9 @@ import (
 // parseDestinationIP extracts the destination IP from the traceroute header line (Unix)
 // Example: "traceroute to google.com (142.250.80.46), 30 hops max, 60 byte packets"
 func parseDestinationIP(line string) string {
-	re := regexp.MustCompile(`\(([0-9]+\.[0-9]+\.[0-9]+\.[0-9]+)\)`)
+	re := regexp.MustCompile(`\(([0-9a-fA-F:.]+)\)`)
 	matches := re.FindStringSubmatch(line)
-	if len(matches) >= 2 {
+	if len(matches) >= 2 && net.ParseIP(matches[1]) != nil {
 		return matches[1]
 	}
 	return ""
@@ -30,6 +31,18 @@ type GeoLookupFunc func(ip string) *geo.Location
 //	" 1  192.168.1.1  0.456 ms"
 //	" 3  * * *"
 //	" 5  10.0.0.1  5.1 ms  5.2 ms  5.3 ms"
+//	" 2  router.local (192.168.1.1)  0.512 ms  0.498 ms  0.501 ms"
+//	" 6  * 10.0.0.1  5.1 ms *"
+//	" 7  10.0.0.1  5.1 ms 10.0.0.2  5.3 ms"
+//	" 8  10.0.0.1  5.1 ms !X  5.2 ms !X"
+//	" 9  10.0.0.1  5.1 ms <MPLS:L=24001,E=0,S=1,T=1>  5.2 ms"
+//	"10  router.example.net (62.115.42.1) [AS1299]  21.4 ms"
+//	"11  [AS15169] 8.8.8.8  22.0 ms"
+//
+// When several addresses answer the same hop, the first one is kept, as is
+// the first unreachable annotation and the first MPLS label stack. AS tags
+// follow their address on Linux and precede it on macOS; those given for
+// other addresses are recorded as conflicts
 func parseUnixHopLine(line string, destinationIP string, geoLookup GeoLookupFunc) *Hop {
 	fields := strings.Fields(line)
 	if len(fields) < 2 {
@@ -42,16 +55,76 @@ func parseUnixHopLine(line string, destinationIP string, geoLookup GeoLookupFunc
 		return nil
 	}
 
-	// Check for timeout (all asterisks)
-	isTimeout := true
-	for _, f := range fields[1:] {
-		if f != "*" {
-			isTimeout = false
-			break
+	var ipAddress, hostname string
+	var rttValues []float64
+	var unreachable Unreachable
+	var mpls []MPLSLabel
+	var lastAddress string // Most recent address on the line, which an AS tag may follow
+	var claims []ASNClaim
+
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		next := ""
+		if i+1 < len(fields) {
+			next = fields[i+1]
+		}
+
+		switch {
+		case field == "*" || field == "ms":
+			continue
+
+		// RTT value followed by its unit
+		case next == "ms":
+			if rtt, err := strconv.ParseFloat(field, 64); err == nil {
+				rttValues = append(rttValues, rtt)
+			}
+			i++
+
+		// Hostname followed by its address in parentheses
+		case isParenthesizedIP(next):
+			lastAddress = strings.Trim(next, "()")
+			if ipAddress == "" {
+				hostname = field
+				ipAddress = lastAddress
+			}
+			i++
+
+		case net.ParseIP(field) != nil:
+			lastAddress = field
+			if ipAddress == "" {
+				ipAddress = field
+			}
+
+		// AS numbers from traceroute's own lookup, e.g. "[AS15169]"
+		case strings.HasPrefix(field, "["):
+			asns, ok := parseASTag(field)
+			if !ok {
+				continue
+			}
+			address := lastAddress
+			if next := taggedAddress(fields[i+1:]); next != "" {
+				address = next
+			}
+			for _, asn := range asns {
+				claims = append(claims, ASNClaim{ASN: asn, Source: ASNSourceTraceroute, IP: address})
+			}
+
+		// Unreachable annotation after an RTT, e.g. "!H"
+		case strings.HasPrefix(field, "!"):
+			if reason, ok := parseUnreachableFlag(field); ok && unreachable == "" {
+				unreachable = reason
+			}
+
+		// ICMP extension with the label stack, e.g. "<MPLS:L=24001,E=0,S=1,T=1>"
+		case strings.HasPrefix(field, "<MPLS:"):
+			if stack, ok := parseMPLSExtension(field); ok && mpls == nil {
+				mpls = stack
+			}
 		}
 	}
 
-	if isTimeout {
+	// No address means every probe timed out
+	if ipAddress == "" {
 		return &Hop{
 			HopNumber:     hopNum,
 			IPAddress:     "*",
@@ -64,62 +137,73 @@ func parseUnixHopLine(line string, destinationIP string, geoLookup GeoLookupFunc
 		}
 	}
 
-	// Parse IP address (second field)
-	ipAddress := fields[1]
+	hop := &Hop{
+		HopNumber:     hopNum,
+		IPAddress:     ipAddress,
+		Hostname:      hostname,
+		RTT:           rttValues,
+		AvgRTT:        average(rttValues),
+		IsTimeout:     false,
+		IsDestination: ipAddress == destinationIP,
+		Timestamp:     time.Now().UnixMilli(),
+		Unreachable:   unreachable,
+		MPLS:          mpls,
+	}
+	for _, claim := range claims {
+		mergeASN(hop, claim)
+	}
+	enrichHop(hop, geoLookup)
+	return hop
+}
 
-	// Parse RTT values (look for numbers followed by "ms")
-	var rttValues []float64
-	for i := 2; i < len(fields); i++ {
-		if fields[i] == "ms" && i > 2 {
-			// Previous field should be the RTT value
-			rtt, err := strconv.ParseFloat(fields[i-1], 64)
-			if err == nil {
-				rttValues = append(rttValues, rtt)
-			}
-		}
+// taggedAddress returns the address starting fields, which an AS tag printed
+// before its address belongs to, or "" if fields start with something else
+func taggedAddress(fields []string) string {
+	switch {
+	case len(fields) == 0:
+		return ""
+	case net.ParseIP(fields[0]) != nil:
+		return fields[0]
+	case len(fields) > 1 && isParenthesizedIP(fields[1]):
+		return strings.Trim(fields[1], "()")
 	}
+	return ""
+}
 
-	// Calculate average RTT
-	var avgRTT float64
-	if len(rttValues) > 0 {
-		sum := 0.0
-		for _, rtt := range rttValues {
-			sum += rtt
-		}
-		avgRTT = sum / float64(len(rttValues))
+// isParenthesizedIP reports whether a field looks like "(192.168.1.1)"
+func isParenthesizedIP(field string) bool {
+	if len(field) < 3 || field[0] != '(' || field[len(field)-1] != ')' {
+		return false
 	}
+	return net.ParseIP(field[1:len(field)-1]) != nil
+}
 
-	// Look up geolocation if function provided
-	var location *geo.Location
-	if geoLookup != nil {
-		location = geoLookup(ipAddress)
+// enrichHop adds geolocation and datacenter details to a hop
+func enrichHop(hop *Hop, geoLookup GeoLookupFunc) {
+	if hop.IsTimeout {
+		return
 	}
 
-	// Detect datacenter from ISP/Org info
-	var dc *datacenter.DataCenter
-	if location != nil {
-		dc = datacenter.Detect(location.Org, location.ISP, "")
+	// Look up geolocation if function provided
+	if geoLookup != nil {
+		hop.Location = geoLookup(hop.IPAddress)
 	}
 
-	return &Hop{
-		HopNumber:     hopNum,
-		IPAddress:     ipAddress,
-		RTT:           rttValues,
-		AvgRTT:        avgRTT,
-		Location:      location,
-		DataCenter:    dc,
-		IsTimeout:     false,
-		IsDestination: ipAddress == destinationIP,
-		Timestamp:     time.Now().UnixMilli(),
+	// Detect datacenter from ISP/Org info, falling back to the hostname
+	if hop.Location != nil {
+		mergeASN(hop, ASNClaim{ASN: hop.Location.ASN, Source: ASNSourceGeo})
+		hop.DataCenter = datacenter.Detect(hop.Location.Org, hop.Location.ISP, hop.Hostname)
+	} else if hop.Hostname != "" {
+		hop.DataCenter = datacenter.Detect("", "", hop.Hostname)
 	}
 }
 
 // parseWindowsDestinationIP extracts the destination IP from the tracert header line
 // Example: "Tracing route to google.com [142.250.80.46]"
 func parseWindowsDestinationIP(line string) string {
-	re := regexp.MustCompile(`\[([0-9]+\.[0-9]+\.[0-9]+\.[0-9]+)\]`)
+	re := regexp.MustCompile(`\[([0-9a-fA-F:.]+)\]`)
 	matches := re.FindStringSubmatch(line)
-	if len(matches) >= 2 {
+	if len(matches) >= 2 && net.ParseIP(matches[1]) != nil {
 		return matches[1]
 	}
 	return ""
@@ -131,6 +215,8 @@ func parseWindowsDestinationIP(line string) string {
 //	"  1    <1 ms    <1 ms    <1 ms  192.168.1.1"
 //	"  2     5 ms     4 ms     5 ms  10.0.0.1"
 //	"  3     *        *        *     Request timed out."
+//	"  4    12 ms     *       11 ms  router.example.com [10.0.0.1]"
+//	"  5  10.0.0.1  reports: Destination host unreachable."
 func parseWindowsHopLine(line string, destinationIP string, geoLookup GeoLookupFunc) *Hop {
 	line = strings.TrimSpace(line)
 	if line == "" {
@@ -174,16 +260,29 @@ func parseWindowsHopLine(line string, destinationIP string, geoLookup GeoLookupF
 	// Parse RTT values (3 RTT columns before the IP)
 	// Format: hopNum rtt1 ms rtt2 ms rtt3 ms IP
 	// Or: hopNum <1 ms <1 ms <1 ms IP
+	// Or: hopNum rtt1 ms rtt2 ms rtt3 ms hostname [IP]
 	var rttValues []float64
 	ipAddress := ""
+	hostname := ""
+	ipIndex := -1
 
 	// Find the IP address (last field that looks like an IP)
-	ipPattern := regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+\.[0-9]+$`)
-	for i := len(fields) - 1; i >= 0; i-- {
-		if ipPattern.MatchString(fields[i]) {
-			ipAddress = fields[i]
-			break
+	for i := len(fields) - 1; i >= 1; i-- {
+		candidate := fields[i]
+		bracketed := strings.HasPrefix(candidate, "[") && strings.HasSuffix(candidate, "]")
+		if bracketed {
+			candidate = strings.Trim(candidate, "[]")
 		}
+		if net.ParseIP(candidate) == nil {
+			continue
+		}
+		ipAddress = candidate
+		ipIndex = i
+		if bracketed && i > 1 {
+			hostname = fields[i-1]
+			ipIndex = i - 1
+		}
+		break
 	}
 
 	if ipAddress == "" {
@@ -191,14 +290,9 @@ func parseWindowsHopLine(line string, destinationIP string, geoLookup GeoLookupF
 	}
 
 	// Parse RTT values between hop number and IP
-	for i := 1; i < len(fields); i++ {
+	for i := 1; i < ipIndex; i++ {
 		field := fields[i]
 
-		// Stop if we hit the IP address
-		if field == ipAddress {
-			break
-		}
-
 		// Skip "ms" markers
 		if field == "ms" {
 			continue
@@ -222,37 +316,19 @@ func parseWindowsHopLine(line string, destinationIP string, geoLookup GeoLookupF
 		}
 	}
 
-	// Calculate average RTT
-	var avgRTT float64
-	if len(rttValues) > 0 {
-		sum := 0.0
-		for _, rtt := range rttValues {
-			sum += rtt
-		}
-		avgRTT = sum / float64(len(rttValues))
-	}
-
-	// Look up geolocation if function provided
-	var location *geo.Location
-	if geoLookup != nil {
-		location = geoLookup(ipAddress)
-	}
-
-	// Detect datacenter from ISP/Org info
-	var dc *datacenter.DataCenter
-	if location != nil {
-		dc = datacenter.Detect(location.Org, location.ISP, "")
-	}
-
-	return &Hop{
+	hop := &Hop{
 		HopNumber:     hopNum,
 		IPAddress:     ipAddress,
+		Hostname:      hostname,
 		RTT:           rttValues,
-		AvgRTT:        avgRTT,
-		Location:      location,
-		DataCenter:    dc,
+		AvgRTT:        average(rttValues),
 		IsTimeout:     false,
 		IsDestination: ipAddress == destinationIP,
 		Timestamp:     time.Now().UnixMilli(),
 	}
+	if reason, ok := parseWindowsUnreachable(line); ok {
+		hop.Unreachable = reason
+	}
+	enrichHop(hop, geoLookup)
+	return hop
 }
@@ -0,0 +1,75 @@
+package trace
+
+import (
+	"packet-painter/internal/geo"
+)
+
+// Outcome describes how a trace ended
+type Outcome string
+
+const (
+	OutcomeCompleted Outcome = "completed"
+	OutcomeCancelled Outcome = "cancelled"
+	OutcomeError     Outcome = "error"
+)
+
+// Result is the complete record of a finished trace
+type Result struct {
+	SessionID  string        `json:"sessionId"`
+	Target     string        `json:"target"`
+	Options    Options       `json:"options"`
+	Source     *geo.Location `json:"source"`
+	Hops       []*Hop        `json:"hops"`
+	StartedAt  int64         `json:"startedAt"`
+	EndedAt    int64         `json:"endedAt"`
+	DurationMs int64         `json:"durationMs"`
+	Outcome    Outcome       `json:"outcome"`
+	Error      string        `json:"error,omitempty"`
+	ErrorCode  ErrorCode     `json:"errorCode,omitempty"`
+
+	// How far a completed trace got and why it stopped
+	PathOutcome PathOutcome `json:"pathOutcome,omitempty"`
+	StopReason  StopReason  `json:"stopReason,omitempty"`
+}
+
+// Reached reports whether the destination responded
+func (r *Result) Reached() bool {
+	for _, hop := range r.Hops {
+		if hop.IsDestination {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns how far a completed trace got and why it stopped
+// Results recorded before stop reasons existed, such as imports, are taken
+// to have run to the tool's own end
+func (r *Result) Path() (PathOutcome, StopReason) {
+	if r.StopReason != "" {
+		return pathOutcome(r.StopReason, r.Hops), r.StopReason
+	}
+	if r.Reached() {
+		return PathReached, ReasonDestination
+	}
+	if lastUnreachable(r.Hops) != "" {
+		return pathOutcome(ReasonUnreachable, r.Hops), ReasonUnreachable
+	}
+	return pathOutcome(ReasonMaxHops, r.Hops), ReasonMaxHops
+}
+
+// PathMTU returns the smallest MTU any hop reported, or 0 if the backend does not discover it
+func (r *Result) PathMTU() int {
+	mtu := 0
+	for _, hop := range r.Hops {
+		if hop.MTU > 0 && (mtu == 0 || hop.MTU < mtu) {
+			mtu = hop.MTU
+		}
+	}
+	return mtu
+}
+
+// MPLSTunnels returns the MPLS tunnels detected along the path
+func (r *Result) MPLSTunnels() []MPLSTunnel {
+	return DetectMPLSTunnels(r.Hops)
+}
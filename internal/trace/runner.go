@@ -2,7 +2,9 @@ package trace
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"packet-painter/internal/geo"
@@ -16,27 +18,82 @@ type Runner interface {
 	Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) error
 }
 
+// Status is where a session is in its lifecycle
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusCancelled Status = "cancelled"
+	StatusError     Status = "error"
+)
+
 // Session manages a real traceroute session
+// It records every hop and how the trace ended, so its state can be
+// snapshotted at any time, during the run or after. Its deadline policy
+// decides when a trace that is still finding hops has run long enough
 type Session struct {
 	ID         string
 	Target     string
+	Options    Options
 	runner     Runner
 	geoLookup  *geo.Lookup
 	cancelFunc context.CancelFunc
 	mu         sync.Mutex
-	running    bool
+	deadline   DeadlinePolicy
+	status     Status
+	hops       []*Hop
+	startedAt  int64
+	endedAt    int64
+	stopped    StopReason // Why the deadline policy stopped the trace, if it did
+	reason     StopReason // Why a completed trace stopped
+	err        error
+	done       chan struct{}
 }
 
 // NewSession creates a new traceroute session for the given target
 func NewSession(target string) *Session {
+	return NewSessionWithOptions(target, DefaultOptions())
+}
+
+// NewSessionWithOptions creates a new traceroute session with custom options
+func NewSessionWithOptions(target string, opts Options) *Session {
+	opts = opts.WithDefaults()
+	return NewSessionWithRunner(target, opts, newPlatformRunner(opts))
+}
+
+// NewSessionWithRunner creates a session that uses the given runner
+// Useful for alternative backends and for tests
+func NewSessionWithRunner(target string, opts Options, runner Runner) *Session {
+	opts = opts.WithDefaults()
 	return &Session{
 		ID:        uuid.New().String(),
 		Target:    target,
-		runner:    newPlatformRunner(),
+		Options:   opts,
+		runner:    runner,
 		geoLookup: geo.NewLookup(),
+		deadline:  DefaultDeadlinePolicy(opts),
+		status:    StatusPending,
+		done:      make(chan struct{}),
 	}
 }
 
+// DeadlinePolicy returns the session's deadline policy
+func (s *Session) DeadlinePolicy() DeadlinePolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deadline
+}
+
+// SetDeadlinePolicy replaces the session's deadline policy
+// Has no effect once the session has started
+func (s *Session) SetDeadlinePolicy(policy DeadlinePolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadline = policy
+}
+
 // GetSource returns the source location for this session
 // Returns nil since we don't have local geo lookup
 func (s *Session) GetSource() *geo.Location {
@@ -44,35 +101,180 @@ func (s *Session) GetSource() *geo.Location {
 }
 
 // Start begins the traceroute with real system commands
+// The callbacks run on the session goroutine after the session has recorded the event.
+// onComplete is also called when the deadline policy stops the trace with hops found
 func (s *Session) Start(ctx context.Context, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) {
 	s.mu.Lock()
-	if s.running {
+	// A session runs at most once
+	if s.status != StatusPending {
 		s.mu.Unlock()
 		return
 	}
-	s.running = true
+	s.status = StatusRunning
+	s.startedAt = time.Now().UnixMilli()
 
 	// Create a cancellable context
 	ctx, s.cancelFunc = context.WithCancel(ctx)
+	cancel := s.cancelFunc
+	policy := s.deadline
 	s.mu.Unlock()
 
 	go func() {
-		defer func() {
+		defer close(s.done)
+
+		// The policy ends a trace by cancelling it, noting why first
+		budget := startBudget(policy, func(reason StopReason) {
 			s.mu.Lock()
-			s.running = false
+			if s.stopped == "" {
+				s.stopped = reason
+			}
 			s.mu.Unlock()
-		}()
+			cancel()
+		})
+		defer budget.close()
 
-		err := s.runner.Run(ctx, s.Target, s.geoLookup, onHop, onComplete, onError)
-		if err != nil && onError != nil {
+		err := s.runner.Run(ctx, s.Target, s.geoLookup,
+			func(hop *Hop) {
+				s.mu.Lock()
+				s.hops = append(s.hops, hop)
+				s.mu.Unlock()
+				if onHop != nil {
+					onHop(hop)
+				}
+				budget.hop(hop)
+			},
+			func(totalHops int) {
+				if s.complete(ReasonMaxHops) && onComplete != nil {
+					onComplete(totalHops)
+				}
+			},
+			func(err error) {
+				s.finish(StatusError, err)
+				if onError != nil {
+					onError(err)
+				}
+			},
+		)
+		if err != nil && ctx.Err() == nil && s.finish(StatusError, err) && onError != nil {
 			// Only call onError if context wasn't cancelled
-			if ctx.Err() == nil {
-				onError(err)
+			onError(err)
+		}
+
+		// The runner stopped without reporting, so the policy or the context ended it.
+		// Running out of time still completes the trace with what it found
+		s.mu.Lock()
+		reason := s.stopped
+		found := len(s.hops)
+		s.mu.Unlock()
+		if reason == "" && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = ReasonDeadline
+		}
+
+		switch {
+		case reason == ReasonDeadline && found == 0:
+			s.finish(StatusError, newTraceError(CodeDeadlineExceeded, "", context.DeadlineExceeded))
+		case reason != "":
+			if s.complete(reason) && onComplete != nil {
+				onComplete(found)
 			}
+		default:
+			s.finish(StatusCancelled, ctx.Err())
 		}
 	}()
 }
 
+// finish records how the session ended; only the first call has any effect
+// Reports whether this call was the one that finished the session
+func (s *Session) finish(status Status, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finishLocked(status, err)
+}
+
+// complete finishes the session as completed, stopped for reason
+func (s *Session) complete(reason StopReason) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.finishLocked(StatusCompleted, nil) {
+		return false
+	}
+	s.reason = reason
+	switch {
+	case pathOutcome(reason, s.hops) == PathReached:
+		s.reason = ReasonDestination
+	case lastUnreachable(s.hops) != "":
+		s.reason = ReasonUnreachable
+	}
+	return true
+}
+
+// finishLocked is finish with s.mu held
+func (s *Session) finishLocked(status Status, err error) bool {
+	if s.status != StatusRunning {
+		return false
+	}
+	s.status = status
+	s.err = err
+	s.endedAt = time.Now().UnixMilli()
+	return true
+}
+
+// Run starts the session and blocks until it finishes, returning the recorded result
+// onHop is optional and is called for each hop as it arrives. A result is
+// returned for failed, timed-out and cancelled traces too, alongside the error
+func (s *Session) Run(ctx context.Context, onHop HopCallback) (*Result, error) {
+	s.Start(ctx, onHop, nil, nil)
+	<-s.Done()
+
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	return s.Snapshot(), err
+}
+
+// Snapshot returns the session's state so far as a result
+// Outcome is empty until the session has finished
+func (s *Session) Snapshot() *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &Result{
+		SessionID: s.ID,
+		Target:    s.Target,
+		Options:   s.Options,
+		Source:    s.GetSource(),
+		Hops:      append([]*Hop(nil), s.hops...),
+		StartedAt: s.startedAt,
+		EndedAt:   s.endedAt,
+	}
+	switch s.status {
+	case StatusCompleted:
+		result.Outcome = OutcomeCompleted
+		result.PathOutcome = pathOutcome(s.reason, result.Hops)
+		result.StopReason = s.reason
+	case StatusCancelled:
+		result.Outcome = OutcomeCancelled
+	case StatusError:
+		result.Outcome = OutcomeError
+	}
+	if s.endedAt > 0 {
+		result.DurationMs = s.endedAt - s.startedAt
+	}
+	if s.err != nil {
+		result.Error = s.err.Error()
+		result.ErrorCode = ErrorCodeOf(s.err)
+	}
+	return result
+}
+
+// Status returns where the session is in its lifecycle
+func (s *Session) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
 // Cancel stops the traceroute session
 func (s *Session) Cancel() {
 	s.mu.Lock()
@@ -81,12 +283,15 @@ func (s *Session) Cancel() {
 	if s.cancelFunc != nil {
 		s.cancelFunc()
 	}
-	s.running = false
+}
+
+// Done returns a channel that is closed once the runner has fully stopped,
+// whether it completed, failed or was cancelled
+func (s *Session) Done() <-chan struct{} {
+	return s.done
 }
 
 // IsRunning returns whether the session is currently running
 func (s *Session) IsRunning() bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.running
+	return s.Status() == StatusRunning
 }
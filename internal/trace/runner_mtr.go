@@ -0,0 +1,127 @@
+//go:build darwin || linux
+
+package trace
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"packet-painter/internal/geo"
+)
+
+// mtrRunner implements Runner with mtr, which probes every hop repeatedly
+type mtrRunner struct {
+	opts Options
+	path string // Where mtr was found; empty to search PATH
+}
+
+// Run executes mtr and streams hops with their probe statistics as they settle
+func (r *mtrRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) error {
+	if err := checkTarget(target); err != nil {
+		return err
+	}
+
+	// Command: mtr -l -n -c 1 -m 30 -G 1 <target>
+	// -l: Raw output, one record per probe sent or answered
+	// -n: No DNS lookup (just IPs)
+	// -c: Cycles, one probe per hop each (probes per hop)
+	// -m: Max hops
+	// -G: Seconds to wait for replies after the last probe
+	cmd := exec.CommandContext(ctx, r.command(), "-l", "-n",
+		"-c", strconv.Itoa(r.opts.ProbesPerHop),
+		"-m", strconv.Itoa(r.opts.MaxHops),
+		"-G", strconv.Itoa(r.opts.WaitSeconds),
+		target)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return startFailure("mtr", err)
+	}
+
+	var geoLookupFunc GeoLookupFunc
+	if geoLookup != nil {
+		geoLookupFunc = geoLookup.GetLocation
+	}
+
+	parser := newMtrRawParser(r.opts.ProbesPerHop)
+	var hopCount int
+	emit := func(hops []*Hop) {
+		for _, hop := range hops {
+			hopCount++
+			if onHop != nil {
+				onHop(hop)
+			}
+		}
+	}
+	var unparsed strings.Builder
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			return ctx.Err()
+		default:
+		}
+
+		line := scanner.Text()
+		if parser.parseLine(line) {
+			emit(parser.settled(geoLookupFunc))
+		} else if strings.TrimSpace(line) != "" {
+			unparsed.WriteString(line)
+			unparsed.WriteString("\n")
+		}
+	}
+
+	// Read any stderr output
+	stderrScanner := bufio.NewScanner(stderr)
+	var stderrOutput strings.Builder
+	for stderrScanner.Scan() {
+		stderrOutput.WriteString(stderrScanner.Text())
+		stderrOutput.WriteString("\n")
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return toolFailure("mtr", err, stderrOutput.String()+unparsed.String())
+	}
+
+	emit(parser.finish(target, r.opts.MaxHops, geoLookupFunc))
+
+	// mtr exits cleanly after printing some failures, e.g. an unknown host
+	if hopCount == 0 {
+		output := stderrOutput.String() + unparsed.String()
+		if code, ok := classifyOutput(output); ok {
+			return newTraceError(code, strings.TrimSpace(output), nil)
+		}
+	}
+
+	if onComplete != nil {
+		onComplete(hopCount)
+	}
+
+	return nil
+}
+
+// command returns the mtr binary to run
+func (r *mtrRunner) command() string {
+	if r.path != "" {
+		return r.path
+	}
+	return "mtr"
+}
@@ -1,7 +1,11 @@
 package trace
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"packet-painter/internal/geo"
 )
 
 func TestParseDestinationIP(t *testing.T) {
@@ -96,6 +100,59 @@ func TestParseUnixHopLine(t *testing.T) {
 				IsDestination: true,
 			},
 		},
+		{
+			name:          "hop with hostname",
+			input:         " 2  router.local (192.168.1.1)  0.512 ms  0.498 ms  0.501 ms",
+			destinationIP: "8.8.8.8",
+			expected: &Hop{
+				HopNumber:     2,
+				IPAddress:     "192.168.1.1",
+				Hostname:      "router.local",
+				RTT:           []float64{0.512, 0.498, 0.501},
+				AvgRTT:        0.50366666,
+				IsTimeout:     false,
+				IsDestination: false,
+			},
+		},
+		{
+			name:          "hop with partial timeouts",
+			input:         " 6  * 10.0.0.1  5.1 ms *",
+			destinationIP: "8.8.8.8",
+			expected: &Hop{
+				HopNumber:     6,
+				IPAddress:     "10.0.0.1",
+				RTT:           []float64{5.1},
+				AvgRTT:        5.1,
+				IsTimeout:     false,
+				IsDestination: false,
+			},
+		},
+		{
+			name:          "hop answered by several addresses",
+			input:         " 7  10.0.0.1  5.1 ms 10.0.0.2  5.3 ms",
+			destinationIP: "8.8.8.8",
+			expected: &Hop{
+				HopNumber:     7,
+				IPAddress:     "10.0.0.1",
+				RTT:           []float64{5.1, 5.3},
+				AvgRTT:        5.2,
+				IsTimeout:     false,
+				IsDestination: false,
+			},
+		},
+		{
+			name:          "IPv6 hop",
+			input:         " 3  2001:db8::1  12.4 ms",
+			destinationIP: "2001:db8::1",
+			expected: &Hop{
+				HopNumber:     3,
+				IPAddress:     "2001:db8::1",
+				RTT:           []float64{12.4},
+				AvgRTT:        12.4,
+				IsTimeout:     false,
+				IsDestination: true,
+			},
+		},
 		{
 			name:          "empty line",
 			input:         "",
@@ -132,6 +189,9 @@ func TestParseUnixHopLine(t *testing.T) {
 			if result.IPAddress != tt.expected.IPAddress {
 				t.Errorf("IPAddress = %s, want %s", result.IPAddress, tt.expected.IPAddress)
 			}
+			if result.Hostname != tt.expected.Hostname {
+				t.Errorf("Hostname = %s, want %s", result.Hostname, tt.expected.Hostname)
+			}
 			if result.IsTimeout != tt.expected.IsTimeout {
 				t.Errorf("IsTimeout = %v, want %v", result.IsTimeout, tt.expected.IsTimeout)
 			}
@@ -247,6 +307,20 @@ func TestParseWindowsHopLine(t *testing.T) {
 				IsDestination: true,
 			},
 		},
+		{
+			name:          "hop with hostname and partial timeout",
+			input:         "  5    12 ms     *       11 ms  router.example.com [10.0.0.1]",
+			destinationIP: "8.8.8.8",
+			expected: &Hop{
+				HopNumber:     5,
+				IPAddress:     "10.0.0.1",
+				Hostname:      "router.example.com",
+				RTT:           []float64{12, 11},
+				AvgRTT:        11.5,
+				IsTimeout:     false,
+				IsDestination: false,
+			},
+		},
 		{
 			name:          "empty line",
 			input:         "",
@@ -283,6 +357,9 @@ func TestParseWindowsHopLine(t *testing.T) {
 			if result.IPAddress != tt.expected.IPAddress {
 				t.Errorf("IPAddress = %s, want %s", result.IPAddress, tt.expected.IPAddress)
 			}
+			if result.Hostname != tt.expected.Hostname {
+				t.Errorf("Hostname = %s, want %s", result.Hostname, tt.expected.Hostname)
+			}
 			if result.IsTimeout != tt.expected.IsTimeout {
 				t.Errorf("IsTimeout = %v, want %v", result.IsTimeout, tt.expected.IsTimeout)
 			}
@@ -402,3 +479,90 @@ func TestParseWindowsFullOutput(t *testing.T) {
 		}
 	}
 }
+
+// stepRunner emits hops one at a time as the test allows
+type stepRunner struct {
+	hops []*Hop
+	next chan struct{}
+}
+
+func (r *stepRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) error {
+	for _, hop := range r.hops {
+		select {
+		case <-r.next:
+			onHop(hop)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	onComplete(len(r.hops))
+	return nil
+}
+
+func TestSessionSnapshot(t *testing.T) {
+	runner := &stepRunner{
+		hops: []*Hop{{HopNumber: 1, IPAddress: "192.168.1.1"}, {HopNumber: 2, IPAddress: "8.8.8.8", IsDestination: true}},
+		next: make(chan struct{}),
+	}
+	session := NewSessionWithRunner("8.8.8.8", Options{MaxHops: 5}, runner)
+	if session.Status() != StatusPending {
+		t.Fatalf("Status() = %s before start, want pending", session.Status())
+	}
+
+	hops := make(chan *Hop)
+	session.Start(context.Background(), func(hop *Hop) { hops <- hop }, nil, nil)
+	runner.next <- struct{}{}
+	<-hops
+
+	snapshot := session.Snapshot()
+	if session.Status() != StatusRunning || snapshot.Outcome != "" || len(snapshot.Hops) != 1 || snapshot.StartedAt == 0 {
+		t.Errorf("running snapshot = %+v, status %s", snapshot, session.Status())
+	}
+	if snapshot.Options.MaxHops != 5 {
+		t.Errorf("snapshot MaxHops = %d, want 5", snapshot.Options.MaxHops)
+	}
+
+	runner.next <- struct{}{}
+	<-hops
+	<-session.Done()
+
+	snapshot = session.Snapshot()
+	if session.Status() != StatusCompleted || snapshot.Outcome != OutcomeCompleted || len(snapshot.Hops) != 2 || snapshot.EndedAt == 0 {
+		t.Errorf("finished snapshot = %+v, status %s", snapshot, session.Status())
+	}
+}
+
+func TestSessionCancelStatus(t *testing.T) {
+	runner := &stepRunner{hops: []*Hop{{HopNumber: 1}}, next: make(chan struct{})}
+
+	tests := []struct {
+		name     string
+		ctx      func() (context.Context, context.CancelFunc)
+		expected Status
+		err      string
+	}{
+		{"cancelled", func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) }, StatusCancelled, "context canceled"},
+		{"timed out", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), time.Millisecond)
+		}, StatusError, "trace timed out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			session := NewSessionWithRunner("8.8.8.8", DefaultOptions(), runner)
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+			}()
+
+			result, err := session.Run(ctx, nil)
+			if session.Status() != tt.expected {
+				t.Errorf("Status() = %s, want %s", session.Status(), tt.expected)
+			}
+			if err == nil || err.Error() != tt.err || result.Error != tt.err {
+				t.Errorf("error = %v (result %q), want %s", err, result.Error, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+//go:build darwin || linux
+
+package trace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTool writes a script standing in for a trace tool: it records its
+// arguments, prints output and runs the shell command then, e.g. a sleep for a trace still going
+func fakeTool(t *testing.T, name, output, then string) (path, argsFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	path = filepath.Join(dir, name)
+	argsFile = filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s ' \"$@\" > " + argsFile + "\ncat <<'EOF'\n" + output + "\nEOF\n" + then + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, argsFile
+}
+
+func TestMtrRunner(t *testing.T) {
+	path, argsFile := fakeTool(t, "mtr", mtrRawCapture, "")
+	runner := &mtrRunner{opts: Options{MaxHops: 30, ProbesPerHop: 2, WaitSeconds: 1}, path: path}
+
+	var hops []*Hop
+	total := -1
+	err := runner.Run(context.Background(), "8.8.8.8", nil,
+		func(hop *Hop) { hops = append(hops, hop) },
+		func(n int) { total = n },
+		nil)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if total != 4 || len(hops) != 4 || !hops[3].IsDestination || hops[2].Stats.LossPercent != 50 {
+		t.Errorf("total = %d, hops = %d", total, len(hops))
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	if got := strings.TrimSpace(string(args)); got != "-l -n -c 2 -m 30 -G 1 8.8.8.8" {
+		t.Errorf("mtr args = %q", got)
+	}
+}
+
+func TestMtrRunnerCancel(t *testing.T) {
+	path, _ := fakeTool(t, "mtr", "h 0 192.168.1.1\np 0 500 1\nh 1 10.0.0.1\np 1 5000 2", "exec sleep 10")
+	runner := &mtrRunner{opts: Options{MaxHops: 30, ProbesPerHop: 1, WaitSeconds: 1}, path: path}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runner.Run(ctx, "8.8.8.8", nil, func(hop *Hop) { cancel() }, nil, nil)
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		cancel()
+		t.Fatal("Run() did not return after cancellation")
+	}
+}
+
+func TestMtrRunnerFailure(t *testing.T) {
+	path, _ := fakeTool(t, "mtr", "", "echo 'mtr: Failed to resolve host: nosuch.example: Name or service not known' >&2\nexit 1")
+	runner := &mtrRunner{opts: DefaultOptions(), path: path}
+
+	err := runner.Run(context.Background(), "nosuch.example", nil, nil, nil, nil)
+	var te *TraceError
+	if !errors.As(err, &te) || te.Code != CodeUnknownHost {
+		t.Errorf("Run() error = %#v, want %s", err, CodeUnknownHost)
+	}
+}
+
+func TestTracepathRunner(t *testing.T) {
+	path, argsFile := fakeTool(t, "tracepath", tracepathCapture, "")
+	runner := &tracepathRunner{opts: Options{MaxHops: 20, ProbesPerHop: 3, WaitSeconds: 2}, path: path}
+
+	var hops []*Hop
+	total := -1
+	err := runner.Run(context.Background(), "8.8.8.8", nil,
+		func(hop *Hop) { hops = append(hops, hop) },
+		func(n int) { total = n },
+		nil)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if total != 5 || len(hops) != 5 || !hops[4].IsDestination || hops[4].MTU != 1420 {
+		t.Errorf("total = %d, hops = %d", total, len(hops))
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	if got := strings.TrimSpace(string(args)); got != "-n -m 20 8.8.8.8" {
+		t.Errorf("tracepath args = %q", got)
+	}
+}
+
+func TestUnixRunnerExtensions(t *testing.T) {
+	path, argsFile := fakeTool(t, "traceroute", tracerouteMPLSCapture, "")
+
+	tests := []struct {
+		name string
+		mpls bool
+		want string
+	}{
+		// Not every traceroute accepts -e, so it is only passed when asked for
+		{"default", false, "-n -q 3 -w 1 -m 30 8.8.8.8"},
+		{"mpls", true, "-n -q 3 -w 1 -m 30 -e 8.8.8.8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &unixRunner{opts: Options{MaxHops: 30, ProbesPerHop: 3, WaitSeconds: 1, MPLS: tt.mpls}, path: path}
+
+			var hops []*Hop
+			err := runner.Run(context.Background(), "8.8.8.8", nil,
+				func(hop *Hop) { hops = append(hops, hop) },
+				nil, nil)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if len(hops) != 6 || len(hops[2].MPLS) != 2 || hops[2].MPLS[1].Label != 300 {
+				t.Errorf("hops = %d, hop 3 MPLS = %+v", len(hops), hops[2].MPLS)
+			}
+
+			want := tt.want
+			if runtime.GOOS != "linux" {
+				want = strings.Replace(want, " -e", "", 1)
+			}
+			args, _ := os.ReadFile(argsFile)
+			if got := strings.TrimSpace(string(args)); got != want {
+				t.Errorf("traceroute args = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestUnixRunnerASLookup(t *testing.T) {
+	output := "traceroute to 8.8.8.8 (8.8.8.8), 30 hops max, 60 byte packets\n 1  8.8.8.8 [AS15169]  22.015 ms"
+	path, argsFile := fakeTool(t, "traceroute", output, "")
+	runner := &unixRunner{opts: Options{MaxHops: 30, ProbesPerHop: 1, WaitSeconds: 1, ASLookup: true}, path: path}
+
+	var hops []*Hop
+	if err := runner.Run(context.Background(), "8.8.8.8", nil, func(hop *Hop) { hops = append(hops, hop) }, nil, nil); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(hops) != 1 || hops[0].ASN != "AS15169" {
+		t.Errorf("hops = %+v", hops)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	flag := "-A"
+	if runtime.GOOS == "darwin" {
+		flag = "-a"
+	}
+	if got := strings.Fields(string(args)); !slices.Contains(got, flag) {
+		t.Errorf("traceroute args = %q, want %s", got, flag)
+	}
+}
@@ -0,0 +1,130 @@
+//go:build darwin || linux
+
+package trace
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"packet-painter/internal/geo"
+)
+
+// tracepathRunner implements Runner with tracepath, which needs no privileges and discovers the path MTU
+type tracepathRunner struct {
+	opts Options
+	path string // Where tracepath was found; empty to search PATH
+}
+
+// Run executes tracepath and streams hops with the path MTU at each
+func (r *tracepathRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) error {
+	if err := checkTarget(target); err != nil {
+		return err
+	}
+
+	// Command: tracepath -n -m 30 <target>
+	// -n: No DNS lookup (just IPs)
+	// -m: Max hops
+	// tracepath picks its own probe count and wait, so those options don't apply
+	cmd := exec.CommandContext(ctx, r.command(), "-n",
+		"-m", strconv.Itoa(r.opts.MaxHops),
+		target)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return startFailure("tracepath", err)
+	}
+
+	var geoLookupFunc GeoLookupFunc
+	if geoLookup != nil {
+		geoLookupFunc = geoLookup.GetLocation
+	}
+
+	parser := newTracepathParser(geoLookupFunc)
+	var hopCount int
+	emit := func(hop *Hop) {
+		if hop == nil {
+			return
+		}
+		hopCount++
+		if onHop != nil {
+			onHop(hop)
+		}
+	}
+	var terminal bool // Stopped tracepath at an unreachable hop
+	var unparsed strings.Builder
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+			return ctx.Err()
+		default:
+		}
+
+		line := scanner.Text()
+		if hop, ok := parser.parseLine(line); ok {
+			emit(hop)
+			// Nothing answers beyond a router that reports the destination unreachable
+			if parser.unreachable() {
+				terminal = true
+				cmd.Process.Kill()
+				break
+			}
+		} else if strings.TrimSpace(line) != "" {
+			unparsed.WriteString(line)
+			unparsed.WriteString("\n")
+		}
+	}
+
+	// Read any stderr output
+	stderrScanner := bufio.NewScanner(stderr)
+	var stderrOutput strings.Builder
+	for stderrScanner.Scan() {
+		stderrOutput.WriteString(stderrScanner.Text())
+		stderrOutput.WriteString("\n")
+	}
+
+	if err := cmd.Wait(); err != nil && !terminal {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return toolFailure("tracepath", err, stderrOutput.String()+unparsed.String())
+	}
+
+	emit(parser.finish())
+
+	if hopCount == 0 {
+		output := stderrOutput.String() + unparsed.String()
+		if code, ok := classifyOutput(output); ok {
+			return newTraceError(code, strings.TrimSpace(output), nil)
+		}
+	}
+
+	if onComplete != nil {
+		onComplete(hopCount)
+	}
+
+	return nil
+}
+
+// command returns the tracepath binary to run
+func (r *tracepathRunner) command() string {
+	if r.path != "" {
+		return r.path
+	}
+	return "tracepath"
+}
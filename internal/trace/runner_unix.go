@@ -7,27 +7,33 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"runtime"
+	"strconv"
 	"strings"
 
 	"packet-painter/internal/geo"
 )
 
 // unixRunner implements Runner for Linux and macOS
-type unixRunner struct{}
+type unixRunner struct {
+	opts Options
+	path string // Where traceroute was found; empty to search PATH
+}
 
-// newPlatformRunner returns a new Unix runner
-func newPlatformRunner() Runner {
-	return &unixRunner{}
+// platformBackends are the backends this platform can drive, best first
+var platformBackends = []platformBackend{
+	{BackendTraceroute, func(opts Options, path string) Runner { return &unixRunner{opts: opts, path: path} }},
+	{BackendTracepath, func(opts Options, path string) Runner { return &tracepathRunner{opts: opts, path: path} }},
+	{BackendMTR, func(opts Options, path string) Runner { return &mtrRunner{opts: opts, path: path} }},
 }
 
 // Run executes traceroute and streams hop results
 func (r *unixRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) error {
-	// Command: traceroute -n -q 1 -w 1 -m 30 <target>
-	// -n: No DNS lookup (just IPs)
-	// -q 1: Single probe per hop
-	// -w 1: 1 second timeout
-	// -m 30: Max 30 hops
-	cmd := exec.CommandContext(ctx, "traceroute", "-n", "-q", "1", "-w", "1", "-m", "30", target)
+	if err := checkTarget(target); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, r.command(), r.args(target)...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -40,12 +46,15 @@ func (r *unixRunner) Run(ctx context.Context, target string, geoLookup *geo.Look
 	}
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start traceroute: %w", err)
+		return startFailure("traceroute", err)
 	}
 
 	scanner := bufio.NewScanner(stdout)
 	var destinationIP string
 	var hopCount int
+	var terminal bool // Stopped the tool at an unreachable hop
+	// Lines that are not hops may explain a failure, e.g. tracert's resolver errors
+	var unparsed strings.Builder
 
 	for scanner.Scan() {
 		select {
@@ -74,6 +83,15 @@ func (r *unixRunner) Run(ctx context.Context, target string, geoLookup *geo.Look
 			if onHop != nil {
 				onHop(hop)
 			}
+			// Nothing answers beyond a router that reports the destination unreachable
+			if hop.Unreachable != "" {
+				terminal = true
+				cmd.Process.Kill()
+				break
+			}
+		} else if strings.TrimSpace(line) != "" {
+			unparsed.WriteString(line)
+			unparsed.WriteString("\n")
 		}
 	}
 
@@ -85,17 +103,20 @@ func (r *unixRunner) Run(ctx context.Context, target string, geoLookup *geo.Look
 		stderrOutput.WriteString("\n")
 	}
 
-	if err := cmd.Wait(); err != nil {
+	if err := cmd.Wait(); err != nil && !terminal {
 		// Check if it was cancelled
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		// Include stderr in error message if available
-		errMsg := err.Error()
-		if stderrOutput.Len() > 0 {
-			errMsg = fmt.Sprintf("%s: %s", errMsg, strings.TrimSpace(stderrOutput.String()))
+		// Classify the failure from what the tool printed
+		return toolFailure("traceroute", err, stderrOutput.String()+unparsed.String())
+	}
+
+	// Some tools exit cleanly after failing to resolve the target
+	if hopCount == 0 {
+		if code, ok := classifyOutput(unparsed.String()); ok {
+			return newTraceError(code, strings.TrimSpace(unparsed.String()), nil)
 		}
-		return fmt.Errorf("traceroute failed: %s", errMsg)
 	}
 
 	if onComplete != nil {
@@ -104,3 +125,38 @@ func (r *unixRunner) Run(ctx context.Context, target string, geoLookup *geo.Look
 
 	return nil
 }
+
+// args returns traceroute's arguments: traceroute -n -q 1 -w 1 -m 30 [-e] <target>
+// -n: No DNS lookup (just IPs)
+// -q: Probes per hop (default 1)
+// -w: Seconds to wait per probe (default 1)
+// -m: Max hops (default 30)
+// -e: Show ICMP extensions such as MPLS label stacks when opts.MPLS is set
+// (Linux only; BSD's -e means something else, busybox and inetutils reject it)
+// -A: Look up AS numbers when opts.ASLookup is set (-a on macOS)
+func (r *unixRunner) args(target string) []string {
+	args := []string{"-n",
+		"-q", strconv.Itoa(r.opts.ProbesPerHop),
+		"-w", strconv.Itoa(r.opts.WaitSeconds),
+		"-m", strconv.Itoa(r.opts.MaxHops),
+	}
+	if r.opts.MPLS && runtime.GOOS == "linux" {
+		args = append(args, "-e")
+	}
+	if r.opts.ASLookup {
+		if runtime.GOOS == "darwin" {
+			args = append(args, "-a")
+		} else {
+			args = append(args, "-A")
+		}
+	}
+	return append(args, target)
+}
+
+// command returns the traceroute binary to run
+func (r *unixRunner) command() string {
+	if r.path != "" {
+		return r.path
+	}
+	return "traceroute"
+}
@@ -7,24 +7,38 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"packet-painter/internal/geo"
 )
 
 // windowsRunner implements Runner for Windows
-type windowsRunner struct{}
+type windowsRunner struct {
+	opts Options
+	path string // Where tracert was found; empty to search PATH
+}
 
-// newPlatformRunner returns a new Windows runner
-func newPlatformRunner() Runner {
-	return &windowsRunner{}
+// platformBackends are the backends this platform can drive, best first
+var platformBackends = []platformBackend{
+	{BackendTracert, func(opts Options, path string) Runner { return &windowsRunner{opts: opts, path: path} }},
 }
 
 // Run executes tracert and streams hop results
 func (r *windowsRunner) Run(ctx context.Context, target string, geoLookup *geo.Lookup, onHop HopCallback, onComplete CompletedCallback, onError ErrorCallback) error {
-	// Command: tracert -d <target>
+	if err := checkTarget(target); err != nil {
+		return err
+	}
+
+	// Command: tracert -d -h 30 -w 1000 <target>
 	// -d: Do not resolve hostnames
-	cmd := exec.CommandContext(ctx, "tracert", "-d", target)
+	// -h: Max hops (default 30)
+	// -w: Milliseconds to wait per probe (default 1000)
+	// tracert always sends 3 probes per hop
+	cmd := exec.CommandContext(ctx, r.command(), "-d",
+		"-h", strconv.Itoa(r.opts.MaxHops),
+		"-w", strconv.Itoa(r.opts.WaitSeconds*1000),
+		target)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -37,12 +51,15 @@ func (r *windowsRunner) Run(ctx context.Context, target string, geoLookup *geo.L
 	}
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start tracert: %w", err)
+		return startFailure("tracert", err)
 	}
 
 	scanner := bufio.NewScanner(stdout)
 	var destinationIP string
 	var hopCount int
+	var terminal bool // Stopped the tool at an unreachable hop
+	// Lines that are not hops may explain a failure, e.g. tracert's resolver errors
+	var unparsed strings.Builder
 
 	for scanner.Scan() {
 		select {
@@ -79,6 +96,15 @@ func (r *windowsRunner) Run(ctx context.Context, target string, geoLookup *geo.L
 			if onHop != nil {
 				onHop(hop)
 			}
+			// Nothing answers beyond a router that reports the destination unreachable
+			if hop.Unreachable != "" {
+				terminal = true
+				cmd.Process.Kill()
+				break
+			}
+		} else if strings.TrimSpace(line) != "" {
+			unparsed.WriteString(line)
+			unparsed.WriteString("\n")
 		}
 	}
 
@@ -90,17 +116,20 @@ func (r *windowsRunner) Run(ctx context.Context, target string, geoLookup *geo.L
 		stderrOutput.WriteString("\n")
 	}
 
-	if err := cmd.Wait(); err != nil {
+	if err := cmd.Wait(); err != nil && !terminal {
 		// Check if it was cancelled
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		// Include stderr in error message if available
-		errMsg := err.Error()
-		if stderrOutput.Len() > 0 {
-			errMsg = fmt.Sprintf("%s: %s", errMsg, strings.TrimSpace(stderrOutput.String()))
+		// Classify the failure from what the tool printed
+		return toolFailure("tracert", err, stderrOutput.String()+unparsed.String())
+	}
+
+	// Some tools exit cleanly after failing to resolve the target
+	if hopCount == 0 {
+		if code, ok := classifyOutput(unparsed.String()); ok {
+			return newTraceError(code, strings.TrimSpace(unparsed.String()), nil)
 		}
-		return fmt.Errorf("tracert failed: %s", errMsg)
 	}
 
 	if onComplete != nil {
@@ -109,3 +138,11 @@ func (r *windowsRunner) Run(ctx context.Context, target string, geoLookup *geo.L
 
 	return nil
 }
+
+// command returns the tracert binary to run
+func (r *windowsRunner) command() string {
+	if r.path != "" {
+		return r.path
+	}
+	return "tracert"
+}
@@ -0,0 +1,138 @@
+package trace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tracepathLinePattern matches a numbered line of `tracepath -n` output
+// The TTL is followed by '?' while tracepath is unsure of it
+// Example: " 5:  10.8.0.1                                   20.104ms pmtu 1420"
+var tracepathLinePattern = regexp.MustCompile(`^\s*(\d+)\??:\s+(.*)$`)
+
+// tracepathPMTUPattern matches the path MTU on tracepath's summary line
+// Examples: "     Resume: pmtu 1420 hops 5 back 5", "     Too many hops: pmtu 1500"
+var tracepathPMTUPattern = regexp.MustCompile(`^\s*(?:Resume|Too many hops):\s+pmtu\s+(\d+)`)
+
+// tracepathParser turns `tracepath -n` lines into hops
+// tracepath prints a line per probe, so a TTL may appear several times, e.g.
+// once when a router asks for smaller packets and again once they fit. Lines
+// for the same TTL are merged, and a hop is complete once a later TTL begins
+type tracepathParser struct {
+	geoLookup GeoLookupFunc
+	pmtu      int // Path MTU discovered so far
+	pending   *Hop
+}
+
+// newTracepathParser returns a parser that geolocates hops with geoLookup
+func newTracepathParser(geoLookup GeoLookupFunc) *tracepathParser {
+	return &tracepathParser{geoLookup: geoLookup}
+}
+
+// parseLine applies one line of output and returns the previous hop if the
+// line began a new one. parsed is false for lines that are not tracepath's
+func (p *tracepathParser) parseLine(line string) (done *Hop, parsed bool) {
+	if matches := tracepathPMTUPattern.FindStringSubmatch(line); matches != nil {
+		p.pmtu, _ = strconv.Atoi(matches[1])
+		return nil, true
+	}
+	matches := tracepathLinePattern.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, false
+	}
+	hopNum, _ := strconv.Atoi(matches[1])
+	fields := strings.Fields(matches[2])
+	if hopNum == 0 || len(fields) == 0 {
+		return nil, false
+	}
+
+	if p.pending != nil && hopNum != p.pending.HopNumber {
+		done = p.complete()
+	}
+
+	// Local errors, e.g. the first hop's interface MTU, are not hops
+	if fields[0] == "[LOCALHOST]" {
+		p.applyAnnotations(fields[1:], nil)
+		return done, true
+	}
+
+	if p.pending == nil {
+		p.pending = &Hop{HopNumber: hopNum, IPAddress: "*", IsTimeout: true, Timestamp: time.Now().UnixMilli()}
+	}
+	hop := p.pending
+	if rest := strings.Join(fields, " "); rest == "no reply" || rest == "send failed" {
+		// Leave the hop timed out unless another probe answers
+		return done, true
+	}
+	// Keep the first router to answer if replies come from several
+	if hop.IsTimeout {
+		hop.IPAddress = fields[0]
+		hop.IsTimeout = false
+	}
+	p.applyAnnotations(fields[1:], hop)
+	return done, true
+}
+
+// applyAnnotations reads the RTT and notes after a hop's address
+// hop is nil for [LOCALHOST] lines, which only carry a pmtu
+func (p *tracepathParser) applyAnnotations(fields []string, hop *Hop) {
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case field == "pmtu" && i+1 < len(fields):
+			if mtu, err := strconv.Atoi(fields[i+1]); err == nil {
+				p.pmtu = mtu
+				i++
+			}
+		case hop == nil:
+			continue
+		case field == "reached":
+			hop.IsDestination = true
+		case strings.HasPrefix(field, "!"):
+			if reason, ok := parseUnreachableFlag(field); ok && hop.Unreachable == "" {
+				hop.Unreachable = reason
+			}
+		case field == "asymm" && i+1 < len(fields):
+			if back, err := strconv.Atoi(fields[i+1]); err == nil {
+				hop.ReturnHops = back
+				i++
+			}
+		case strings.HasSuffix(field, "ms"):
+			if rtt, err := strconv.ParseFloat(strings.TrimSuffix(field, "ms"), 64); err == nil {
+				hop.RTT = append(hop.RTT, rtt)
+			}
+		}
+	}
+}
+
+// unreachable reports whether the hop being read was reported unreachable
+func (p *tracepathParser) unreachable() bool {
+	return p.pending != nil && p.pending.Unreachable != ""
+}
+
+// complete finishes the pending hop with the path MTU known so far and clears it
+func (p *tracepathParser) complete() *Hop {
+	hop := p.pending
+	p.pending = nil
+	hop.MTU = p.pmtu
+	if len(hop.RTT) > 0 {
+		var sum float64
+		for _, rtt := range hop.RTT {
+			sum += rtt
+		}
+		hop.AvgRTT = sum / float64(len(hop.RTT))
+	}
+	enrichHop(hop, p.geoLookup)
+	return hop
+}
+
+// finish returns the last hop, if any, once tracepath has exited
+// The summary line may lower the path MTU after the last hop was printed
+func (p *tracepathParser) finish() *Hop {
+	if p.pending == nil {
+		return nil
+	}
+	return p.complete()
+}
@@ -0,0 +1,131 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+)
+
+// tracepathCapture is `tracepath -n 8.8.8.8` output through a VPN whose
+// tunnel lowers the path MTU at hop 2, with an asymmetric return at hop 4
+const tracepathCapture = ` 1?: [LOCALHOST]                      pmtu 1500
+ 1:  192.168.1.1                                           0.512ms
+ 1:  192.168.1.1                                           0.480ms
+ 2:  10.8.0.1                                              5.234ms pmtu 1420
+ 2:  10.8.0.1                                              5.301ms
+ 3:  no reply
+ 4:  72.14.215.85                                         12.345ms asymm  5
+ 5:  8.8.8.8                                              15.678ms reached
+     Resume: pmtu 1420 hops 5 back 5 `
+
+func TestTracepathParser(t *testing.T) {
+	parser := newTracepathParser(nil)
+	var hops []*Hop
+	for _, line := range strings.Split(tracepathCapture, "\n") {
+		hop, ok := parser.parseLine(line)
+		if !ok {
+			t.Fatalf("parseLine(%q) not parsed", line)
+		}
+		if hop != nil {
+			hops = append(hops, hop)
+		}
+	}
+	if len(hops) != 4 {
+		t.Fatalf("streamed %d hops before tracepath exited, want 4", len(hops))
+	}
+	if last := parser.finish(); last != nil {
+		hops = append(hops, last)
+	}
+
+	expected := []struct {
+		ip          string
+		rtts        int
+		mtu         int
+		returnHops  int
+		timeout     bool
+		destination bool
+	}{
+		{"192.168.1.1", 2, 1500, 0, false, false},
+		{"10.8.0.1", 2, 1420, 0, false, false},
+		{"*", 0, 1420, 0, true, false},
+		{"72.14.215.85", 1, 1420, 5, false, false},
+		{"8.8.8.8", 1, 1420, 0, false, true},
+	}
+	if len(hops) != len(expected) {
+		t.Fatalf("got %d hops, want %d", len(hops), len(expected))
+	}
+	for i, want := range expected {
+		hop := hops[i]
+		if hop.HopNumber != i+1 || hop.IPAddress != want.ip || len(hop.RTT) != want.rtts || hop.IsTimeout != want.timeout || hop.IsDestination != want.destination {
+			t.Errorf("hop %d = %+v", i+1, hop)
+		}
+		if hop.MTU != want.mtu || hop.ReturnHops != want.returnHops {
+			t.Errorf("hop %d MTU = %d ReturnHops = %d, want %d and %d", i+1, hop.MTU, hop.ReturnHops, want.mtu, want.returnHops)
+		}
+	}
+	if hops[0].AvgRTT != 0.496 {
+		t.Errorf("hop 1 AvgRTT = %f, want 0.496", hops[0].AvgRTT)
+	}
+
+	result := &Result{Hops: hops}
+	if mtu := result.PathMTU(); mtu != 1420 {
+		t.Errorf("PathMTU() = %d, want 1420", mtu)
+	}
+}
+
+func TestTracepathParserLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		hops   int
+		mtu    int // MTU of the last hop
+		parsed bool
+	}{
+		{
+			name:   "summary lowers the last hop's MTU",
+			lines:  []string{" 1?: [LOCALHOST]     pmtu 1500", " 1:  192.168.1.1    0.5ms", "     Too many hops: pmtu 1400"},
+			hops:   1,
+			mtu:    1400,
+			parsed: true,
+		},
+		{
+			name:   "reply after no reply",
+			lines:  []string{" 1:  no reply", " 1:  192.168.1.1    0.5ms"},
+			hops:   1,
+			parsed: true,
+		},
+		{
+			name:   "unknown MTU",
+			lines:  []string{" 1:  send failed"},
+			hops:   1,
+			parsed: true,
+		},
+		{
+			name:  "not tracepath",
+			lines: []string{"tracepath: 8.8.8.8: Name or service not known"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := newTracepathParser(nil)
+			parsed := true
+			var hops []*Hop
+			for _, line := range tt.lines {
+				hop, ok := parser.parseLine(line)
+				parsed = parsed && ok
+				if hop != nil {
+					hops = append(hops, hop)
+				}
+			}
+			if last := parser.finish(); last != nil {
+				hops = append(hops, last)
+			}
+			if parsed != tt.parsed || len(hops) != tt.hops {
+				t.Fatalf("parsed = %v with %d hops, want %v with %d", parsed, len(hops), tt.parsed, tt.hops)
+			}
+			if len(hops) > 0 && hops[len(hops)-1].MTU != tt.mtu {
+				t.Errorf("MTU = %d, want %d", hops[len(hops)-1].MTU, tt.mtu)
+			}
+		})
+	}
+}
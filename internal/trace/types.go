@@ -17,27 +17,77 @@ type Hop struct {
 	IsTimeout     bool                   `json:"isTimeout"`
 	IsDestination bool                   `json:"isDestination"`
 	Timestamp     int64                  `json:"timestamp"`
+	Stats         *ProbeStats            `json:"stats,omitempty"`
+	MTU           int                    `json:"mtu,omitempty"`          // Path MTU in effect at this hop (tracepath)
+	ReturnHops    int                    `json:"returnHops,omitempty"`   // Hops the reply took back when it differs from the way out (tracepath's asymm)
+	Unreachable   Unreachable            `json:"unreachable,omitempty"`  // Set when the router reported the destination unreachable
+	MPLS          []MPLSLabel            `json:"mpls,omitempty"`         // Label stack the router quoted, top first (traceroute -e)
+	ASN           string                 `json:"asn,omitempty"`          // AS announcing the hop's address, e.g. "AS15169"
+	ASNConflicts  []ASNClaim             `json:"asnConflicts,omitempty"` // AS numbers other sources or routers gave that differ from ASN
+}
+
+// ProbeStats holds aggregate statistics for tools that probe a hop repeatedly (mtr, pathping)
+type ProbeStats struct {
+	Sent        int     `json:"sent"`
+	Received    int     `json:"received"`
+	LossPercent float64 `json:"lossPercent"`
+	Last        float64 `json:"last"`
+	Avg         float64 `json:"avg"`
+	Best        float64 `json:"best"`
+	Worst       float64 `json:"worst"`
+	StdDev      float64 `json:"stdDev"`
+}
+
+// LossRatio returns the share of probes to the hop that went unanswered, from 0 to 1
+// probesPerHop is how many probes were sent when the hop has no ProbeStats
+func (h *Hop) LossRatio(probesPerHop int) float64 {
+	if h.Stats != nil {
+		return h.Stats.LossPercent / 100
+	}
+	if h.IsTimeout {
+		return 1
+	}
+	if probesPerHop < len(h.RTT) {
+		probesPerHop = len(h.RTT)
+	}
+	if probesPerHop == 0 {
+		return 0
+	}
+	return float64(probesPerHop-len(h.RTT)) / float64(probesPerHop)
 }
 
 // TraceStartedEvent is emitted when a trace begins
 type TraceStartedEvent struct {
 	SessionID string        `json:"sessionId"`
 	Target    string        `json:"target"`
+	Options   Options       `json:"options"`
 	Source    *geo.Location `json:"source"`
 	Timestamp int64         `json:"timestamp"`
 }
 
+// TraceQueuedEvent is emitted when a trace waits for a free slot under the concurrency limit
+type TraceQueuedEvent struct {
+	SessionID string `json:"sessionId"`
+	Target    string `json:"target"`
+	Position  int    `json:"position"` // 1-based place in the queue
+	Timestamp int64  `json:"timestamp"`
+}
+
 // TraceHopEvent is emitted for each hop discovered
 type TraceHopEvent struct {
 	SessionID string `json:"sessionId"`
 	Hop       *Hop   `json:"hop"`
 }
 
-// TraceCompletedEvent is emitted when a trace finishes successfully
+// TraceCompletedEvent is emitted when a trace finishes, including one cut short by its deadline policy
 type TraceCompletedEvent struct {
-	SessionID string `json:"sessionId"`
-	TotalHops int    `json:"totalHops"`
-	Timestamp int64  `json:"timestamp"`
+	SessionID string       `json:"sessionId"`
+	TotalHops int          `json:"totalHops"`
+	Outcome   PathOutcome  `json:"outcome"`
+	Reason    StopReason   `json:"reason"`
+	PathMTU   int          `json:"pathMtu,omitempty"`     // Smallest MTU on the path, for backends that discover it
+	Tunnels   []MPLSTunnel `json:"mplsTunnels,omitempty"` // MPLS tunnels detected along the path
+	Timestamp int64        `json:"timestamp"`
 }
 
 // TraceCancelledEvent is emitted when a trace is cancelled
@@ -48,7 +98,34 @@ type TraceCancelledEvent struct {
 
 // TraceErrorEvent is emitted when a trace encounters an error
 type TraceErrorEvent struct {
-	SessionID string `json:"sessionId"`
-	Error     string `json:"error"`
-	Timestamp int64  `json:"timestamp"`
+	SessionID   string    `json:"sessionId"`
+	Error       string    `json:"error"`
+	Code        ErrorCode `json:"code"`
+	Remediation string    `json:"remediation,omitempty"`
+	Timestamp   int64     `json:"timestamp"`
 }
+
+// Event names, as used by the Wails runtime and the API event stream
+const (
+	EventQueued    = "trace:queued"
+	EventStarted   = "trace:started"
+	EventHop       = "trace:hop"
+	EventCompleted = "trace:completed"
+	EventCancelled = "trace:cancelled"
+	EventError     = "trace:error"
+)
+
+// EventName and EventSessionID identify each trace event on the event bus
+
+func (e TraceStartedEvent) EventName() string        { return EventStarted }
+func (e TraceStartedEvent) EventSessionID() string   { return e.SessionID }
+func (e TraceQueuedEvent) EventName() string         { return EventQueued }
+func (e TraceQueuedEvent) EventSessionID() string    { return e.SessionID }
+func (e TraceHopEvent) EventName() string            { return EventHop }
+func (e TraceHopEvent) EventSessionID() string       { return e.SessionID }
+func (e TraceCompletedEvent) EventName() string      { return EventCompleted }
+func (e TraceCompletedEvent) EventSessionID() string { return e.SessionID }
+func (e TraceCancelledEvent) EventName() string      { return EventCancelled }
+func (e TraceCancelledEvent) EventSessionID() string { return e.SessionID }
+func (e TraceErrorEvent) EventName() string          { return EventError }
+func (e TraceErrorEvent) EventSessionID() string     { return e.SessionID }
@@ -0,0 +1,94 @@
+package trace
+
+import (
+	"strings"
+)
+
+// Unreachable is why a router reported that the destination cannot be reached
+// Hops carrying one end the trace, as nothing beyond them will answer
+type Unreachable string
+
+const (
+	UnreachableHost              Unreachable = "host_unreachable"     // !H, ICMP code 1
+	UnreachableNetwork           Unreachable = "network_unreachable"  // !N, ICMP code 0
+	UnreachableProtocol          Unreachable = "protocol_unreachable" // !P, ICMP code 2
+	UnreachableProhibited        Unreachable = "admin_prohibited"     // !X, ICMP code 13
+	UnreachableNetworkProhibited Unreachable = "network_prohibited"   // !A, ICMP code 9
+	UnreachableHostProhibited    Unreachable = "host_prohibited"      // !Z, ICMP code 10
+	UnreachableOther             Unreachable = "unreachable"          // Any other code, e.g. !S or !<n>
+)
+
+// unreachableFlags maps traceroute and tracepath annotations to reasons
+var unreachableFlags = map[string]Unreachable{
+	"!H": UnreachableHost,
+	"!N": UnreachableNetwork,
+	"!P": UnreachableProtocol,
+	"!X": UnreachableProhibited,
+	"!A": UnreachableNetworkProhibited,
+	"!Z": UnreachableHostProhibited,
+}
+
+// Prohibited reports whether a filter, rather than a missing route, stopped the probes
+func (u Unreachable) Prohibited() bool {
+	return u == UnreachableProhibited || u == UnreachableNetworkProhibited || u == UnreachableHostProhibited
+}
+
+// Description explains the reason for people
+func (u Unreachable) Description() string {
+	switch u {
+	case UnreachableHost:
+		return "host unreachable"
+	case UnreachableNetwork:
+		return "network unreachable"
+	case UnreachableProtocol:
+		return "protocol unreachable"
+	case UnreachableProhibited:
+		return "communication administratively prohibited"
+	case UnreachableNetworkProhibited:
+		return "network administratively prohibited"
+	case UnreachableHostProhibited:
+		return "host administratively prohibited"
+	default:
+		return "destination unreachable"
+	}
+}
+
+// parseUnreachableFlag reads a traceroute annotation such as "!H" or "!<10>"
+// Returns false for fields that are not annotations
+func parseUnreachableFlag(field string) (Unreachable, bool) {
+	if len(field) < 2 || field[0] != '!' {
+		return "", false
+	}
+	if reason, ok := unreachableFlags[field]; ok {
+		return reason, true
+	}
+	return UnreachableOther, true
+}
+
+// windowsUnreachableMessages map the text tracert prints after "reports:"
+// Example: "  4    22 ms    21 ms    20 ms  10.1.1.1 reports: Destination net unreachable."
+var windowsUnreachableMessages = []struct {
+	message string
+	reason  Unreachable
+}{
+	{"destination host unreachable", UnreachableHost},
+	{"destination net unreachable", UnreachableNetwork},
+	{"destination protocol unreachable", UnreachableProtocol},
+	{"administratively prohibited", UnreachableProhibited},
+	{"unreachable", UnreachableOther},
+}
+
+// parseWindowsUnreachable returns the reason in a tracert "reports:" line, if any
+func parseWindowsUnreachable(line string) (Unreachable, bool) {
+	lower := strings.ToLower(line)
+	i := strings.Index(lower, "reports:")
+	if i < 0 {
+		return "", false
+	}
+	for _, m := range windowsUnreachableMessages {
+		if strings.Contains(lower[i:], m.message) {
+			return m.reason, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,78 @@
+package trace
+
+import (
+	"testing"
+)
+
+func TestParseUnreachableHops(t *testing.T) {
+	tests := []struct {
+		name        string
+		windows     bool
+		input       string
+		ip          string
+		rtts        int
+		unreachable Unreachable
+	}{
+		{"host unreachable", false, " 8  10.0.0.1  5.1 ms !H  5.2 ms !H  5.3 ms !H", "10.0.0.1", 3, UnreachableHost},
+		{"administratively prohibited", false, " 8  10.0.0.1  5.1 ms !X  5.2 ms !X", "10.0.0.1", 2, UnreachableProhibited},
+		{"first annotation wins", false, " 8  10.0.0.1  5.1 ms !N  5.2 ms !H", "10.0.0.1", 2, UnreachableNetwork},
+		{"numeric code", false, " 8  10.0.0.1  5.1 ms !<10>", "10.0.0.1", 1, UnreachableOther},
+		{"unannotated", false, " 8  10.0.0.1  5.1 ms  5.2 ms", "10.0.0.1", 2, ""},
+		{"tracert net unreachable", true, "  4    22 ms    21 ms    20 ms  10.1.1.1 reports: Destination net unreachable.", "10.1.1.1", 3, UnreachableNetwork},
+		{"tracert host unreachable", true, "  5  10.0.0.1  reports: Destination host unreachable.", "10.0.0.1", 0, UnreachableHost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hop *Hop
+			if tt.windows {
+				hop = parseWindowsHopLine(tt.input, "8.8.8.8", nil)
+			} else {
+				hop = parseUnixHopLine(tt.input, "8.8.8.8", nil)
+			}
+			if hop == nil {
+				t.Fatalf("%q not parsed", tt.input)
+			}
+			if hop.IPAddress != tt.ip || len(hop.RTT) != tt.rtts || hop.Unreachable != tt.unreachable {
+				t.Errorf("hop = %+v, want %s with %d RTTs, unreachable %q", hop, tt.ip, tt.rtts, tt.unreachable)
+			}
+		})
+	}
+}
+
+func TestTracepathUnreachable(t *testing.T) {
+	parser := newTracepathParser(nil)
+	for _, line := range []string{" 1:  192.168.1.1    0.5ms", " 2:  10.0.0.1    5.1ms !H"} {
+		parser.parseLine(line)
+	}
+	if !parser.unreachable() {
+		t.Fatal("unreachable() = false after !H")
+	}
+	if hop := parser.finish(); hop.Unreachable != UnreachableHost {
+		t.Errorf("Unreachable = %q, want %q", hop.Unreachable, UnreachableHost)
+	}
+}
+
+func TestUnreachablePath(t *testing.T) {
+	router := &Hop{HopNumber: 1, IPAddress: "192.168.1.1"}
+	prohibited := &Hop{HopNumber: 2, IPAddress: "10.0.0.1", Unreachable: UnreachableProhibited}
+	noRoute := &Hop{HopNumber: 2, IPAddress: "10.0.0.1", Unreachable: UnreachableNetwork}
+
+	tests := []struct {
+		name    string
+		hops    []*Hop
+		outcome PathOutcome
+	}{
+		{"prohibited", []*Hop{router, prohibited}, PathProhibited},
+		{"no route", []*Hop{router, noRoute}, PathUnreachable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome, reason := (&Result{Hops: tt.hops}).Path()
+			if outcome != tt.outcome || reason != ReasonUnreachable {
+				t.Errorf("Path() = %s, %s; want %s, %s", outcome, reason, tt.outcome, ReasonUnreachable)
+			}
+		})
+	}
+}
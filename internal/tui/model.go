@@ -0,0 +1,420 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"packet-painter/internal/trace"
+)
+
+// Minimum terminal size the layout is designed for
+const (
+	MinWidth  = 80
+	MinHeight = 24
+)
+
+// ANSI escape sequences used by the renderer
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiReverse = "\x1b[7m"
+	ansiLand    = "\x1b[32m"
+	ansiPath    = "\x1b[36m"
+	ansiDim     = "\x1b[90m"
+)
+
+// state describes where the current trace is
+type state int
+
+const (
+	stateRunning state = iota
+	stateCompleted
+	stateCancelled
+	stateFailed
+)
+
+// model holds everything the screen shows
+type model struct {
+	target    string
+	opts      trace.Options
+	hops      []*trace.Hop
+	selected  int // Index into hops, -1 when nothing is selected
+	follow    bool
+	state     state
+	err       string
+	startedAt time.Time
+	endedAt   time.Time
+}
+
+// newModel creates a model for a trace that is about to start
+func newModel(target string, opts trace.Options, now time.Time) *model {
+	return &model{
+		target:    target,
+		opts:      opts.WithDefaults(),
+		selected:  -1,
+		follow:    true,
+		state:     stateRunning,
+		startedAt: now,
+	}
+}
+
+// addHop records a hop and keeps the selection on the newest hop unless the user moved it
+func (m *model) addHop(hop *trace.Hop) {
+	m.hops = append(m.hops, hop)
+	if m.follow {
+		m.selected = len(m.hops) - 1
+	}
+}
+
+// finish records how the trace ended
+func (m *model) finish(s state, errMsg string, now time.Time) {
+	m.state = s
+	m.err = errMsg
+	m.endedAt = now
+}
+
+// moveSelection moves the selected hop up or down, following new hops again at the bottom
+func (m *model) moveSelection(delta int) {
+	if len(m.hops) == 0 {
+		return
+	}
+	m.selected = clamp(m.selected+delta, 0, len(m.hops)-1)
+	m.follow = m.selected == len(m.hops)-1
+}
+
+// reached reports whether the destination has answered
+func (m *model) reached() bool {
+	for _, hop := range m.hops {
+		if hop.IsDestination {
+			return true
+		}
+	}
+	return false
+}
+
+// render draws the full screen as lines, each at most width visible columns
+func (m *model) render(width, height int, now time.Time) []string {
+	if width < MinWidth || height < MinHeight {
+		lines := make([]string, height)
+		if height > 0 {
+			lines[0] = truncate(fmt.Sprintf("Terminal is %dx%d, need at least %dx%d", width, height, MinWidth, MinHeight), width)
+		}
+		return lines
+	}
+
+	tableRows := (height - 4) * 2 / 5
+	if tableRows < 6 {
+		tableRows = 6
+	}
+	mapRows := height - 4 - tableRows
+
+	lines := make([]string, 0, height)
+	lines = append(lines, m.renderTitle(width, now))
+	lines = append(lines, m.renderMap(width, mapRows)...)
+	lines = append(lines, m.renderTable(width, tableRows)...)
+	lines = append(lines, m.renderDetails(width)...)
+	lines = append(lines, ansiDim+truncate("up/down select   r restart   c cancel   q quit", width)+ansiReset)
+	return lines
+}
+
+// renderTitle draws the header with the target and trace status
+func (m *model) renderTitle(width int, now time.Time) string {
+	end := now
+	if m.state != stateRunning {
+		end = m.endedAt
+	}
+	elapsed := end.Sub(m.startedAt).Round(100 * time.Millisecond)
+
+	var status string
+	switch m.state {
+	case stateRunning:
+		status = fmt.Sprintf("tracing... hop %d/%d", len(m.hops), m.opts.MaxHops)
+	case stateCompleted:
+		if m.reached() {
+			status = fmt.Sprintf("reached in %d hops", len(m.hops))
+		} else {
+			status = fmt.Sprintf("not reached after %d hops", len(m.hops))
+		}
+	case stateCancelled:
+		status = "cancelled"
+	case stateFailed:
+		status = "error: " + m.err
+	}
+
+	title := fmt.Sprintf(" packet-painter  %s  |  %s  |  %s", m.target, status, elapsed)
+	return ansiReverse + pad(truncate(title, width), width) + ansiReset
+}
+
+// renderMap draws land, the path between located hops and the hop markers
+func (m *model) renderMap(width, height int) []string {
+	mask := landMask(width, height)
+	cells := make([][]rune, height)
+	colors := make([][]string, height)
+	for y := range cells {
+		cells[y] = make([]rune, width)
+		colors[y] = make([]string, width)
+		for x := range cells[y] {
+			if mask[y][x] {
+				cells[y][x], colors[y][x] = '.', ansiLand
+			} else {
+				cells[y][x] = ' '
+			}
+		}
+	}
+
+	// Path first so markers draw on top
+	prevX, prevY, havePrev := 0, 0, false
+	for _, hop := range m.hops {
+		if hop.Location == nil {
+			continue
+		}
+		x, y := project(hop.Location.Latitude, hop.Location.Longitude, width, height)
+		if havePrev {
+			drawLine(prevX, prevY, x, y, func(px, py int) {
+				cells[py][px], colors[py][px] = '*', ansiPath
+			})
+		}
+		prevX, prevY, havePrev = x, y, true
+	}
+
+	for i, hop := range m.hops {
+		if hop.Location == nil {
+			continue
+		}
+		x, y := project(hop.Location.Latitude, hop.Location.Longitude, width, height)
+		marker := 'o'
+		if hop.IsDestination {
+			marker = 'X'
+		}
+		color := ansiBold + latencyColor(hop)
+		if i == m.selected {
+			marker = '@'
+			color = ansiReverse + color
+		}
+		cells[y][x], colors[y][x] = marker, color
+	}
+
+	lines := make([]string, height)
+	for y := range cells {
+		var b strings.Builder
+		current := ""
+		for x, r := range cells[y] {
+			if colors[y][x] != current {
+				b.WriteString(ansiReset + colors[y][x])
+				current = colors[y][x]
+			}
+			b.WriteRune(r)
+		}
+		b.WriteString(ansiReset)
+		lines[y] = b.String()
+	}
+	return lines
+}
+
+// renderTable draws the hop table, scrolled to keep the selection visible
+func (m *model) renderTable(width, rows int) []string {
+	lines := make([]string, 0, rows)
+	header := fmt.Sprintf("%3s  %-20s %9s %5s  %-22s %s", "#", "Address", "RTT", "Loss", "Location", "Provider")
+	lines = append(lines, ansiBold+pad(truncate(header, width), width)+ansiReset)
+
+	visible := rows - 1
+	first := 0
+	if m.selected >= visible {
+		first = m.selected - visible + 1
+	}
+	for i := first; i < len(m.hops) && i < first+visible; i++ {
+		hop := m.hops[i]
+		line := pad(truncate(m.tableRow(hop), width), width)
+		switch {
+		case i == m.selected:
+			line = ansiReverse + line + ansiReset
+		case hop.IsTimeout:
+			line = ansiDim + line + ansiReset
+		default:
+			line = latencyColor(hop) + line + ansiReset
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < rows {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// tableRow formats the columns for one hop
+func (m *model) tableRow(hop *trace.Hop) string {
+	if hop.IsTimeout {
+		return fmt.Sprintf("%3d  %-20s %9s %5s", hop.HopNumber, "*", "-", "100%")
+	}
+	rtt := fmt.Sprintf("%.1f ms", hop.AvgRTT)
+	return fmt.Sprintf("%3d  %-20s %9s %5s  %-22s %s",
+		hop.HopNumber, truncate(hop.IPAddress, 20), rtt, fmt.Sprintf("%.0f%%", m.loss(hop)),
+		truncate(placeName(hop), 22), provider(hop))
+}
+
+// renderDetails draws two lines describing the selected hop
+func (m *model) renderDetails(width int) []string {
+	if m.selected < 0 || m.selected >= len(m.hops) {
+		return []string{"", ""}
+	}
+	hop := m.hops[m.selected]
+
+	first := fmt.Sprintf("Hop %d  %s", hop.HopNumber, hop.IPAddress)
+	if hop.Hostname != "" {
+		first += "  " + hop.Hostname
+	}
+	if hop.IsDestination {
+		first += "  (destination)"
+	}
+
+	var parts []string
+	if !hop.IsTimeout {
+		rtts := make([]string, len(hop.RTT))
+		for i, rtt := range hop.RTT {
+			rtts[i] = fmt.Sprintf("%.1f", rtt)
+		}
+		if len(rtts) > 0 {
+			parts = append(parts, "RTT "+strings.Join(rtts, "/")+" ms")
+		}
+		parts = append(parts, fmt.Sprintf("loss %.0f%%", m.loss(hop)))
+	} else {
+		parts = append(parts, "no reply")
+	}
+	if loc := hop.Location; loc != nil {
+		var place []string
+		for _, s := range []string{loc.City, loc.Region, loc.Country} {
+			if s != "" {
+				place = append(place, s)
+			}
+		}
+		if len(place) > 0 {
+			parts = append(parts, strings.Join(place, ", "))
+		}
+		if loc.Org != "" {
+			parts = append(parts, loc.Org)
+		} else if loc.ISP != "" {
+			parts = append(parts, loc.ISP)
+		}
+	}
+	if hop.ASN != "" {
+		parts = append(parts, hop.ASN)
+	}
+	if hop.DataCenter != nil {
+		parts = append(parts, "["+hop.DataCenter.Provider+"]")
+	}
+
+	return []string{
+		ansiBold + truncate(first, width) + ansiReset,
+		truncate(strings.Join(parts, "  "), width),
+	}
+}
+
+// loss returns the percentage of probes to a hop that went unanswered
+func (m *model) loss(hop *trace.Hop) float64 {
+	return hop.LossRatio(m.opts.ProbesPerHop) * 100
+}
+
+// placeName returns "City, CC" for a located hop
+func placeName(hop *trace.Hop) string {
+	if hop.Location == nil {
+		return ""
+	}
+	var parts []string
+	if hop.Location.City != "" {
+		parts = append(parts, hop.Location.City)
+	}
+	if hop.Location.CountryCode != "" {
+		parts = append(parts, hop.Location.CountryCode)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// provider names the network operating a hop
+func provider(hop *trace.Hop) string {
+	if hop.DataCenter != nil {
+		return hop.DataCenter.Provider
+	}
+	if hop.Location != nil {
+		if hop.Location.Org != "" {
+			return hop.Location.Org
+		}
+		return hop.Location.ISP
+	}
+	return ""
+}
+
+// latencyColor returns the ANSI color for a hop using the same thresholds as the globe
+func latencyColor(hop *trace.Hop) string {
+	switch {
+	case hop.IsTimeout:
+		return ansiDim
+	case hop.AvgRTT < 50:
+		return "\x1b[32m" // Green
+	case hop.AvgRTT < 100:
+		return "\x1b[92m" // Bright green
+	case hop.AvgRTT < 150:
+		return "\x1b[33m" // Yellow
+	case hop.AvgRTT < 200:
+		return "\x1b[91m" // Bright red
+	default:
+		return "\x1b[31m" // Red
+	}
+}
+
+// drawLine plots the cells between two points with Bresenham's algorithm, excluding the endpoints
+func drawLine(x0, y0, x1, y1 int, plot func(x, y int)) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	x, y := x0, y0
+	errTerm := dx + dy
+	for x != x1 || y != y1 {
+		if x != x0 || y != y0 {
+			plot(x, y)
+		}
+		e2 := 2 * errTerm
+		if e2 >= dy {
+			errTerm += dy
+			x += sx
+		}
+		if e2 <= dx {
+			errTerm += dx
+			y += sy
+		}
+	}
+}
+
+// abs returns the absolute value of v
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// truncate shortens s to at most width characters
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	if width <= 1 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-1]) + "~"
+}
+
+// pad right-fills s with spaces to width characters
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
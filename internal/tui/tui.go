@@ -0,0 +1,243 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"packet-painter/internal/trace"
+)
+
+// SessionFactory creates the session for each (re)started trace
+type SessionFactory func(target string, opts trace.Options) *trace.Session
+
+// key is a decoded keypress
+type key int
+
+const (
+	keyNone key = iota
+	keyUp
+	keyDown
+	keyRestart
+	keyCancel
+	keyQuit
+)
+
+// hopMsg and doneMsg carry session callbacks to the UI loop, tagged with the run they belong to
+type hopMsg struct {
+	run int
+	hop *trace.Hop
+}
+
+type doneMsg struct {
+	run    int
+	result *trace.Result
+	err    error
+}
+
+// Run shows the full-screen UI until the user quits or ctx is cancelled
+// in must be a terminal; it is switched to raw mode and restored on return
+func Run(ctx context.Context, target string, opts trace.Options, newSession SessionFactory, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("tui needs an interactive terminal, use `packet-painter trace` for scripts")
+	}
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	defer term.Restore(fd, oldState)
+
+	w := bufio.NewWriter(out)
+	// Alternate screen, hidden cursor
+	w.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		w.WriteString(ansiReset + "\x1b[?25h\x1b[?1049l")
+		w.Flush()
+	}()
+
+	quit := make(chan struct{})
+	defer close(quit)
+
+	keys := make(chan key)
+	go readKeys(in, keys, quit)
+
+	messages := make(chan interface{})
+	run := 0
+	var cancelRun context.CancelFunc
+	start := func() *model {
+		if cancelRun != nil {
+			cancelRun()
+		}
+		run++
+		var runCtx context.Context
+		runCtx, cancelRun = context.WithCancel(ctx)
+		go runSession(runCtx, run, newSession(target, opts), messages, quit)
+		return newModel(target, opts, time.Now())
+	}
+	m := start()
+	defer func() { cancelRun() }()
+
+	// Redraw periodically for the elapsed clock and terminal resizes
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = MinWidth, MinHeight
+		}
+		draw(w, m.render(width, height, time.Now()), width)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case k := <-keys:
+			switch k {
+			case keyQuit:
+				return nil
+			case keyUp:
+				m.moveSelection(-1)
+			case keyDown:
+				m.moveSelection(1)
+			case keyRestart:
+				m = start()
+			case keyCancel:
+				if m.state == stateRunning {
+					cancelRun()
+					m.finish(stateCancelled, "", time.Now())
+				}
+			}
+		case msg := <-messages:
+			switch msg := msg.(type) {
+			case hopMsg:
+				if msg.run == run && m.state == stateRunning {
+					m.addHop(msg.hop)
+				}
+			case doneMsg:
+				if msg.run == run && m.state == stateRunning {
+					m.finish(finalState(msg.result), errorText(msg.err), time.Now())
+				}
+			}
+		}
+	}
+}
+
+// runSession runs one trace, forwarding its callbacks to the UI loop until quit closes
+func runSession(ctx context.Context, run int, session *trace.Session, messages chan<- interface{}, quit <-chan struct{}) {
+	send := func(msg interface{}) {
+		select {
+		case messages <- msg:
+		case <-quit:
+		}
+	}
+	result, err := session.Run(ctx, func(hop *trace.Hop) {
+		send(hopMsg{run: run, hop: hop})
+	})
+	send(doneMsg{run: run, result: result, err: err})
+}
+
+// finalState maps a session result to the state shown in the title
+func finalState(result *trace.Result) state {
+	switch result.Outcome {
+	case trace.OutcomeCompleted:
+		return stateCompleted
+	case trace.OutcomeCancelled:
+		return stateCancelled
+	default:
+		return stateFailed
+	}
+}
+
+// errorText returns the message for err, or "" when there is none
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// readKeys decodes keypresses from the raw terminal until quit closes
+func readKeys(in io.Reader, keys chan<- key, quit <-chan struct{}) {
+	buf := make([]byte, 16)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		k := decodeKey(buf[:n])
+		if k == keyNone {
+			continue
+		}
+		select {
+		case keys <- k:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// decodeKey maps raw terminal input to a key
+func decodeKey(b []byte) key {
+	switch string(b) {
+	case "\x1b[A", "\x1bOA", "k":
+		return keyUp
+	case "\x1b[B", "\x1bOB", "j":
+		return keyDown
+	case "r", "R":
+		return keyRestart
+	case "c", "C", "\x1b":
+		return keyCancel
+	case "q", "Q", "\x03", "\x04": // Ctrl+C and Ctrl+D too, since raw mode swallows signals
+		return keyQuit
+	}
+	return keyNone
+}
+
+// draw repaints the screen from the top-left corner
+func draw(w *bufio.Writer, lines []string, width int) {
+	w.WriteString("\x1b[H")
+	for i, line := range lines {
+		w.WriteString(line)
+		// Clearing after a full-width line would erase its last column
+		if visibleWidth(line) < width {
+			w.WriteString("\x1b[K")
+		}
+		if i < len(lines)-1 {
+			w.WriteString("\r\n")
+		}
+	}
+	w.Flush()
+}
+
+// visibleWidth counts the characters in s that occupy a terminal column
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(stripANSI(s))
+}
+
+// stripANSI removes escape sequences, leaving the visible text
+func stripANSI(s string) string {
+	var b strings.Builder
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case inEscape:
+			if r >= '@' && r <= '~' && r != '[' {
+				inEscape = false
+			}
+		case r == '\x1b':
+			inEscape = true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
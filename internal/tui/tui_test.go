@@ -0,0 +1,124 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"packet-painter/internal/geo"
+	"packet-painter/internal/trace"
+)
+
+func testHops() []*trace.Hop {
+	return []*trace.Hop{
+		{HopNumber: 1, IPAddress: "192.168.1.1", RTT: []float64{0.5, 0.6}, AvgRTT: 0.55},
+		{HopNumber: 2, IPAddress: "*", IsTimeout: true},
+		{HopNumber: 3, IPAddress: "4.69.0.1", RTT: []float64{70}, AvgRTT: 70,
+			Location: &geo.Location{Latitude: 40.7, Longitude: -74, City: "New York", CountryCode: "US", Org: "Lumen"}},
+		{HopNumber: 4, IPAddress: "142.250.80.46", Hostname: "lga34s34-in-f14.1e100.net", RTT: []float64{80, 81}, AvgRTT: 80.5, IsDestination: true, ASN: "AS15169",
+			Location: &geo.Location{Latitude: 51.5, Longitude: -0.1, City: "London", CountryCode: "GB", Org: "Google LLC"}},
+	}
+}
+
+func TestRenderFitsTerminal(t *testing.T) {
+	now := time.Now()
+	m := newModel("google.com", trace.Options{ProbesPerHop: 2}, now)
+	for _, hop := range testHops() {
+		m.addHop(hop)
+	}
+	m.finish(stateCompleted, "", now.Add(time.Second))
+
+	sizes := [][2]int{{80, 24}, {120, 40}, {200, 60}}
+	for _, size := range sizes {
+		width, height := size[0], size[1]
+		lines := m.render(width, height, now)
+		if len(lines) != height {
+			t.Fatalf("%dx%d: got %d lines, want %d", width, height, len(lines), height)
+		}
+		for i, line := range lines {
+			if w := visibleWidth(line); w > width {
+				t.Errorf("%dx%d: line %d is %d columns wide", width, height, i, w)
+			}
+		}
+
+		screen := stripANSI(strings.Join(lines, "\n"))
+		for _, want := range []string{"reached in 4 hops", "New York, US", "Lumen", "50%", "AS15169", "(destination)"} {
+			if !strings.Contains(screen, want) {
+				t.Errorf("%dx%d: screen does not contain %q", width, height, want)
+			}
+		}
+		// Selected destination and the other located hop are plotted
+		if !strings.Contains(screen, "@") || !strings.Contains(screen, "o") {
+			t.Errorf("%dx%d: hop markers missing from map", width, height)
+		}
+	}
+
+	small := m.render(60, 20, now)
+	if len(small) != 20 || !strings.Contains(small[0], "need at least 80x24") {
+		t.Errorf("small terminal render = %q", small[0])
+	}
+}
+
+func TestSelectionFollowsNewHops(t *testing.T) {
+	m := newModel("google.com", trace.DefaultOptions(), time.Now())
+	hops := testHops()
+	m.addHop(hops[0])
+	m.addHop(hops[1])
+	if m.selected != 1 {
+		t.Fatalf("selected = %d, want newest hop", m.selected)
+	}
+
+	m.moveSelection(-1)
+	m.addHop(hops[2])
+	if m.selected != 0 {
+		t.Errorf("selected = %d, want selection kept at 0 after moving up", m.selected)
+	}
+
+	m.moveSelection(5)
+	m.addHop(hops[3])
+	if m.selected != 3 {
+		t.Errorf("selected = %d, want follow resumed at the last hop", m.selected)
+	}
+}
+
+func TestLandMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		land     bool
+	}{
+		{"Kansas", 38.5, -98, true},
+		{"Brazil", -10, -55, true},
+		{"Sahara", 23, 10, true},
+		{"Siberia", 62, 100, true},
+		{"Australia", -25, 134, true},
+		{"Pacific", 0, -140, false},
+		{"Atlantic", 30, -40, false},
+		{"Indian Ocean", -20, 80, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLand(tt.lon, tt.lat); got != tt.land {
+				t.Errorf("isLand(%v, %v) = %v, want %v", tt.lon, tt.lat, got, tt.land)
+			}
+		})
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	tests := map[string]key{
+		"\x1b[A": keyUp,
+		"j":      keyDown,
+		"r":      keyRestart,
+		"c":      keyCancel,
+		"q":      keyQuit,
+		"\x03":   keyQuit,
+		"x":      keyNone,
+	}
+	for input, want := range tests {
+		if got := decodeKey([]byte(input)); got != want {
+			t.Errorf("decodeKey(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
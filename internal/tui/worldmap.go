@@ -0,0 +1,149 @@
+package tui
+
+import "sync"
+
+// Latitude range drawn on the map; the polar regions carry no traceroute hops
+const (
+	mapNorth = 80.0
+	mapSouth = -60.0
+)
+
+// landPolygons are coarse coastlines as lon/lat pairs, good enough for a terminal-sized map
+var landPolygons = [][][2]float64{
+	// North America
+	{{-168, 66}, {-162, 70}, {-156, 71}, {-140, 70}, {-128, 70}, {-115, 68}, {-95, 72}, {-82, 73}, {-80, 63},
+		{-94, 59}, {-92, 57}, {-82, 55}, {-79, 52}, {-78, 58}, {-72, 62}, {-64, 60}, {-56, 52}, {-66, 45},
+		{-70, 42}, {-76, 38}, {-76, 35}, {-81, 31}, {-80, 26}, {-82, 26}, {-84, 30}, {-90, 30}, {-97, 28},
+		{-97, 22}, {-92, 18}, {-87, 21}, {-88, 16}, {-83, 10}, {-79, 9}, {-78, 8}, {-81, 8}, {-86, 12},
+		{-92, 15}, {-105, 20}, {-110, 23}, {-112, 29}, {-115, 30}, {-117, 33}, {-121, 35}, {-124, 40},
+		{-124, 47}, {-130, 54}, {-137, 59}, {-148, 61}, {-152, 58}, {-158, 56}, {-165, 54}, {-160, 59},
+		{-166, 62}},
+	// Arctic archipelago
+	{{-120, 72}, {-95, 78}, {-80, 83}, {-62, 82}, {-80, 73}, {-95, 72}},
+	// Greenland
+	{{-73, 78}, {-60, 82}, {-30, 83}, {-20, 80}, {-20, 70}, {-40, 65}, {-43, 60}, {-50, 64}, {-55, 70}},
+	// Cuba
+	{{-85, 22}, {-74, 20}, {-77, 20}, {-84, 22}},
+	// South America
+	{{-78, 8}, {-72, 12}, {-62, 11}, {-52, 5}, {-50, 0}, {-44, -2}, {-35, -5}, {-35, -9}, {-39, -15},
+		{-41, -22}, {-48, -26}, {-53, -34}, {-58, -38}, {-62, -39}, {-65, -42}, {-66, -47}, {-69, -51},
+		{-68, -55}, {-73, -53}, {-75, -47}, {-73, -38}, {-71, -30}, {-70, -18}, {-76, -14}, {-81, -6},
+		{-80, -1}, {-78, 2}, {-77, 7}},
+	// Africa
+	{{-17, 21}, {-16, 28}, {-10, 30}, {-6, 36}, {3, 37}, {10, 37}, {11, 33}, {20, 31}, {25, 32}, {32, 31},
+		{35, 28}, {39, 21}, {43, 12}, {51, 12}, {47, 5}, {40, -3}, {40, -15}, {35, -24}, {33, -27}, {28, -33},
+		{20, -35}, {18, -32}, {15, -27}, {12, -17}, {13, -8}, {9, -1}, {9, 4}, {5, 6}, {-5, 5}, {-8, 4},
+		{-13, 8}, {-17, 13}},
+	// Madagascar
+	{{44, -25}, {47, -25}, {50, -15}, {49, -12}, {44, -16}},
+	// Eurasia
+	{{-10, 36}, {-9, 43}, {-2, 44}, {-4, 48}, {2, 51}, {8, 54}, {8, 57}, {11, 56}, {13, 55}, {21, 55},
+		{23, 60}, {28, 60}, {22, 61}, {21, 64}, {25, 66}, {22, 66}, {17, 63}, {18, 60}, {11, 58}, {5, 59},
+		{5, 62}, {14, 67}, {20, 70}, {28, 71}, {41, 67}, {44, 68}, {53, 68}, {60, 69}, {69, 73}, {80, 73},
+		{87, 75}, {104, 78}, {113, 74}, {130, 71}, {140, 72}, {160, 70}, {180, 69}, {180, 65}, {178, 64},
+		{170, 60}, {163, 60}, {156, 51}, {156, 57}, {163, 62}, {155, 60}, {142, 59}, {135, 55}, {141, 52},
+		{140, 47}, {133, 43}, {129, 41}, {129, 35}, {126, 35}, {125, 38}, {122, 40}, {118, 39}, {122, 37},
+		{119, 35}, {122, 30}, {117, 24}, {109, 21}, {106, 20}, {109, 12}, {105, 9}, {100, 14}, {99, 8},
+		{103, 1}, {101, 3}, {98, 8}, {98, 16}, {94, 17}, {91, 22}, {87, 21}, {80, 15}, {77, 8}, {73, 17},
+		{72, 21}, {67, 24}, {58, 25}, {56, 27}, {52, 27}, {48, 30}, {50, 26}, {56, 24}, {59, 22}, {55, 17},
+		{44, 13}, {43, 17}, {39, 22}, {35, 28}, {34, 31}, {35, 36}, {30, 36}, {26, 38}, {27, 40}, {24, 40},
+		{22, 37}, {19, 40}, {19, 42}, {14, 45}, {12, 44}, {16, 41}, {18, 40}, {16, 38}, {12, 42}, {9, 44},
+		{3, 43}, {3, 42}, {0, 39}, {-2, 37}, {-6, 36}, {-9, 37}},
+	// Great Britain
+	{{-5, 50}, {1, 51}, {2, 53}, {-2, 56}, {-2, 58}, {-5, 59}, {-6, 56}, {-5, 54}, {-3, 54}, {-5, 52}},
+	// Ireland
+	{{-10, 52}, {-6, 52}, {-6, 55}, {-8, 55}, {-10, 54}},
+	// Iceland
+	{{-24, 64}, {-14, 64}, {-14, 66}, {-22, 66}},
+	// Japan
+	{{130, 31}, {132, 34}, {136, 34}, {140, 35}, {141, 38}, {142, 42}, {145, 44}, {141, 45}, {140, 42},
+		{139, 38}, {135, 36}, {130, 34}},
+	// Philippines
+	{{120, 18}, {122, 18}, {126, 7}, {122, 7}, {120, 14}},
+	// Sumatra
+	{{95, 5}, {98, 4}, {106, -6}, {104, -6}},
+	// Java
+	{{105, -6}, {114, -7}, {114, -8}, {106, -7}},
+	// Borneo
+	{{109, 1}, {117, 7}, {119, 1}, {116, -4}, {110, -3}},
+	// New Guinea
+	{{131, -1}, {141, -3}, {150, -10}, {143, -9}, {138, -8}, {132, -3}},
+	// Australia
+	{{114, -22}, {114, -34}, {117, -35}, {124, -34}, {131, -31}, {138, -35}, {141, -38}, {147, -38},
+		{150, -37}, {153, -32}, {153, -25}, {146, -19}, {145, -15}, {142, -11}, {141, -17}, {136, -15},
+		{136, -12}, {131, -11}, {127, -14}, {122, -17}},
+	// New Zealand
+	{{172, -34}, {178, -38}, {175, -41}, {171, -46}, {167, -46}, {172, -41}, {174, -37}},
+}
+
+// maskCache holds rasterized land masks keyed by size, since the map is redrawn on every event
+var (
+	maskMu    sync.Mutex
+	maskCache = make(map[[2]int][][]bool)
+)
+
+// landMask returns which cells of a width x height map are land
+func landMask(width, height int) [][]bool {
+	maskMu.Lock()
+	defer maskMu.Unlock()
+
+	key := [2]int{width, height}
+	if mask, ok := maskCache[key]; ok {
+		return mask
+	}
+
+	mask := make([][]bool, height)
+	for y := range mask {
+		mask[y] = make([]bool, width)
+		lat := mapNorth - (float64(y)+0.5)*(mapNorth-mapSouth)/float64(height)
+		for x := range mask[y] {
+			lon := -180 + (float64(x)+0.5)*360/float64(width)
+			mask[y][x] = isLand(lon, lat)
+		}
+	}
+	maskCache[key] = mask
+	return mask
+}
+
+// isLand reports whether a coordinate falls inside any land polygon
+func isLand(lon, lat float64) bool {
+	for _, polygon := range landPolygons {
+		if pointInPolygon(lon, lat, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// pointInPolygon uses ray casting to test containment
+func pointInPolygon(lon, lat float64, polygon [][2]float64) bool {
+	inside := false
+	j := len(polygon) - 1
+	for i := range polygon {
+		xi, yi := polygon[i][0], polygon[i][1]
+		xj, yj := polygon[j][0], polygon[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+		j = i
+	}
+	return inside
+}
+
+// project converts a coordinate to a map cell, clamping to the drawn area
+func project(lat, lon float64, width, height int) (x, y int) {
+	x = int((lon + 180) / 360 * float64(width))
+	y = int((mapNorth - lat) / (mapNorth - mapSouth) * float64(height))
+	return clamp(x, 0, width-1), clamp(y, 0, height-1)
+}
+
+// clamp limits v to the range [lo, hi]
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
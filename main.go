@@ -2,6 +2,9 @@ package main
 
 import (
 	"embed"
+	"os"
+
+	"packet-painter/internal/cli"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,6 +15,11 @@ import (
 var assets embed.FS
 
 func main() {
+	// Headless subcommands run without starting the window
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -25,6 +33,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 13, G: 10, B: 20, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},